@@ -12,16 +12,43 @@ type Play struct {
 	// 网络带宽限制（适用于部分 CNI）
 	Bandwidth BandwidthSpec
 
+	// 带宽限制方式, 可为空: "annotation"(CNI注解, 修改后Pod重建)或 "tc"(直接限速UPF的N3/N6接口, 首次启用时UPF Pod重建一次, 之后修改立即生效)
+	BandwidthEnforcer string // JSON字段名为 "bandwidth_enforcer"
+
 	// Pod 调度规则
 	Scheduling SchedulingSpec
 
 	// 网络策略（前端可传入完整策略结构）
 	NetworkPolicy networkingv1.NetworkPolicy
+	// 切片默认只允许SMF-UPF之间的N4、UPF的N3/N6以及共享NF访问SMF的SBI, 如需额外放行的流量在此添加
+	AllowRules []NetworkAllowRule // JSON字段名为 "allow_rules"
 	// 特定插件使用的注解（如限速、带宽隔离）
 	Annotations map[string]string
+
+	// 按NF划分的参数, 键为NF名称如 "smf", 顶层参数即UPF的参数
+	NFs map[string]NFPlay // JSON字段名为 "nfs"
+
+	// 由运维固定的参数("resources"、"bandwidth"), 不要修改这些参数, 修改也不会生效
+	OperatorOwned []string // JSON字段名为 "operator_owned"
+}
+
+// 额外放行的入站流量
+type NetworkAllowRule struct {
+	Name  string                           // 规则名称, 小写字母、数字和 "-"
+	NF    string                           // 目标NF, 如 "upf"
+	From  []networkingv1.NetworkPolicyPeer // 来源, 为空表示任意来源
+	Ports []networkingv1.NetworkPolicyPort // 端口, 为空表示所有端口
+}
+
+// 单个NF的参数, 未设置的部分保持不变
+type NFPlay struct {
+	Resources  ResourceSpec
+	Bandwidth  BandwidthSpec
+	Priority   int
+	Scheduling SchedulingSpec
 }
 
-// 资源定义（CPU / 内存）
+// 资源定义（CPU / 内存）, 格式同Kubernetes, 请求不能大于限制
 type ResourceSpec struct {
 	CPURequest    string    // "500m"
 	CPULimit      string      // "1"
@@ -29,10 +56,10 @@ type ResourceSpec struct {
 	MemoryLimit   string   // "1Gi"
 }
 
-// 带宽配置
+// 带宽配置, 单位为bit/s, 必须带K/M/G单位
 type BandwidthSpec struct {
-	Ingress string  // 例如 "100Mbps"
-	Egress  string  // 例如 "200Mbps"
+	Ingress string  // 例如 "100M" 表示100Mbps
+	Egress  string  // 例如 "200M" 表示200Mbps
 }
 
 // 调度器配置
@@ -40,6 +67,10 @@ type SchedulingSpec struct {
 	SchedulerName string            // 自定义调度器名称，默认 "default-scheduler"
 	NodeName      string                 // 若指定，Pod 将直接运行在此节点
 	NodeSelector  map[string]string  // 节点标签选择器
+	Affinity      *corev1.Affinity   // 可选, 节点亲和性及Pod亲和/反亲和性
+	Tolerations   []corev1.Toleration // 可选, 容忍的节点污点
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint // 可选, 拓扑分布约束
+	RuntimeClassName string          // 可选, 容器运行时, 如 "kata"
 }
 
 2. SLA的格式为：
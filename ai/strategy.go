@@ -152,5 +152,12 @@ func (s *StrategyAgent) Reconcile(current sm.Play, sla sm.SLA) (sm.Play, error)
 		return current, fmt.Errorf("解析响应失败: %w", err)
 	}
 
+	// ID和切片保持不变, 数值与API输入经过相同的校验
+	play.ID = current.ID
+	play.SliceID = current.SliceID
+	if err := play.Validate(); err != nil {
+		return current, fmt.Errorf("生成的策略不合法: %w", err)
+	}
+
 	return play, nil
 }
@@ -0,0 +1,254 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"slicer/db"
+	"slicer/util"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 归档在bucket中的布局:
+//
+//	slices/<sliceID>/<rev>/<file>       每次切片变更(创建、Play应用)生成一个新版本
+//	decisions/<sliceID>/<ts>.json       控制器的每次决策记录
+const (
+	slicePrefix    = "slices/"
+	decisionPrefix = "decisions/"
+
+	// 时间戳格式, 字典序即时间序
+	timeFormat = "20060102T150405.000Z"
+
+	// 保留策略的执行间隔
+	retentionInterval = time.Hour
+)
+
+// Archive 将切片的渲染结果与控制器决策记录归档到对象存储
+type Archive struct {
+	mu sync.Mutex // 保证同一切片的版本号分配不冲突
+
+	config util.Config
+	oss    db.OSS
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewArchive(config util.Config, oss db.OSS) (*Archive, error) {
+	if err := oss.EnsureBucket(config.OSSBucket); err != nil {
+		return nil, fmt.Errorf("初始化bucket %s 失败: %w", config.OSSBucket, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Archive{
+		config: config,
+		oss:    oss,
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Start 定时执行保留策略
+func (a *Archive) Start() {
+	go func() {
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for {
+			if err := a.ApplyRetention(); err != nil {
+				slog.Error("执行归档保留策略失败", "error", err)
+			}
+			select {
+			case <-a.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (a *Archive) Stop() {
+	a.cancel()
+}
+
+// SaveSliceRevision 保存切片的一个新版本, files的key为文件名, 返回新版本号
+func (a *Archive) SaveSliceRevision(sliceID string, files map[string][]byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	revs, err := a.ListSliceRevisions(sliceID)
+	if err != nil {
+		return 0, err
+	}
+	rev := 1
+	if len(revs) > 0 {
+		rev = revs[len(revs)-1] + 1
+	}
+
+	// 按文件名排序上传, 保证结果稳定
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key := SliceRevisionKey(sliceID, rev, name)
+		if err := a.oss.Upload(a.config.OSSBucket, key, bytes.NewReader(files[name])); err != nil {
+			return 0, fmt.Errorf("上传归档 %s 失败: %w", key, err)
+		}
+	}
+	return rev, nil
+}
+
+// SaveDecision 保存一次控制器决策记录, record会被序列化为JSON
+func (a *Archive) SaveDecision(sliceID string, t time.Time, record any) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化决策记录失败: %w", err)
+	}
+	key := DecisionKey(sliceID, t)
+	if err := a.oss.Upload(a.config.OSSBucket, key, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("上传决策记录 %s 失败: %w", key, err)
+	}
+	return nil
+}
+
+// ListSliceRevisions 返回切片已有的所有版本号, 从小到大排序
+func (a *Archive) ListSliceRevisions(sliceID string) ([]int, error) {
+	objects, err := a.oss.List(a.config.OSSBucket, slicePrefix+sliceID+"/")
+	if err != nil {
+		return nil, fmt.Errorf("列出切片归档失败: %w", err)
+	}
+
+	seen := make(map[int]bool)
+	for _, obj := range objects {
+		// slices/<sliceID>/<rev>/<file>
+		parts := strings.SplitN(strings.TrimPrefix(obj.Key, slicePrefix+sliceID+"/"), "/", 2)
+		rev, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		seen[rev] = true
+	}
+
+	revs := make([]int, 0, len(seen))
+	for rev := range seen {
+		revs = append(revs, rev)
+	}
+	sort.Ints(revs)
+	return revs, nil
+}
+
+// List 列出指定前缀下的所有归档对象
+func (a *Archive) List(prefix string) ([]db.ObjectInfo, error) {
+	if !strings.HasPrefix(prefix, slicePrefix) && !strings.HasPrefix(prefix, decisionPrefix) {
+		return nil, fmt.Errorf("非法的归档前缀: %s", prefix)
+	}
+	return a.oss.List(a.config.OSSBucket, prefix)
+}
+
+// Download 下载归档对象
+func (a *Archive) Download(key string, w io.Writer) error {
+	if !strings.HasPrefix(key, slicePrefix) && !strings.HasPrefix(key, decisionPrefix) {
+		return fmt.Errorf("非法的归档key: %s", key)
+	}
+	return a.oss.Download(a.config.OSSBucket, key, w)
+}
+
+// ApplyRetention 执行保留策略:
+// 每个切片只保留最新的ArchiveMaxRevisions个版本, 删除超过ArchiveDecisionRetention的决策记录
+func (a *Archive) ApplyRetention() error {
+	if a.config.ArchiveMaxRevisions > 0 {
+		if err := a.pruneRevisions(); err != nil {
+			return err
+		}
+	}
+	if a.config.ArchiveDecisionRetention > 0 {
+		if err := a.pruneDecisions(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Archive) pruneRevisions() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	objects, err := a.oss.List(a.config.OSSBucket, slicePrefix)
+	if err != nil {
+		return fmt.Errorf("列出切片归档失败: %w", err)
+	}
+
+	// sliceID -> rev -> keys
+	revisions := make(map[string]map[int][]string)
+	for _, obj := range objects {
+		parts := strings.SplitN(strings.TrimPrefix(obj.Key, slicePrefix), "/", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		rev, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		if revisions[parts[0]] == nil {
+			revisions[parts[0]] = make(map[int][]string)
+		}
+		revisions[parts[0]][rev] = append(revisions[parts[0]][rev], obj.Key)
+	}
+
+	for sliceID, revs := range revisions {
+		nums := make([]int, 0, len(revs))
+		for rev := range revs {
+			nums = append(nums, rev)
+		}
+		sort.Ints(nums)
+		for len(nums) > a.config.ArchiveMaxRevisions {
+			for _, key := range revs[nums[0]] {
+				if err := a.oss.Delete(a.config.OSSBucket, key); err != nil {
+					return fmt.Errorf("删除归档 %s 失败: %w", key, err)
+				}
+			}
+			slog.Debug("删除过期切片归档", "sliceID", sliceID, "rev", nums[0])
+			nums = nums[1:]
+		}
+	}
+	return nil
+}
+
+func (a *Archive) pruneDecisions() error {
+	objects, err := a.oss.List(a.config.OSSBucket, decisionPrefix)
+	if err != nil {
+		return fmt.Errorf("列出决策记录失败: %w", err)
+	}
+
+	deadline := time.Now().Add(-a.config.ArchiveDecisionRetention)
+	for _, obj := range objects {
+		t, err := time.Parse(timeFormat, strings.TrimSuffix(path.Base(obj.Key), ".json"))
+		if err != nil || t.After(deadline) {
+			continue
+		}
+		if err := a.oss.Delete(a.config.OSSBucket, obj.Key); err != nil {
+			return fmt.Errorf("删除决策记录 %s 失败: %w", obj.Key, err)
+		}
+	}
+	return nil
+}
+
+// SliceRevisionKey 切片版本中文件的key
+func SliceRevisionKey(sliceID string, rev int, name string) string {
+	return fmt.Sprintf("%s%s/%d/%s", slicePrefix, sliceID, rev, name)
+}
+
+// DecisionKey 决策记录的key
+func DecisionKey(sliceID string, t time.Time) string {
+	return fmt.Sprintf("%s%s/%s.json", decisionPrefix, sliceID, t.UTC().Format(timeFormat))
+}
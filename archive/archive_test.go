@@ -0,0 +1,107 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slicer/db"
+	"slicer/util"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memOSS 内存中的对象存储, 仅实现归档使用的方法
+type memOSS struct {
+	db.OSS
+	objects map[string][]byte
+}
+
+func (m *memOSS) EnsureBucket(bucketName string) error { return nil }
+
+func (m *memOSS) Upload(bucketName, objectName string, reader io.Reader) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	m.objects[objectName] = data
+	return nil
+}
+
+func (m *memOSS) Download(bucketName, objectName string, writer io.Writer) error {
+	data, ok := m.objects[objectName]
+	if !ok {
+		return fmt.Errorf("对象不存在: %s", objectName)
+	}
+	_, err := writer.Write(data)
+	return err
+}
+
+func (m *memOSS) Delete(bucketName, objectName string) error {
+	delete(m.objects, objectName)
+	return nil
+}
+
+func (m *memOSS) List(bucketName, prefix string) ([]db.ObjectInfo, error) {
+	var infos []db.ObjectInfo
+	for key, data := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, db.ObjectInfo{Key: key, Size: int64(len(data))})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func newTestArchive(t *testing.T, config util.Config) (*Archive, *memOSS) {
+	oss := &memOSS{objects: make(map[string][]byte)}
+	a, err := NewArchive(config, oss)
+	require.NoError(t, err)
+	return a, oss
+}
+
+func TestSaveSliceRevision(t *testing.T) {
+	a, oss := newTestArchive(t, util.Config{})
+
+	files := map[string][]byte{"manifests/smf-configmap.yaml": []byte("a: 1")}
+	for want := 1; want <= 3; want++ {
+		rev, err := a.SaveSliceRevision("1-000001", files)
+		require.NoError(t, err)
+		require.Equal(t, want, rev)
+	}
+	require.Contains(t, oss.objects, "slices/1-000001/3/manifests/smf-configmap.yaml")
+
+	var buf bytes.Buffer
+	require.NoError(t, a.Download("slices/1-000001/1/manifests/smf-configmap.yaml", &buf))
+	require.Equal(t, "a: 1", buf.String())
+
+	// 只允许访问归档前缀下的对象
+	require.Error(t, a.Download("backup/x.zip", &buf))
+}
+
+func TestApplyRetention(t *testing.T) {
+	a, oss := newTestArchive(t, util.Config{OSSConfig: util.OSSConfig{
+		ArchiveMaxRevisions:      2,
+		ArchiveDecisionRetention: time.Hour,
+	}})
+
+	for range 3 {
+		_, err := a.SaveSliceRevision("1-000001", map[string][]byte{"slice.json": []byte("{}")})
+		require.NoError(t, err)
+	}
+	require.NoError(t, a.SaveDecision("1-000001", time.Now().Add(-2*time.Hour), map[string]string{"k": "old"}))
+	require.NoError(t, a.SaveDecision("1-000001", time.Now(), map[string]string{"k": "new"}))
+
+	require.NoError(t, a.ApplyRetention())
+
+	revs, err := a.ListSliceRevisions("1-000001")
+	require.NoError(t, err)
+	require.Equal(t, []int{2, 3}, revs)
+
+	decisions, err := oss.List("", "decisions/1-000001/")
+	require.NoError(t, err)
+	require.Len(t, decisions, 1)
+}
@@ -0,0 +1,361 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"slicer/db"
+	"slicer/delivery"
+	"slicer/kubeclient"
+	"slicer/model"
+	"slicer/render"
+	"slicer/util"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// 备份在bucket中的前缀
+	backupPrefix = "backup/"
+	// 备份格式版本, 格式变化时递增
+	formatVersion = 1
+
+	manifestFile = "manifest.json"
+	mongoDir     = "mongo/"
+	ipamFile     = "ipam/prefixes.json"
+)
+
+// Manifest 备份归档的描述信息
+type Manifest struct {
+	Version     int            `json:"version"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Database    string         `json:"database"`
+	Collections map[string]int `json:"collections"` // 集合名称 -> 字节数
+}
+
+// Backup 负责将数据库和IPAM状态备份到对象存储, 并从备份恢复
+type Backup struct {
+	mu sync.Mutex // 保证同一时间只有一个备份/恢复操作
+
+	config   util.Config
+	store    db.Store
+	ipam     *db.IPAM
+	oss      db.OSS
+	render   *render.Render
+	clusters *kubeclient.Clusters
+	delivery delivery.Deliverer // 重新应用切片及Play的交付方式
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewBackup(config util.Config, store db.Store, ipam *db.IPAM, oss db.OSS, render *render.Render, clusters *kubeclient.Clusters, deliverer delivery.Deliverer) (*Backup, error) {
+	if err := oss.EnsureBucket(config.OSSBucket); err != nil {
+		return nil, fmt.Errorf("初始化bucket %s 失败: %w", config.OSSBucket, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Backup{
+		config:   config,
+		store:    store,
+		ipam:     ipam,
+		oss:      oss,
+		render:   render,
+		clusters: clusters,
+		delivery: deliverer,
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+// Start 启动定时备份, BackupInterval为0时不启动
+func (b *Backup) Start() {
+	if b.config.BackupInterval <= 0 {
+		slog.Info("未设置备份间隔, 不进行定时备份")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(b.config.BackupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-ticker.C:
+				key, err := b.Create()
+				if err != nil {
+					slog.Error("定时备份失败", "error", err)
+					continue
+				}
+				slog.Info("定时备份完成", "key", key)
+			}
+		}
+	}()
+}
+
+func (b *Backup) Stop() {
+	b.cancel()
+}
+
+// Create 创建一次完整备份, 返回备份在bucket中的key
+func (b *Backup) Create() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	collections, err := b.store.DumpCollections()
+	if err != nil {
+		return "", fmt.Errorf("导出数据库失败: %w", err)
+	}
+	prefixes, err := b.ipam.Dump()
+	if err != nil {
+		return "", fmt.Errorf("导出IPAM失败: %w", err)
+	}
+
+	manifest := Manifest{
+		Version:     formatVersion,
+		CreatedAt:   time.Now().UTC(),
+		Database:    b.config.MongoDBName,
+		Collections: make(map[string]int),
+	}
+
+	// 写入zip归档
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range collections {
+		manifest.Collections[name] = len(data)
+		if err := writeZipFile(zw, mongoDir+name+".json", data); err != nil {
+			return "", err
+		}
+	}
+	if err := writeZipFile(zw, ipamFile, []byte(prefixes)); err != nil {
+		return "", err
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("序列化备份描述失败: %w", err)
+	}
+	if err := writeZipFile(zw, manifestFile, manifestData); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("关闭备份归档失败: %w", err)
+	}
+
+	// 以时间命名, 便于按时间排序
+	key := backupPrefix + manifest.CreatedAt.Format("20060102T150405Z") + ".zip"
+	if err := b.oss.Upload(b.config.OSSBucket, key, &buf); err != nil {
+		return "", fmt.Errorf("上传备份失败: %w", err)
+	}
+	return key, nil
+}
+
+// List 列出所有备份, 按时间从旧到新排序
+func (b *Backup) List() ([]db.ObjectInfo, error) {
+	objects, err := b.oss.List(b.config.OSSBucket, backupPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("列出备份失败: %w", err)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+// Latest 返回最新备份的key
+func (b *Backup) Latest() (string, error) {
+	objects, err := b.List()
+	if err != nil {
+		return "", err
+	}
+	if len(objects) == 0 {
+		return "", fmt.Errorf("没有可用的备份")
+	}
+	return objects[len(objects)-1].Key, nil
+}
+
+// Restore 从指定备份恢复数据库和IPAM状态, key为"latest"时使用最新备份
+// reapply为true时, 恢复完成后将所有切片重新渲染并应用到集群
+func (b *Backup) Restore(key string, reapply bool) (Manifest, error) {
+	if key == "latest" {
+		latest, err := b.Latest()
+		if err != nil {
+			return Manifest{}, err
+		}
+		key = latest
+	}
+	if !strings.HasPrefix(key, backupPrefix) {
+		key = path.Join(backupPrefix, key)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 下载并解析归档
+	var buf bytes.Buffer
+	if err := b.oss.Download(b.config.OSSBucket, key, &buf); err != nil {
+		return Manifest{}, fmt.Errorf("下载备份 %s 失败: %w", key, err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("解析备份 %s 失败: %w", key, err)
+	}
+
+	var manifest Manifest
+	var prefixes string
+	collections := make(map[string][]byte)
+	for _, f := range zr.File {
+		data, err := readZipFile(f)
+		if err != nil {
+			return Manifest{}, err
+		}
+		switch {
+		case f.Name == manifestFile:
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return Manifest{}, fmt.Errorf("解析备份描述失败: %w", err)
+			}
+		case f.Name == ipamFile:
+			prefixes = string(data)
+		case strings.HasPrefix(f.Name, mongoDir):
+			name := strings.TrimSuffix(strings.TrimPrefix(f.Name, mongoDir), ".json")
+			collections[name] = data
+		}
+	}
+	if manifest.Version != formatVersion {
+		return manifest, fmt.Errorf("不支持的备份格式版本: %d", manifest.Version)
+	}
+
+	// 先恢复IPAM, 失败时IPAM自行回滚且数据库未变化; 数据库恢复失败时重新导入恢复前的IPAM
+	// 保证数据库与IPAM来自同一备份, 避免地址被重复分配
+	if prefixes != "" {
+		previous, err := b.ipam.Dump()
+		if err != nil {
+			return manifest, fmt.Errorf("导出恢复前的IPAM失败: %w", err)
+		}
+		if err := b.ipam.Load(prefixes); err != nil {
+			return manifest, fmt.Errorf("恢复IPAM失败: %w", err)
+		}
+		if err := b.store.RestoreCollections(collections); err != nil {
+			if rollbackErr := b.ipam.Load(previous); rollbackErr != nil {
+				slog.Error("回滚IPAM失败", "error", rollbackErr)
+			}
+			return manifest, fmt.Errorf("恢复数据库失败: %w", err)
+		}
+	} else if err := b.store.RestoreCollections(collections); err != nil {
+		return manifest, fmt.Errorf("恢复数据库失败: %w", err)
+	}
+	slog.Info("数据库与IPAM恢复完成", "key", key, "createdAt", manifest.CreatedAt)
+
+	if reapply {
+		if err := b.reapply(); err != nil {
+			return manifest, fmt.Errorf("重新应用切片失败: %w", err)
+		}
+	}
+	return manifest, nil
+}
+
+// reapply 通过配置的交付方式重新交付存储中的所有切片及其Play, 再重新部署监控的MDE和KPI计算组件
+func (b *Backup) reapply() error {
+	slices, err := b.store.ListSlice()
+	if err != nil {
+		return err
+	}
+	for _, slice := range slices {
+		sliceID := slice.SliceID()
+		var current *model.Play
+		play, err := b.store.GetPlayBySliceID(sliceID)
+		if err == nil {
+			current = &play
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("获取切片 %s 的Play失败: %w", sliceID, err)
+		}
+		hasPlay := current != nil
+
+		status, err := b.delivery.DeliverSlice(slice, current)
+		if err != nil {
+			return fmt.Errorf("交付切片 %s 失败: %w", sliceID, err)
+		}
+		// 切片模板不含Play的资源、带宽、优先级等, 有Play的切片需重新交付Play
+		if hasPlay {
+			if status, err = b.delivery.DeliverPlay(slice, play); err != nil {
+				return fmt.Errorf("交付切片 %s 的Play失败: %w", sliceID, err)
+			}
+		}
+
+		slice.Status = &status
+		if _, err := b.store.UpdateSlice(slice); err != nil {
+			slog.Error("更新切片交付状态失败", "sliceID", sliceID, "error", err)
+		}
+		slog.Info("切片已重新交付", "sliceID", sliceID, "play", hasPlay)
+	}
+	return b.reapplyMonitors()
+}
+
+// reapplyMonitors 按存储的监控请求重新部署MDE和KPI计算组件, 与切片位于同一集群, 全部监控时位于默认集群
+func (b *Backup) reapplyMonitors() error {
+	monitors, err := b.store.ListMonitor()
+	if err != nil {
+		return fmt.Errorf("获取监控请求失败: %w", err)
+	}
+	for _, monitor := range monitors {
+		var sliceID string
+		if ids := monitor.KPI.SubCounter.SubCounterIDs; len(ids) > 0 {
+			sliceID = ids[0]
+		}
+		kclient := b.clusters.Default()
+		if sliceID != "" {
+			slice, err := b.store.GetSliceBySliceID(sliceID)
+			if err != nil {
+				return fmt.Errorf("获取监控的切片 %s 失败: %w", sliceID, err)
+			}
+			if kclient, err = b.clusters.ForSlice(slice); err != nil {
+				return fmt.Errorf("切片 %s: %w", sliceID, err)
+			}
+		}
+		mde, err := b.render.RenderMde(sliceID)
+		if err != nil {
+			return fmt.Errorf("渲染切片 %s 的MDE失败: %w", sliceID, err)
+		}
+		if err := kclient.ApplyMDE(mde); err != nil {
+			return fmt.Errorf("部署切片 %s 的MDE失败: %w", sliceID, err)
+		}
+		kpic, err := b.render.RenderKpiCalc(sliceID)
+		if err != nil {
+			return fmt.Errorf("渲染切片 %s 的KPI计算组件失败: %w", sliceID, err)
+		}
+		if err := kclient.ApplyKpic(kpic); err != nil {
+			return fmt.Errorf("部署切片 %s 的KPI计算组件失败: %w", sliceID, err)
+		}
+		slog.Info("监控已重新部署", "monitorID", monitor.ID.Hex(), "sliceID", sliceID)
+	}
+	return nil
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("写入备份文件 %s 失败: %w", name, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("写入备份文件 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取备份文件 %s 失败: %w", f.Name, err)
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
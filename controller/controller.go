@@ -2,12 +2,22 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"slicer/db"
+	"slicer/delivery"
 	"slicer/kubeclient"
+	"slicer/model"
 	"slicer/util"
+	"slices"
+	"sort"
 	"sync"
 	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
 )
 
 type Controller interface {
@@ -24,6 +34,11 @@ type Controller interface {
 	AddSlice(sliceID string)
 	RemoveSlice(sliceID string)
 	ListSlices() []string
+	GetSliceSchedule(sliceID string) (SliceSchedule, bool)                // 获取切片的控制设置
+	ListSliceSchedules() []SliceSchedule                                  // 列出所有切片的控制设置, 按切片ID排序
+	UpdateSliceControl(control model.SliceControl) (SliceSchedule, error) // 更新并保存切片的控制设置
+	Reconcile(sliceID string, reason string) error                        // 立即控制切片, 不等待下一次调度
+	Reload() error                                                        // 从存储重新加载切片设置, 用于恢复备份后
 
 	// 策略
 	SetStrategy(strategy Strategy)
@@ -32,8 +47,48 @@ type Controller interface {
 	UnregisterStrategy(strategy ...Strategy)
 	ListStrategy() []Strategy
 	GetStrategyByName(name string) Strategy
+
+	// 决策记录
+	SetRecorder(recorder Recorder)
+	// 切片版本归档, 每次应用Play后归档切片在集群中的版本
+	SetArchiver(archiver SliceArchiver)
+
+	// 交付方式, 未设置时直接通过kubeclient应用Play
+	SetDeliverer(deliverer delivery.Deliverer)
+}
+
+// Recorder 记录控制器每次决策的输入与结果
+type Recorder interface {
+	SaveDecision(sliceID string, t time.Time, record any) error
+}
+
+// SliceArchiver 归档切片的当前版本(应用到集群的清单、集群中的资源、切片与Play)
+type SliceArchiver interface {
+	ArchiveSlice(sliceID string)
 }
 
+// Decision 一次控制决策的记录
+type Decision struct {
+	SliceID  string      `json:"slice_id"`
+	Time     time.Time   `json:"time"`
+	Strategy string      `json:"strategy"`
+	SLA      *model.SLA  `json:"sla,omitempty"`
+	Play     *model.Play `json:"play,omitempty"`     // 决策前的Play
+	NewPlay  *model.Play `json:"new_play,omitempty"` // 策略生成的Play
+	Error    string      `json:"error,omitempty"`
+	// 新Play未就绪, 已自动恢复为决策前的Play
+	RolledBack bool `json:"rolled_back,omitempty"`
+}
+
+const (
+	defaultWorkers     = 4
+	defaultRate        = 60 // 每分钟
+	defaultBackoffBase = 10 * time.Second
+	defaultBackoffMax  = 10 * time.Minute
+	// 连续失败的重试次数上限
+	maxRetries = 5
+)
+
 type BasicController struct {
 	// 互斥锁
 	mu sync.Mutex // 保护running, frequency以及切片和策略相关资源
@@ -46,37 +101,83 @@ type BasicController struct {
 	config util.Config
 	// 存储
 	store db.Store
-	// 控制器的配置
-	kclient *kubeclient.KubeClient
+	// 切片所在的集群, 未设置交付方式时直接应用Play
+	clusters *kubeclient.Clusters
 
 	// 运行状态
 	running bool
-	// 控制频率
+	// 控制频率, 未单独设置间隔的切片使用该频率
 	frequency time.Duration
 
-	// 切片列表
-	slices []string
+	// 切片及其设置, key为切片ID
+	slices map[string]*sliceEntry
+	// 启用的切片按下次控制时间排序
+	timers schedule
+	// 切片或频率变化时唤醒run重新计算等待时间
+	wake chan struct{}
+
+	// 待控制的切片, 同一切片在队列中只出现一次, 且同一时间只由一个worker控制, 停止时为nil
+	queue workqueue.TypedRateLimitingInterface[string]
+	// 上一次运行的worker全部退出后关闭
+	done chan struct{}
+	// 并行控制的worker数
+	workers int
+	// 所有切片共用的控制速率
+	limiter *rate.Limiter
+	// 控制失败后的重试间隔
+	backoffBase time.Duration
+	backoffMax  time.Duration
 	// 策略列表
 	strategies []Strategy
 	// 策略
 	strategy Strategy
+	// 决策记录, 可为nil
+	recorder Recorder
+	// 切片版本归档, 可为nil
+	archiver SliceArchiver
+	// 交付方式, 可为nil
+	deliverer delivery.Deliverer
 }
 
 // NewBasicController 创建一个新的控制器
 // 注册传入的所有strategy, 并将第一个strategy设置为默认策略, 若不传入则strategy为nil
-func NewBasicController(config util.Config, store db.Store, kclient *kubeclient.KubeClient, strategy ...Strategy) Controller {
+func NewBasicController(config util.Config, store db.Store, clusters *kubeclient.Clusters, strategy ...Strategy) Controller {
 	// 创建一个新的上下文和取消函数
 	ctx, cancel := context.WithCancel(context.Background())
 
+	workers := config.ControllerWorkers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	perMinute := config.ControllerRate
+	if perMinute <= 0 {
+		perMinute = defaultRate
+	}
+	backoffBase := config.ControllerBackoffBase
+	if backoffBase <= 0 {
+		backoffBase = defaultBackoffBase
+	}
+	backoffMax := config.ControllerBackoffMax
+	if backoffMax <= 0 {
+		backoffMax = defaultBackoffMax
+	}
+	backoffMax = max(backoffMax, backoffBase)
+
 	c := &BasicController{
 		running:   false,
 		frequency: 6 * time.Hour,
 		ctx:       ctx,
 		cancel:    cancel,
-		slices:    []string{},
-		config:    config,
-		store:     store,
-		kclient:   kclient,
+		slices:    map[string]*sliceEntry{},
+		wake:      make(chan struct{}, 1),
+		workers:   workers,
+		// 允许worker数的突发, 启动时到期的切片可以同时开始
+		limiter:     rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), workers),
+		backoffBase: backoffBase,
+		backoffMax:  backoffMax,
+		config:      config,
+		store:       store,
+		clusters:    clusters,
 		strategy: func() Strategy {
 			if len(strategy) > 0 {
 				return strategy[0]
@@ -85,74 +186,258 @@ func NewBasicController(config util.Config, store db.Store, kclient *kubeclient.
 		}(),
 	}
 	c.RegisterStrategy(strategy...) // 注册策略
+	c.loadSlices()                  // 恢复已保存的切片设置
 	return c
 }
 
-// 运行相关
-func (c *BasicController) Start() {
-	go c.run()
+// loadSlices 从存储中恢复切片的控制设置, 失败时只记录日志
+func (c *BasicController) loadSlices() {
+	controls, err := c.store.ListSliceControl()
+	if err != nil {
+		slog.Error("加载切片控制设置失败", "err", err)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, control := range controls {
+		c.setSliceLocked(control, now)
+	}
 }
 
-func (c *BasicController) run() {
+// Reload 以存储中的切片设置替换控制器中的切片, 存储中没有的切片移出控制器
+func (c *BasicController) Reload() error {
+	controls, err := c.store.ListSliceControl()
+	if err != nil {
+		return fmt.Errorf("加载切片控制设置失败: %w", err)
+	}
+	c.mu.Lock()
+	keep := make(map[string]bool, len(controls))
+	now := time.Now()
+	for _, control := range controls {
+		keep[control.SliceID] = true
+		c.setSliceLocked(control, now)
+	}
+	for id, entry := range c.slices {
+		if !keep[id] {
+			c.timers.remove(entry)
+			delete(c.slices, id)
+		}
+	}
+	c.mu.Unlock()
+	c.notify()
+	return nil
+}
+
+// 运行相关
+
+// Start 启动调度和worker, 上一次运行的worker仍在控制切片时, 等待其退出后再开始
+func (c *BasicController) Start() {
 	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.running {
-		c.mu.Unlock()
 		return
 	}
 	c.running = true
-	c.mu.Unlock()
+	c.queue = workqueue.NewTypedRateLimitingQueue(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[string](c.backoffBase, c.backoffMax))
+	prev := c.done
+	c.done = make(chan struct{})
+	go c.run(c.ctx, c.queue, prev, c.done)
+}
+
+func (c *BasicController) run(ctx context.Context, queue workqueue.TypedRateLimitingInterface[string], prev, done chan struct{}) {
+	defer close(done)
+	if prev != nil {
+		<-prev
+	}
+
+	var wg sync.WaitGroup
+	for range c.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c.processNext(ctx, queue) {
+			}
+		}()
+	}
+
+	c.schedule(ctx, queue)
+
+	// 正在进行的控制完成后worker退出, 队列中剩余的切片丢弃
+	queue.ShutDown()
+	wg.Wait()
+}
 
-	ticker := time.NewTicker(c.frequency)
-	defer ticker.Stop()
+// schedule 按各切片的下次控制时间将到期的切片加入队列, 直到ctx取消
+func (c *BasicController) schedule(ctx context.Context, queue workqueue.TypedRateLimitingInterface[string]) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
 
 	for {
+		// 等待最早到期的切片, 没有启用的切片时等待唤醒
+		c.mu.Lock()
+		wait, ok := c.timers.wait(time.Now())
+		c.mu.Unlock()
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		var expired <-chan time.Time
+		if ok {
+			timer.Reset(max(wait, 0))
+			expired = timer.C
+		}
+
 		select {
-		case <-c.ctx.Done(): // 停止
-			c.mu.Lock()
-			c.running = false
-			c.mu.Unlock()
+		case <-ctx.Done(): // 停止
 			return
-		case <-ticker.C:
-			// 执行控制逻辑
-			for _, sliceID := range c.slices {
-				err := c.control(sliceID)
-				if err != nil {
-					slog.Error("控制失败, 跳过", "sliceID", sliceID, "err", err)
-					continue
-				}
+		case <-c.wake:
+		case <-expired:
+			for _, sliceID := range c.due() {
+				queue.Add(sliceID)
 			}
 		}
 	}
 }
 
-func (c *BasicController) control(sliceID string) error {
+// processNext 从队列中取出一个切片进行控制, 队列关闭时返回false
+// 失败时按指数退避重新加入队列, 超过maxRetries次后等待下一次调度
+func (c *BasicController) processNext(ctx context.Context, queue workqueue.TypedRateLimitingInterface[string]) bool {
+	sliceID, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+	defer queue.Done(sliceID)
+
+	// 已停止或切片已移出控制器时丢弃
+	if err := c.limiter.Wait(ctx); err != nil {
+		queue.Forget(sliceID)
+		return true
+	}
+	c.mu.Lock()
+	entry, ok := c.slices[sliceID]
+	var strategy string
+	if ok {
+		strategy = entry.control.Strategy
+	}
+	c.mu.Unlock()
+	if !ok {
+		queue.Forget(sliceID)
+		return true
+	}
+
+	err := c.control(ctx, sliceID, strategy)
+	if err == nil || ctx.Err() != nil {
+		queue.Forget(sliceID)
+		return true
+	}
+	if retries := queue.NumRequeues(sliceID); retries < maxRetries {
+		slog.Warn("控制失败, 稍后重试", "sliceID", sliceID, "retries", retries, "err", err)
+		queue.AddRateLimited(sliceID)
+		return true
+	}
+	slog.Error("控制多次失败, 等待下一次调度", "sliceID", sliceID, "err", err)
+	queue.Forget(sliceID)
+	return true
+}
+
+// due 取出到期的切片并安排下一次控制, 返回到期的切片ID
+func (c *BasicController) due() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	var ids []string
+	for _, entry := range c.timers.due(now) {
+		ids = append(ids, entry.control.SliceID)
+		c.timers.set(entry, now.Add(c.intervalLocked(entry.control)))
+	}
+	return ids
+}
+
+// notify 唤醒run重新计算等待时间, 不阻塞
+func (c *BasicController) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// control 使用切片设置的策略控制切片, strategyName为空时使用全局策略
+// 策略(如AI调用)可能耗时较长, 返回后若ctx已取消(控制器已停止)则丢弃新Play, 不再应用
+func (c *BasicController) control(ctx context.Context, sliceID, strategyName string) (err error) {
+	decision := Decision{SliceID: sliceID, Time: time.Now()}
+	defer func() {
+		if err != nil {
+			decision.Error = err.Error()
+		}
+		c.record(decision)
+	}()
+
+	strategy := c.GetStrategy()
+	if strategyName != "" {
+		strategy = c.GetStrategyByName(strategyName)
+	}
+	if strategy == nil {
+		err = fmt.Errorf("策略 %q 不存在", strategyName)
+		slog.Error("获取策略失败", "sliceID", sliceID, "err", err)
+		return err
+	}
+
 	// 获取SLA
-	sla, err := c.store.GetSLA(sliceID)
+	sla, err := c.store.GetSLABySliceID(sliceID)
 	if err != nil {
 		slog.Error("获取SLA失败", "sliceID", sliceID, "err", err)
 		return err
 	}
+	decision.SLA = &sla
 
 	// 获取Play
-	play, err := c.store.GetPlay(sliceID)
+	play, err := c.store.GetPlayBySliceID(sliceID)
 	if err != nil {
 		slog.Error("获取Play失败", "sliceID", sliceID, "err", err)
 		return err
 	}
+	decision.Play = &play
 
 	// 核心控制逻辑
 	// 调用策略执行Reconcile
 	// 生成新的Play
-	newPlay, err := c.strategy.Reconcile(play, sla)
+	decision.Strategy = strategy.Name()
+	newPlay, err := strategy.Reconcile(play, sla)
 	if err != nil {
 		slog.Error("生成新Play失败", "sliceID", sliceID, "err", err)
 		return err
 	}
+	// 策略只调整资源和带宽, 放行规则、副本数等其余参数只能通过API修改, 沿用当前设置
+	newPlay.KeepFixed(play)
+	decision.NewPlay = &newPlay
+	if ctx.Err() != nil {
+		err = fmt.Errorf("控制器已停止, 不应用新Play")
+		slog.Info("控制器已停止, 丢弃新Play", "sliceID", sliceID)
+		return err
+	}
+
+	// 策略(尤其是AI策略)的输出需与API输入经过相同的校验
+	if err = newPlay.Validate(); err != nil {
+		slog.Error("新Play不合法", "sliceID", sliceID, "err", err)
+		return err
+	}
 
 	// 应用新的Play
-	err = c.kclient.Play(newPlay, c.config.Namespace)
+	err = c.applyPlay(sliceID, newPlay)
 	if err != nil {
 		slog.Error("应用Play失败", "sliceID", sliceID, "err", err)
+		if delivery.IsRolloutError(err) && c.config.RolloutAutoRollback {
+			if revertErr := c.applyPlay(sliceID, play); revertErr != nil {
+				slog.Error("回滚Play失败", "sliceID", sliceID, "err", revertErr)
+			} else {
+				decision.RolledBack = true
+				c.archive(sliceID)
+			}
+		}
 		return err
 	}
 
@@ -163,6 +448,9 @@ func (c *BasicController) control(sliceID string) error {
 		return err
 	}
 
+	// 集群中的切片已变化, 归档新版本
+	c.archive(sliceID)
+
 	// 更新SLA
 	_, err = c.store.UpdateSLA(sla)
 	if err != nil {
@@ -175,15 +463,56 @@ func (c *BasicController) control(sliceID string) error {
 	return nil
 }
 
+// applyPlay 通过交付方式应用Play并记录交付状态, 未设置交付方式时直接应用到集群
+func (c *BasicController) applyPlay(sliceID string, play model.Play) error {
+	c.mu.Lock()
+	deliverer := c.deliverer
+	c.mu.Unlock()
+
+	slice, err := c.store.GetSliceBySliceID(sliceID)
+	if err != nil {
+		return err
+	}
+	if deliverer == nil {
+		kclient, err := c.clusters.ForSlice(slice)
+		if err != nil {
+			return err
+		}
+		_, err = kclient.Play(play, c.config.SliceNamespace(play.SliceID))
+		return err
+	}
+	status, err := deliverer.DeliverPlay(slice, play)
+	if err != nil {
+		return err
+	}
+	slice.Status = &status
+	if _, err := c.store.UpdateSlice(slice); err != nil {
+		slog.Error("更新切片交付状态失败", "sliceID", sliceID, "err", err)
+	}
+	return nil
+}
+
+// record 保存决策记录, 失败只记录日志不影响控制
+func (c *BasicController) record(decision Decision) {
+	c.mu.Lock()
+	recorder := c.recorder
+	c.mu.Unlock()
+	if recorder == nil {
+		return
+	}
+	if err := recorder.SaveDecision(decision.SliceID, decision.Time, decision); err != nil {
+		slog.Error("保存决策记录失败", "sliceID", decision.SliceID, "err", err)
+	}
+}
+
+// Stop 停止调度, 不等待正在进行的控制完成; 其策略返回后不再应用新Play
 func (c *BasicController) Stop() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.running {
 		c.cancel() // 取消上下文
 		c.running = false
-
-		// 等待控制器停止
-		time.Sleep(100 * time.Millisecond)
+		c.queue = nil
 
 		// 重新创建上下文和取消函数
 		c.ctx, c.cancel = context.WithCancel(context.Background())
@@ -197,45 +526,171 @@ func (c *BasicController) IsRunning() bool {
 }
 
 // 切片相关
+
+// AddSlice 将切片加入控制, 已保存的设置优先, 否则使用默认设置并保存
 func (c *BasicController) AddSlice(sliceID string) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	for _, id := range c.slices {
-		if id == sliceID {
-			return
+	_, exists := c.slices[sliceID]
+	c.mu.Unlock()
+	if exists {
+		return
+	}
+
+	// 只有设置不存在时才保存默认设置, 查询失败时不覆盖已保存的设置
+	control, err := c.store.GetSliceControl(sliceID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		control = model.DefaultSliceControl(sliceID)
+		if control, err = c.store.SaveSliceControl(control); err != nil {
+			slog.Error("保存切片控制设置失败", "sliceID", sliceID, "err", err)
 		}
+	} else if err != nil {
+		slog.Error("获取切片控制设置失败, 暂时使用默认设置", "sliceID", sliceID, "err", err)
+		control = model.DefaultSliceControl(sliceID)
 	}
-	c.slices = append(c.slices, sliceID)
+
+	c.mu.Lock()
+	c.setSliceLocked(control, time.Now())
+	c.mu.Unlock()
+	c.notify()
 }
+
 func (c *BasicController) RemoveSlice(sliceID string) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	for i, id := range c.slices {
-		if id == sliceID {
-			c.slices = append(c.slices[:i], c.slices[i+1:]...)
-			return
-		}
+	entry, ok := c.slices[sliceID]
+	if ok {
+		c.timers.remove(entry)
+		delete(c.slices, sliceID)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := c.store.DeleteSliceControl(sliceID); err != nil {
+		slog.Error("删除切片控制设置失败", "sliceID", sliceID, "err", err)
 	}
+	c.notify()
 }
 
 func (c *BasicController) ListSlices() []string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.slices
+	ids := make([]string, 0, len(c.slices))
+	for id := range c.slices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (c *BasicController) GetSliceSchedule(sliceID string) (SliceSchedule, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.slices[sliceID]
+	if !ok {
+		return SliceSchedule{}, false
+	}
+	return entry.schedule(), true
+}
+
+func (c *BasicController) ListSliceSchedules() []SliceSchedule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	schedules := make([]SliceSchedule, 0, len(c.slices))
+	for _, entry := range c.slices {
+		schedules = append(schedules, entry.schedule())
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].SliceID < schedules[j].SliceID })
+	return schedules
+}
+
+// UpdateSliceControl 校验并保存切片的控制设置, 切片需已加入控制器
+// 修改后从当前时间起按新的间隔重新安排
+func (c *BasicController) UpdateSliceControl(control model.SliceControl) (SliceSchedule, error) {
+	if err := control.Validate(); err != nil {
+		return SliceSchedule{}, err
+	}
+	if control.Strategy != "" && c.GetStrategyByName(control.Strategy) == nil {
+		return SliceSchedule{}, fmt.Errorf("策略 %q 不存在", control.Strategy)
+	}
+	c.mu.Lock()
+	_, ok := c.slices[control.SliceID]
+	c.mu.Unlock()
+	if !ok {
+		return SliceSchedule{}, fmt.Errorf("切片 %s 不在控制器中", control.SliceID)
+	}
+
+	control, err := c.store.SaveSliceControl(control)
+	if err != nil {
+		return SliceSchedule{}, err
+	}
+	c.mu.Lock()
+	entry := c.setSliceLocked(control, time.Now())
+	schedule := entry.schedule()
+	c.mu.Unlock()
+	c.notify()
+	return schedule, nil
+}
+
+// Reconcile 立即将切片加入控制队列, 已在队列中时不重复加入, 下一次定时控制从当前时间起重新计算
+// 切片需已加入控制器并启用, 且控制器正在运行, reason只用于日志
+func (c *BasicController) Reconcile(sliceID string, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.slices[sliceID]
+	if !ok {
+		return fmt.Errorf("切片 %s 不在控制器中", sliceID)
+	}
+	if !entry.control.Enabled {
+		return fmt.Errorf("切片 %s 未启用控制", sliceID)
+	}
+	if c.queue == nil {
+		return fmt.Errorf("控制器未运行")
+	}
+	c.queue.Add(sliceID)
+	c.timers.set(entry, time.Now().Add(c.intervalLocked(entry.control)))
+	c.notify()
+	slog.Info("立即控制切片", "sliceID", sliceID, "reason", reason)
+	return nil
+}
+
+// setSliceLocked 设置切片并安排下一次控制, 未启用的切片移出队列, 调用方需持有mu
+func (c *BasicController) setSliceLocked(control model.SliceControl, now time.Time) *sliceEntry {
+	entry, ok := c.slices[control.SliceID]
+	if !ok {
+		entry = &sliceEntry{index: -1}
+		c.slices[control.SliceID] = entry
+	}
+	entry.control = control
+	if control.Enabled {
+		c.timers.set(entry, now.Add(c.intervalLocked(control)))
+	} else {
+		c.timers.remove(entry)
+	}
+	return entry
+}
+
+// intervalLocked 切片的控制间隔, 未设置时为全局频率, 调用方需持有mu
+func (c *BasicController) intervalLocked(control model.SliceControl) time.Duration {
+	if control.Interval > 0 {
+		return control.Interval
+	}
+	return c.frequency
 }
 
 // 频率相关
+
+// SetFrequency 设置全局频率, 使用全局频率的切片从当前时间起重新安排
 func (c *BasicController) SetFrequency(duration time.Duration) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	c.frequency = duration
-
-	// 重启
-	if c.running {
-		c.Stop()
-		// 重新启动控制器
-		c.Start()
+	now := time.Now()
+	for _, entry := range c.slices {
+		if entry.control.Enabled && entry.control.Interval == 0 {
+			c.timers.set(entry, now.Add(duration))
+		}
 	}
+	c.mu.Unlock()
+	c.notify()
 }
 
 func (c *BasicController) GetFrequency() time.Duration {
@@ -262,13 +717,11 @@ func (c *BasicController) RegisterStrategy(strategy ...Strategy) {
 	defer c.mu.Unlock()
 	// range定义nil为len=0,不需要进行判断
 	for _, s := range strategy {
-		for _, st := range c.strategies {
-			if st.Name() == s.Name() {
-				slog.Warn("策略已存在, 跳过注册", "策略名称", s.Name())
-				continue
-			}
-			c.strategies = append(c.strategies, s)
+		if slices.ContainsFunc(c.strategies, func(st Strategy) bool { return st.Name() == s.Name() }) {
+			slog.Warn("策略已存在, 跳过注册", "策略名称", s.Name())
+			continue
 		}
+		c.strategies = append(c.strategies, s)
 	}
 }
 
@@ -301,3 +754,33 @@ func (c *BasicController) GetStrategyByName(name string) Strategy {
 	}
 	return nil
 }
+
+// 决策记录相关
+func (c *BasicController) SetRecorder(recorder Recorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recorder = recorder
+}
+
+func (c *BasicController) SetArchiver(archiver SliceArchiver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.archiver = archiver
+}
+
+// archive 应用Play后归档切片版本, 未设置归档时跳过
+func (c *BasicController) archive(sliceID string) {
+	c.mu.Lock()
+	archiver := c.archiver
+	c.mu.Unlock()
+	if archiver != nil {
+		archiver.ArchiveSlice(sliceID)
+	}
+}
+
+// 交付相关
+func (c *BasicController) SetDeliverer(deliverer delivery.Deliverer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deliverer = deliverer
+}
@@ -0,0 +1,326 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slicer/db"
+	"slicer/delivery"
+	"slicer/model"
+	"slicer/util"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// fakeStore 只实现切片控制设置和控制所需的方法
+type fakeStore struct {
+	db.Store
+	controls   map[string]model.SliceControl
+	controlErr error // 非nil时查询控制设置失败
+	slaCalls   atomic.Int32
+	slas       map[string]model.SLA
+	plays      map[string]model.Play
+}
+
+// GetSLABySliceID 没有SLA的切片失败, 用于测试重试
+func (f *fakeStore) GetSLABySliceID(sliceID string) (model.SLA, error) {
+	f.slaCalls.Add(1)
+	sla, ok := f.slas[sliceID]
+	if !ok {
+		return model.SLA{}, fmt.Errorf("SLA不存在")
+	}
+	return sla, nil
+}
+
+func (f *fakeStore) UpdateSLA(sla model.SLA) (model.SLA, error) {
+	return sla, nil
+}
+
+func (f *fakeStore) GetPlayBySliceID(sliceID string) (model.Play, error) {
+	return f.plays[sliceID], nil
+}
+
+func (f *fakeStore) UpdatePlay(play model.Play) (model.Play, error) {
+	f.plays[play.SliceID] = play
+	return play, nil
+}
+
+func (f *fakeStore) GetSliceBySliceID(sliceID string) (model.SliceAndAddress, error) {
+	return model.SliceAndAddress{}, nil
+}
+
+func (f *fakeStore) UpdateSlice(slice model.SliceAndAddress) (model.SliceAndAddress, error) {
+	return slice, nil
+}
+
+func (f *fakeStore) SaveSliceControl(control model.SliceControl) (model.SliceControl, error) {
+	f.controls[control.SliceID] = control
+	return control, nil
+}
+
+func (f *fakeStore) GetSliceControl(sliceID string) (model.SliceControl, error) {
+	if f.controlErr != nil {
+		return model.SliceControl{}, f.controlErr
+	}
+	control, ok := f.controls[sliceID]
+	if !ok {
+		return model.SliceControl{}, fmt.Errorf("查询控制设置失败：%w", mongo.ErrNoDocuments)
+	}
+	return control, nil
+}
+
+func (f *fakeStore) DeleteSliceControl(sliceID string) error {
+	delete(f.controls, sliceID)
+	return nil
+}
+
+func (f *fakeStore) ListSliceControl() ([]model.SliceControl, error) {
+	var controls []model.SliceControl
+	for _, control := range f.controls {
+		controls = append(controls, control)
+	}
+	return controls, nil
+}
+
+// fakeDeliverer 记录交付的Play
+type fakeDeliverer struct {
+	delivery.Deliverer
+	plays []model.Play
+}
+
+func (d *fakeDeliverer) DeliverPlay(slice model.SliceAndAddress, play model.Play) (model.DeliveryStatus, error) {
+	d.plays = append(d.plays, play)
+	return model.DeliveryStatus{}, nil
+}
+
+// fakeArchiver 记录归档的切片
+type fakeArchiver []string
+
+func (a *fakeArchiver) ArchiveSlice(sliceID string) {
+	*a = append(*a, sliceID)
+}
+
+// bareStrategy 只返回带宽, 与不完整的AI输出相同
+type bareStrategy struct{}
+
+func (bareStrategy) Name() string { return "bare" }
+func (bareStrategy) Reconcile(current model.Play, sla model.SLA) (model.Play, error) {
+	return model.Play{Bandwidth: model.BandwidthSpec{Ingress: "200M", Egress: "200M"}}, nil
+}
+
+type namedStrategy string
+
+func (s namedStrategy) Name() string { return string(s) }
+func (s namedStrategy) Reconcile(current model.Play, sla model.SLA) (model.Play, error) {
+	return current, nil
+}
+
+func TestSliceSchedule(t *testing.T) {
+	store := &fakeStore{controls: map[string]model.SliceControl{
+		// 重启前保存的设置
+		"1-000002": {SliceID: "1-000002", Interval: time.Minute, Strategy: "other", Enabled: true},
+	}}
+	c := NewBasicController(util.Config{}, store, nil, namedStrategy("basic"), namedStrategy("other")).(*BasicController)
+	require.Len(t, c.ListStrategy(), 2)
+
+	c.AddSlice("1-000001")
+	c.AddSlice("1-000003")
+	assert.Equal(t, []string{"1-000001", "1-000002", "1-000003"}, c.ListSlices())
+	assert.Equal(t, model.DefaultSliceControl("1-000001"), store.controls["1-000001"])
+
+	// 1-000002 使用自己的间隔, 最先到期
+	wait, ok := c.timers.wait(time.Now())
+	require.True(t, ok)
+	assert.InDelta(t, time.Minute, wait, float64(time.Second))
+
+	_, err := c.UpdateSliceControl(model.SliceControl{SliceID: "1-000001", Interval: time.Second, Enabled: true})
+	assert.Error(t, err, "间隔过小")
+	_, err = c.UpdateSliceControl(model.SliceControl{SliceID: "1-000001", Strategy: "missing", Enabled: true})
+	assert.Error(t, err, "策略不存在")
+	_, err = c.UpdateSliceControl(model.SliceControl{SliceID: "1-000009", Enabled: true})
+	assert.Error(t, err, "切片不在控制器中")
+
+	schedule, err := c.UpdateSliceControl(model.SliceControl{SliceID: "1-000003", Interval: 30 * time.Second, Enabled: true})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), schedule.NextRun, time.Second)
+	assert.Equal(t, 30*time.Second, store.controls["1-000003"].Interval)
+
+	// 停用的切片不再安排, 但仍在控制器中
+	schedule, err = c.UpdateSliceControl(model.SliceControl{SliceID: "1-000002", Interval: time.Minute, Enabled: false})
+	require.NoError(t, err)
+	assert.True(t, schedule.NextRun.IsZero())
+	assert.Len(t, c.timers, 2)
+
+	// 到期顺序按下次控制时间, 到期后按各自间隔重新安排
+	due := c.timers.due(time.Now().Add(time.Hour))
+	require.Len(t, due, 1)
+	assert.Equal(t, "1-000003", due[0].control.SliceID)
+
+	c.SetFrequency(time.Minute)
+	got, _ := c.GetSliceSchedule("1-000001")
+	assert.WithinDuration(t, time.Now().Add(time.Minute), got.NextRun, time.Second)
+
+	c.RemoveSlice("1-000001")
+	assert.NotContains(t, store.controls, "1-000001")
+	assert.Equal(t, []string{"1-000002", "1-000003"}, c.ListSlices())
+	assert.Empty(t, c.timers)
+
+	// 恢复备份后以存储为准
+	store.controls = map[string]model.SliceControl{
+		"1-000003": {SliceID: "1-000003", Interval: time.Minute, Enabled: true},
+		"1-000004": model.DefaultSliceControl("1-000004"),
+	}
+	require.NoError(t, c.Reload())
+	assert.Equal(t, []string{"1-000003", "1-000004"}, c.ListSlices())
+	assert.Len(t, c.timers, 2)
+
+	// 查询失败时使用默认设置, 但不覆盖存储
+	store.controlErr = fmt.Errorf("连接失败")
+	c.AddSlice("1-000005")
+	assert.Contains(t, c.ListSlices(), "1-000005")
+	assert.NotContains(t, store.controls, "1-000005")
+}
+
+func TestWorkqueueRetry(t *testing.T) {
+	store := &fakeStore{controls: map[string]model.SliceControl{}}
+	config := util.Config{ControllerConfig: util.ControllerConfig{ControllerRate: 60000}}
+	c := NewBasicController(config, store, nil, namedStrategy("basic")).(*BasicController)
+	c.AddSlice("1-000001")
+
+	queue := workqueue.NewTypedRateLimitingQueue(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[string](time.Millisecond, 10*time.Millisecond))
+	defer queue.ShutDown()
+
+	// 重复加入的切片只出现一次
+	queue.Add("1-000001")
+	queue.Add("1-000001")
+	assert.Equal(t, 1, queue.Len())
+
+	// 失败后按退避重新加入, 重试maxRetries次后放弃
+	for i := 0; i <= maxRetries; i++ {
+		require.True(t, c.processNext(context.TODO(), queue))
+	}
+	assert.EqualValues(t, maxRetries+1, store.slaCalls.Load())
+	assert.Zero(t, queue.NumRequeues("1-000001"))
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, queue.Len())
+
+	// 移出控制器的切片直接丢弃
+	c.RemoveSlice("1-000001")
+	queue.Add("1-000001")
+	require.True(t, c.processNext(context.TODO(), queue))
+	assert.EqualValues(t, maxRetries+1, store.slaCalls.Load())
+
+	// 停止后可立即重新启动
+	c.Start()
+	c.Stop()
+	c.Start()
+	assert.True(t, c.IsRunning())
+	c.Stop()
+	assert.False(t, c.IsRunning())
+}
+
+func TestReconcile(t *testing.T) {
+	store := &fakeStore{controls: map[string]model.SliceControl{
+		"1-000002": {SliceID: "1-000002", Enabled: false},
+	}}
+	config := util.Config{ControllerConfig: util.ControllerConfig{ControllerRate: 60000, ControllerBackoffBase: time.Hour}}
+	c := NewBasicController(config, store, nil, namedStrategy("basic")).(*BasicController)
+	c.AddSlice("1-000001")
+
+	assert.Error(t, c.Reconcile("1-000001", "test"), "控制器未运行")
+	c.Start()
+	defer c.Stop()
+	assert.Error(t, c.Reconcile("1-000009", "test"), "切片不在控制器中")
+	assert.Error(t, c.Reconcile("1-000002", "test"), "切片未启用控制")
+
+	// 立即控制, 并从当前时间起重新安排下一次控制
+	c.SetFrequency(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	before := time.Now()
+	require.NoError(t, c.Reconcile("1-000001", "test"))
+	assert.Eventually(t, func() bool { return store.slaCalls.Load() == 1 }, time.Second, time.Millisecond)
+	got, _ := c.GetSliceSchedule("1-000001")
+	assert.False(t, got.NextRun.Before(before.Add(time.Minute)))
+}
+
+func TestNotifyStoreReconcile(t *testing.T) {
+	store := &fakeStore{
+		controls: map[string]model.SliceControl{},
+		slas:     map[string]model.SLA{"1-000001": {SliceID: "1-000001"}},
+		plays:    map[string]model.Play{"1-000001": {SliceID: "1-000001"}},
+	}
+	config := util.Config{ControllerConfig: util.ControllerConfig{ControllerRate: 60000}}
+	c := NewBasicController(config, store, nil, bareStrategy{}).(*BasicController)
+	c.SetDeliverer(&fakeDeliverer{})
+	c.AddSlice("1-000001")
+	c.Start()
+	defer c.Stop()
+
+	// 经由NotifyStore写入Play后立即控制一次, 控制器自身写入未包装的存储, 不会再次触发
+	var reasons []string
+	notifyStore := db.NewNotifyStore(store, func(sliceID string, reason string) {
+		reasons = append(reasons, reason)
+		assert.NoError(t, c.Reconcile(sliceID, reason))
+	})
+	_, err := notifyStore.UpdatePlay(model.Play{SliceID: "1-000001", OperatorOwned: []string{model.OwnedBandwidth}})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return store.slaCalls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), store.slaCalls.Load())
+	assert.Equal(t, []string{db.ChangePlayUpdated}, reasons)
+
+	// 运维固定的带宽不被策略修改, 等待worker退出后读取
+	c.mu.Lock()
+	done := c.done
+	c.mu.Unlock()
+	c.Stop()
+	<-done
+	assert.Equal(t, model.BandwidthSpec{}, store.plays["1-000001"].Bandwidth)
+}
+
+func TestControlKeepsFixedPlay(t *testing.T) {
+	current := model.Play{
+		SliceID:    "1-000001",
+		Resources:  model.ResourceSpec{CPURequest: "500m", CPULimit: "1", MemoryRequest: "512Mi", MemoryLimit: "1Gi"},
+		Bandwidth:  model.BandwidthSpec{Ingress: "100M", Egress: "100M"},
+		Priority:   100,
+		AllowRules: []model.NetworkAllowRule{{Name: "lab", NF: "upf"}},
+		NFs:        map[string]model.NFPlay{"upf": {Replicas: 2}},
+	}
+	store := &fakeStore{
+		controls: map[string]model.SliceControl{},
+		slas:     map[string]model.SLA{"1-000001": {}},
+		plays:    map[string]model.Play{"1-000001": current},
+	}
+	c := NewBasicController(util.Config{}, store, nil, bareStrategy{}).(*BasicController)
+	deliverer := &fakeDeliverer{}
+	c.SetDeliverer(deliverer)
+	archiver := &fakeArchiver{}
+	c.SetArchiver(archiver)
+
+	require.NoError(t, c.control(context.TODO(), "1-000001", ""))
+
+	// 策略只调整了带宽, 其余参数沿用当前Play
+	require.Len(t, deliverer.plays, 1)
+	applied := deliverer.plays[0]
+	assert.Equal(t, model.BandwidthSpec{Ingress: "200M", Egress: "200M"}, applied.Bandwidth)
+	assert.Equal(t, current.SliceID, applied.SliceID)
+	assert.Equal(t, current.Resources, applied.Resources)
+	assert.Equal(t, current.Priority, applied.Priority)
+	assert.Equal(t, current.AllowRules, applied.AllowRules)
+	assert.Equal(t, 2, applied.UPFReplicaSlots())
+	assert.Equal(t, applied, store.plays["1-000001"])
+	assert.Equal(t, []string{"1-000001"}, []string(*archiver))
+
+	// 策略返回时控制器已停止, 不再应用
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, c.control(ctx, "1-000001", ""))
+	assert.Len(t, deliverer.plays, 1)
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"container/heap"
+	"slicer/model"
+	"time"
+)
+
+// SliceSchedule 切片的控制设置及下次控制时间
+type SliceSchedule struct {
+	model.SliceControl
+	NextRun time.Time `json:"next_run,omitempty"` // 未启用时为空
+}
+
+// sliceEntry 控制器中的一个切片
+type sliceEntry struct {
+	control model.SliceControl
+	next    time.Time
+	index   int // 在schedule中的位置, 未启用时为-1
+}
+
+// schedule 按下次控制时间排序的最小堆, 只包含启用的切片
+type schedule []*sliceEntry
+
+func (s schedule) Len() int           { return len(s) }
+func (s schedule) Less(i, j int) bool { return s[i].next.Before(s[j].next) }
+func (s schedule) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+	s[i].index = i
+	s[j].index = j
+}
+
+func (s *schedule) Push(x any) {
+	entry := x.(*sliceEntry)
+	entry.index = len(*s)
+	*s = append(*s, entry)
+}
+
+func (s *schedule) Pop() any {
+	old := *s
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	entry.index = -1
+	*s = old[:len(old)-1]
+	return entry
+}
+
+// set 将切片安排在next执行, 已在堆中时调整位置
+func (s *schedule) set(entry *sliceEntry, next time.Time) {
+	entry.next = next
+	if entry.index >= 0 {
+		heap.Fix(s, entry.index)
+		return
+	}
+	heap.Push(s, entry)
+}
+
+// remove 将切片移出堆, 不在堆中时不操作
+func (s *schedule) remove(entry *sliceEntry) {
+	if entry.index >= 0 {
+		heap.Remove(s, entry.index)
+	}
+}
+
+// due 取出所有到期的切片
+func (s *schedule) due(now time.Time) []*sliceEntry {
+	var entries []*sliceEntry
+	for s.Len() > 0 && !(*s)[0].next.After(now) {
+		entries = append(entries, heap.Pop(s).(*sliceEntry))
+	}
+	return entries
+}
+
+// wait 距离最早的切片到期的时间, 没有启用的切片时返回false
+func (s schedule) wait(now time.Time) (time.Duration, bool) {
+	if len(s) == 0 {
+		return 0, false
+	}
+	return s[0].next.Sub(now), true
+}
+
+func (e *sliceEntry) schedule() SliceSchedule {
+	schedule := SliceSchedule{SliceControl: e.control}
+	if e.index >= 0 {
+		schedule.NextRun = e.next
+	}
+	return schedule
+}
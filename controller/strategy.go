@@ -5,8 +5,6 @@ import (
 	"math"
 	"slicer/model"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -57,8 +55,8 @@ func (b *BasicStrategy) Reconcile(current model.Play, sla model.SLA) (model.Play
 
 func (b *BasicStrategy) adjustBandwidth(play *model.Play, metrics UsedMetrics, sla model.SLA) error {
 	// 获取当前配置带宽
-	currentUp := parseBandwidthMbps(play.Bandwidth.Ingress)
-	currentDown := parseBandwidthMbps(play.Bandwidth.Egress)
+	currentUp := play.Bandwidth.Ingress.Mbps()
+	currentDown := play.Bandwidth.Egress.Mbps()
 
 	// 计算峰值需求（P95）
 	p95Up := calculatePercentile(metrics.UpThroughput, 95)
@@ -68,34 +66,25 @@ func (b *BasicStrategy) adjustBandwidth(play *model.Play, metrics UsedMetrics, s
 	switch {
 	case p95Up > currentUp*0.9: // 峰值超过当前带宽90%
 		newUp := math.Ceil(p95Up * 1.2) // 扩容20%并向上取整
-		play.Bandwidth.Ingress = fmt.Sprintf("%.0fM", newUp)
+		play.Bandwidth.Ingress = model.BandwidthFromMbps(newUp)
 	case p95Up < currentUp*0.5: // 长期低负载
 		newUp := math.Max(p95Up*1.1, sla.UpBandwidth) // 最低保持SLA要求
-		play.Bandwidth.Ingress = fmt.Sprintf("%.0fM", newUp)
+		play.Bandwidth.Ingress = model.BandwidthFromMbps(newUp)
 	}
 
 	// 下行带宽调整规则
 	switch {
 	case p95Down > currentDown*0.9:
 		newDown := math.Ceil(p95Down * 1.2)
-		play.Bandwidth.Egress = fmt.Sprintf("%.0fM", newDown)
+		play.Bandwidth.Egress = model.BandwidthFromMbps(newDown)
 	case p95Down < currentDown*0.5:
 		newDown := math.Max(p95Down*1.1, sla.DownBandwidth)
-		play.Bandwidth.Egress = fmt.Sprintf("%.0fM", newDown)
+		play.Bandwidth.Egress = model.BandwidthFromMbps(newDown)
 	}
 
 	return nil
 }
 
-// 带宽解析辅助函数
-func parseBandwidthMbps(bw string) float64 {
-	if strings.HasSuffix(bw, "M") {
-		val, _ := strconv.ParseFloat(strings.TrimSuffix(bw, "M"), 64)
-		return val
-	}
-	return 0
-}
-
 // 百分位数计算（类型安全的实现）
 func calculatePercentile(data []float64, p float64) float64 {
 	if len(data) == 0 {
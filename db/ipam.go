@@ -14,9 +14,13 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// go-ipam的默认命名空间
+const ipamNamespace = "root"
+
 type IPAM struct {
-	config util.Config
-	ipam   ipam.Ipamer
+	config  util.Config
+	ipam    ipam.Ipamer
+	storage ipam.Storage // 用于恢复备份时清空已有前缀
 }
 
 func NewIPAM(config util.Config) (*IPAM, error) {
@@ -54,8 +58,9 @@ func NewIPAM(config util.Config) (*IPAM, error) {
 	}
 	// 保留前两个IP地址
 	ipam := &IPAM{
-		config: config,
-		ipam:   ipamer,
+		config:  config,
+		ipam:    ipamer,
+		storage: storage,
 	}
 	if err := ipam.reserveFirstIPs(ctx, []string{config.N3Network, config.N4Network}); err != nil {
 		return nil, fmt.Errorf("保留IP失败: %w", err)
@@ -110,6 +115,48 @@ func (i *IPAM) AllocateN4Addr() (string, error) {
 	return ip.String(), nil
 }
 
+// ReserveN3Addr 将已在使用的N3地址标记为已分配, 用于接管slicer之外部署的切片
+func (i *IPAM) ReserveN3Addr(addr string) error {
+	if err := i.reserveIP(i.config.N3Network, addr); err != nil {
+		return fmt.Errorf("保留N3地址失败: %w", err)
+	}
+	return nil
+}
+
+// ReserveN4Addr 将已在使用的N4地址标记为已分配
+func (i *IPAM) ReserveN4Addr(addr string) error {
+	if err := i.reserveIP(i.config.N4Network, addr); err != nil {
+		return fmt.Errorf("保留N4地址失败: %w", err)
+	}
+	return nil
+}
+
+// reserveIP 分配指定的地址, 地址的前缀长度需与网络一致, 以便之后按同样的方式释放
+func (i *IPAM) reserveIP(network, addr string) error {
+	prefix, err := netip.ParsePrefix(addr)
+	if err != nil {
+		return fmt.Errorf("无效的IP地址格式: %s", addr)
+	}
+	parent, err := netip.ParsePrefix(network)
+	if err != nil {
+		return fmt.Errorf("解析前缀失败 %s: %w", network, err)
+	}
+	if !parent.Contains(prefix.Addr()) || prefix.Bits() != parent.Bits() {
+		return fmt.Errorf("IP地址 %s 不在网络 %s 范围内", addr, network)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), i.config.IPAMTimeout)
+	defer cancel()
+	ip, err := i.ipam.AcquireSpecificIP(ctx, network, prefix.Addr().String())
+	if err != nil {
+		return fmt.Errorf("%s: %w", addr, err)
+	}
+	if ip == nil {
+		return fmt.Errorf("%s 已被占用", addr)
+	}
+	return nil
+}
+
 // 将ipam库IP类型转换为netip.Prefix类型
 func toNetipPrefix(ip *ipam.IP) (netip.Prefix, error) {
 	// 验证 IP 有效性
@@ -153,6 +200,20 @@ func (i *IPAM) AllocateSessionSubnet() (string, error) {
 	return subnet.Cidr, nil
 }
 
+// ReserveSessionSubnet 将已在使用的会话子网标记为已分配
+func (i *IPAM) ReserveSessionSubnet(subnet string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), i.config.IPAMTimeout)
+	defer cancel()
+
+	if _, _, err := net.ParseCIDR(subnet); err != nil {
+		return fmt.Errorf("子网格式无效: %w", err)
+	}
+	if _, err := i.ipam.AcquireSpecificChildPrefix(ctx, i.config.SessionNetwork, subnet); err != nil {
+		return fmt.Errorf("保留会话子网%s失败: %w", subnet, err)
+	}
+	return nil
+}
+
 // ReleaseN3Addr 释放一个 N3 地址
 func (i *IPAM) ReleaseN3Addr(addr string) error {
 	prefix, err := netip.ParsePrefix(addr)
@@ -231,3 +292,47 @@ func (i *IPAM) ReleaseSessionSubnets(subnets []string) error {
 	}
 	return nil
 }
+
+// Dump 导出所有前缀(含已分配的IP与子网)为JSON字符串
+func (i *IPAM) Dump() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), i.config.IPAMTimeout)
+	defer cancel()
+
+	dump, err := i.ipam.Dump(ctx)
+	if err != nil {
+		return "", fmt.Errorf("导出IPAM失败: %w", err)
+	}
+	return dump, nil
+}
+
+// Load 导入Dump生成的JSON字符串, 会替换现有的所有前缀
+// 先在内存中解析校验导出内容, 再清空存储; 导入失败时重新导入恢复前的前缀
+func (i *IPAM) Load(dump string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), i.config.IPAMTimeout)
+	defer cancel()
+
+	if err := ipam.New(ctx).Load(ctx, dump); err != nil {
+		return fmt.Errorf("解析IPAM导出内容失败: %w", err)
+	}
+	previous, err := i.ipam.Dump(ctx)
+	if err != nil {
+		return fmt.Errorf("导出恢复前的IPAM失败: %w", err)
+	}
+
+	// go-ipam要求导入前存储为空
+	if err := i.replace(ctx, dump); err != nil {
+		if rollbackErr := i.replace(ctx, previous); rollbackErr != nil {
+			return fmt.Errorf("导入IPAM失败: %w, 且恢复原有前缀失败: %v", err, rollbackErr)
+		}
+		return fmt.Errorf("导入IPAM失败, 已恢复原有前缀: %w", err)
+	}
+	return nil
+}
+
+// replace 清空存储后导入前缀
+func (i *IPAM) replace(ctx context.Context, dump string) error {
+	if err := i.storage.DeleteAllPrefixes(ctx, ipamNamespace); err != nil {
+		return fmt.Errorf("清空IPAM失败: %w", err)
+	}
+	return i.ipam.Load(ctx, dump)
+}
@@ -50,8 +50,9 @@ func newTestIPAM(t *testing.T, config util.Config) *IPAM {
 	}
 
 	return &IPAM{
-		config: config,
-		ipam:   ipamer,
+		config:  config,
+		ipam:    ipamer,
+		storage: storage,
 	}
 }
 
@@ -107,3 +108,43 @@ func TestReleaseSessionSubnets(t *testing.T) {
 	err := i.ReleaseSessionSubnets([]string{s1, s2})
 	require.NoError(t, err)
 }
+
+func TestDumpAndLoad(t *testing.T) {
+	i := newTestIPAM(t, testConfig)
+
+	// 分配后导出
+	subnet, err := i.AllocateSessionSubnet()
+	require.NoError(t, err)
+	dump, err := i.Dump()
+	require.NoError(t, err)
+	require.Contains(t, dump, subnet)
+
+	// 释放后再导入, 子网应重新处于已分配状态
+	require.NoError(t, i.ReleaseSessionSubnet(subnet))
+	require.NoError(t, i.Load(dump))
+
+	// 无法解析的导出内容不会清空现有前缀
+	require.Error(t, i.Load("not json"))
+	err = i.ReleaseSessionSubnet(subnet)
+	require.NoError(t, err)
+}
+
+func TestReserveAddr(t *testing.T) {
+	i := newTestIPAM(t, testConfig)
+
+	require.NoError(t, i.ReserveN3Addr("10.10.3.20/24"))
+	require.Error(t, i.ReserveN3Addr("10.10.3.20/24"), "重复保留")
+	require.Error(t, i.ReserveN3Addr("10.10.4.20/24"), "不在N3网络")
+	require.Error(t, i.ReserveN4Addr("10.10.4.20/16"), "前缀长度不一致")
+	require.NoError(t, i.ReserveSessionSubnet("10.41.0.0/16"))
+	require.Error(t, i.ReserveSessionSubnet("10.41.0.0/16"))
+
+	// 保留的地址不会再被分配, 且可按原方式释放
+	for range 3 {
+		ip, err := i.AllocateN3Addr()
+		require.NoError(t, err)
+		require.NotEqual(t, "10.10.3.20/24", ip)
+	}
+	require.NoError(t, i.ReleaseN3Addr("10.10.3.20/24"))
+	require.NoError(t, i.ReleaseSessionSubnet("10.41.0.0/16"))
+}
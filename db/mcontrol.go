@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slicer/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Querier 接口实现
+
+// SaveSliceControl 按切片ID创建或更新控制设置
+func (m *MongoDB) SaveSliceControl(control model.SliceControl) (model.SliceControl, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	// SliceID字段使用默认的bson键名, 更新时不修改_id
+	control.ID = primitive.NilObjectID
+	res, err := m.client.Database(m.database).Collection(m.config.ControlStoreName).UpdateOne(ctx,
+		primitive.M{"sliceid": control.SliceID}, bson.M{"$set": control}, options.Update().SetUpsert(true))
+	if err != nil {
+		return control, fmt.Errorf("保存控制设置失败：%w", err)
+	}
+	if id, ok := res.UpsertedID.(primitive.ObjectID); ok {
+		control.ID = id
+		return control, nil
+	}
+	return m.GetSliceControl(control.SliceID)
+}
+
+func (m *MongoDB) GetSliceControl(sliceID string) (model.SliceControl, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	res := m.client.Database(m.database).Collection(m.config.ControlStoreName).FindOne(ctx, primitive.M{"sliceid": sliceID})
+	var control model.SliceControl
+	if err := res.Decode(&control); err != nil {
+		return control, fmt.Errorf("查询控制设置失败：%w", err)
+	}
+	return control, nil
+}
+
+func (m *MongoDB) DeleteSliceControl(sliceID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	if _, err := m.client.Database(m.database).Collection(m.config.ControlStoreName).DeleteOne(ctx, primitive.M{"sliceid": sliceID}); err != nil {
+		return fmt.Errorf("删除控制设置失败：%w", err)
+	}
+	return nil
+}
+
+func (m *MongoDB) ListSliceControl() ([]model.SliceControl, error) {
+	cursor, err := m.findAll(m.config.ControlStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("查询控制设置失败：%w", err)
+	}
+
+	defer cursor.Close(context.Background())
+	var controls []model.SliceControl
+	for cursor.Next(context.Background()) {
+		var control model.SliceControl
+		if err = cursor.Decode(&control); err != nil {
+			return nil, fmt.Errorf("查询控制设置失败：%w", err)
+		}
+		controls = append(controls, control)
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, fmt.Errorf("查询控制设置失败：%w", err)
+	}
+	return controls, nil
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Dumper 接口实现
+
+// 需要备份的集合
+func (m *MongoDB) collections() []string {
+	return []string{
+		m.config.SliceStoreName,
+		m.config.KubeStoreName,
+		m.config.MonitorStoreName,
+		m.config.PlayStoreName,
+		m.config.SLAStoreName,
+		m.config.ControlStoreName,
+	}
+}
+
+func (m *MongoDB) DumpCollections() (map[string][]byte, error) {
+	dump := make(map[string][]byte)
+	for _, collection := range m.collections() {
+		cursor, err := m.findAll(collection)
+		if err != nil {
+			return nil, fmt.Errorf("导出集合%s失败：%w", collection, err)
+		}
+
+		var docs []bson.Raw
+		if err := cursor.All(context.Background(), &docs); err != nil {
+			return nil, fmt.Errorf("导出集合%s失败：%w", collection, err)
+		}
+
+		// 使用canonical扩展JSON, 保证ObjectID等类型可以无损恢复
+		data, err := bson.MarshalExtJSON(bson.M{"docs": docs}, true, false)
+		if err != nil {
+			return nil, fmt.Errorf("序列化集合%s失败：%w", collection, err)
+		}
+		dump[collection] = data
+	}
+	return dump, nil
+}
+
+// restoreSuffix 恢复时暂存集合的后缀, originalSuffix 替换期间保留原集合的后缀
+const (
+	restoreSuffix  = "_restore"
+	originalSuffix = "_prerestore"
+)
+
+// RestoreCollections 先将所有集合导入暂存集合, 全部成功后再逐个替换原集合
+// 解析或导入失败时删除暂存集合, 原集合保持不变
+// 替换时原集合先重命名为保留集合, 任一集合替换失败时将已替换的集合全部回滚, 全部成功后删除保留集合
+func (m *MongoDB) RestoreCollections(dump map[string][]byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	database := m.client.Database(m.database)
+	staged := make([]string, 0, len(dump))
+	// 尽力删除暂存集合, 遗留的暂存集合会在下次恢复时清除
+	dropStaged := func() {
+		for _, collection := range staged {
+			_ = database.Collection(collection + restoreSuffix).Drop(ctx)
+		}
+	}
+
+	for collection, data := range dump {
+		var wrapped struct {
+			Docs []bson.Raw `bson:"docs"`
+		}
+		if err := bson.UnmarshalExtJSON(data, true, &wrapped); err != nil {
+			dropStaged()
+			return fmt.Errorf("解析集合%s失败：%w", collection, err)
+		}
+
+		// 清除上次失败遗留的暂存集合, 并显式创建, 保证空集合也可以重命名
+		tmp := database.Collection(collection + restoreSuffix)
+		if err := tmp.Drop(ctx); err != nil {
+			dropStaged()
+			return fmt.Errorf("清空暂存集合%s失败：%w", tmp.Name(), err)
+		}
+		staged = append(staged, collection)
+		if err := database.CreateCollection(ctx, tmp.Name()); err != nil {
+			dropStaged()
+			return fmt.Errorf("创建暂存集合%s失败：%w", tmp.Name(), err)
+		}
+		if len(wrapped.Docs) == 0 {
+			continue
+		}
+		docs := make([]any, len(wrapped.Docs))
+		for i, doc := range wrapped.Docs {
+			docs[i] = doc
+		}
+		if _, err := tmp.InsertMany(ctx, docs); err != nil {
+			dropStaged()
+			return fmt.Errorf("导入集合%s失败：%w", collection, err)
+		}
+	}
+
+	// 重命名不存在的集合会失败, 原本不存在的集合替换时无需保留, 回滚时直接删除
+	names, err := database.ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		dropStaged()
+		return fmt.Errorf("列出集合失败：%w", err)
+	}
+	existed := make(map[string]bool, len(names))
+	for _, name := range names {
+		existed[name] = true
+	}
+
+	// 已替换的集合, 回滚时按相反顺序恢复原集合, 返回未能回滚的集合
+	var swapped []string
+	rollback := func() []string {
+		var failed []string
+		for i := len(swapped) - 1; i >= 0; i-- {
+			collection := swapped[i]
+			var err error
+			if existed[collection] {
+				err = m.renameCollection(ctx, collection+originalSuffix, collection)
+			} else {
+				err = database.Collection(collection).Drop(ctx)
+			}
+			if err != nil {
+				failed = append(failed, collection)
+			}
+		}
+		return failed
+	}
+
+	// 重命名是单个集合上的原子操作
+	for _, collection := range staged {
+		if existed[collection] {
+			if err := m.renameCollection(ctx, collection, collection+originalSuffix); err != nil {
+				err = fmt.Errorf("保留原集合%s失败：%w", collection, err)
+				return m.restoreFailed(err, rollback(), dropStaged)
+			}
+		}
+		// 原集合已移走, 即使替换失败也需要回滚
+		swapped = append(swapped, collection)
+		if err := m.renameCollection(ctx, collection+restoreSuffix, collection); err != nil {
+			err = fmt.Errorf("替换集合%s失败：%w", collection, err)
+			return m.restoreFailed(err, rollback(), dropStaged)
+		}
+	}
+
+	// 全部替换成功, 删除保留的原集合
+	for _, collection := range swapped {
+		if existed[collection] {
+			_ = database.Collection(collection + originalSuffix).Drop(ctx)
+		}
+	}
+	return nil
+}
+
+// restoreFailed 替换失败并回滚后的错误, 未能回滚的集合需手工从保留集合恢复
+func (m *MongoDB) restoreFailed(err error, failed []string, dropStaged func()) error {
+	dropStaged()
+	if len(failed) > 0 {
+		return fmt.Errorf("%w; 集合%v未能回滚, 数据库处于部分恢复状态, 原数据保留在%s后缀的集合中", err, failed, originalSuffix)
+	}
+	return fmt.Errorf("%w; 已回滚, 数据库保持恢复前的状态", err)
+}
+
+// renameCollection 重命名集合, 覆盖已存在的目标集合
+func (m *MongoDB) renameCollection(ctx context.Context, from, to string) error {
+	return m.client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "renameCollection", Value: m.database + "." + from},
+		{Key: "to", Value: m.database + "." + to},
+		{Key: "dropTarget", Value: true},
+	}).Err()
+}
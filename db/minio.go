@@ -8,11 +8,15 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// 确保MinioOSS实现了OSS接口
+var _ OSS = (*MinioOSS)(nil)
+
 type OSS interface {
 	UploadFile(bucketName string, objectName string, filePath string) error
 	DownloadFile(bucketName string, objectName string, filePath string) error
@@ -21,6 +25,15 @@ type OSS interface {
 	Upload(bucketName string, objectName string, reader io.Reader) error
 	Download(bucketName string, objectName string, writer io.Writer) error
 	Delete(bucketName string, objectName string) error
+	List(bucketName string, prefix string) ([]ObjectInfo, error)
+	EnsureBucket(bucketName string) error
+}
+
+// ObjectInfo 对象存储中对象的基本信息
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
 }
 
 type MinioOSS struct {
@@ -192,3 +205,39 @@ func (m *MinioOSS) Delete(bucketName, objectName string) error {
 	}
 	return err
 }
+
+// List 列出bucket中指定前缀的所有对象(递归), 按key排序
+func (m *MinioOSS) List(bucketName, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for obj := range m.client.ListObjects(context.Background(), bucketName, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			log.Printf("Failed to list objects: %v", obj.Err)
+			return nil, obj.Err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          obj.Key,
+			Size:         obj.Size,
+			LastModified: obj.LastModified,
+		})
+	}
+	return objects, nil
+}
+
+// EnsureBucket 若bucket不存在则创建, 并开启版本控制
+func (m *MinioOSS) EnsureBucket(bucketName string) error {
+	ctx := context.Background()
+	exists, err := m.client.BucketExists(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := m.client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{}); err != nil {
+			return err
+		}
+	}
+	// 开启版本控制, 同一key的多次写入均会被保留
+	return m.client.EnableVersioning(ctx, bucketName)
+}
@@ -35,19 +35,14 @@ func (m *MongoDB) GetPlay(id string) (model.Play, error) {
 	return play, nil
 }
 
+// GetPlayBySliceID 按切片ID查询Play
+// Play文档中切片ID保存在顶层的sliceid字段, 此前按 slice.sst/slice.sd 查询的字段并不存在, 因此总是查不到
 func (m *MongoDB) GetPlayBySliceID(sliceID string) (model.Play, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
 	defer cancel()
 
-	var sst int
-	var sd string
-	_, err := fmt.Sscanf(sliceID, "%d-%s", &sst, &sd)
-	if err != nil {
-		return model.Play{}, fmt.Errorf("PlayID格式错误：%w", err)
-	}
-
-	// 查询 Play
-	res := m.client.Database(m.database).Collection(m.config.PlayStoreName).FindOne(ctx, primitive.M{"slice.sst": sst, "slice.sd": sd})
+	// 查询 Play, SliceID字段没有bson标签, 使用默认键名sliceid
+	res := m.client.Database(m.database).Collection(m.config.PlayStoreName).FindOne(ctx, primitive.M{"sliceid": sliceID})
 	var play model.Play
 	if err := res.Decode(&play); err != nil {
 		return play, fmt.Errorf("查询Play失败：%w", err)
@@ -78,14 +73,12 @@ func (m *MongoDB) ListPlay() ([]model.Play, error) {
 	return plays, nil
 }
 
+// UpdatePlay 按ID更新Play, 返回的Play与传入的相同
+// 按ID更新时不会插入文档, UpsertedID总是nil, 因此不能从中获取ID(此前的类型断言会panic)
 func (m *MongoDB) UpdatePlay(play model.Play) (model.Play, error) {
 	// 更新Play
-	res, err := m.update(m.config.PlayStoreName, play.ID, play)
-	if err != nil {
+	if _, err := m.update(m.config.PlayStoreName, play.ID, play); err != nil {
 		return play, fmt.Errorf("更新Play失败：%w", err)
 	}
-
-	// 获取更新的ID
-	play.ID = res.UpsertedID.(primitive.ObjectID)
 	return play, nil
 }
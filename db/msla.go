@@ -35,19 +35,14 @@ func (m *MongoDB) GetSLA(id string) (model.SLA, error) {
 	return sla, nil
 }
 
+// GetSLABySliceID 按切片ID查询SLA
+// SLA文档中切片ID保存在顶层的sliceid字段, 此前按 slice.sst/slice.sd 查询的字段并不存在, 因此总是查不到
 func (m *MongoDB) GetSLABySliceID(sliceID string) (model.SLA, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
 	defer cancel()
 
-	var sst int
-	var sd string
-	_, err := fmt.Sscanf(sliceID, "%d-%s", &sst, &sd)
-	if err != nil {
-		return model.SLA{}, fmt.Errorf("SLAID格式错误：%w", err)
-	}
-
-	// 查询 SLA
-	res := m.client.Database(m.database).Collection(m.config.SLAStoreName).FindOne(ctx, primitive.M{"slice.sst": sst, "slice.sd": sd})
+	// 查询 SLA, SliceID字段没有bson标签, 使用默认键名sliceid
+	res := m.client.Database(m.database).Collection(m.config.SLAStoreName).FindOne(ctx, primitive.M{"sliceid": sliceID})
 	var sla model.SLA
 	if err := res.Decode(&sla); err != nil {
 		return sla, fmt.Errorf("查询SLA失败：%w", err)
@@ -78,14 +73,12 @@ func (m *MongoDB) ListSLA() ([]model.SLA, error) {
 	return slas, nil
 }
 
+// UpdateSLA 按ID更新SLA, 返回的SLA与传入的相同
+// 按ID更新时不会插入文档, UpsertedID总是nil, 因此不能从中获取ID(此前的类型断言会panic)
 func (m *MongoDB) UpdateSLA(sla model.SLA) (model.SLA, error) {
 	// 更新SLA
-	res, err := m.update(m.config.SLAStoreName, sla.ID, sla)
-	if err != nil {
+	if _, err := m.update(m.config.SLAStoreName, sla.ID, sla); err != nil {
 		return sla, fmt.Errorf("更新SLA失败：%w", err)
 	}
-
-	// 获取更新的ID
-	sla.ID = res.UpsertedID.(primitive.ObjectID)
 	return sla, nil
 }
@@ -56,6 +56,14 @@ func (m *MongoDB) GetSliceBySliceID(sliceID string) (model.SliceAndAddress, erro
 	return slice, nil
 }
 
+func (m *MongoDB) UpdateSlice(slice model.SliceAndAddress) (model.SliceAndAddress, error) {
+	// 按ID更新, 不会插入新文档, ID保持不变
+	if _, err := m.update(m.config.SliceStoreName, slice.ID, slice); err != nil {
+		return slice, fmt.Errorf("更新Slice失败：%w", err)
+	}
+	return slice, nil
+}
+
 func (m *MongoDB) ListSlice() ([]model.SliceAndAddress, error) {
 	// 获取所有 Slice
 	cursor, err := m.findAll(m.config.SliceStoreName)
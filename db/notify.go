@@ -0,0 +1,64 @@
+package db
+
+import "slicer/model"
+
+// 存储中切片的SLA或Play发生的变化, 作为ChangeHook的reason
+const (
+	ChangeSLACreated  = "sla created"
+	ChangeSLAUpdated  = "sla updated"
+	ChangePlayCreated = "play created"
+	ChangePlayUpdated = "play updated"
+)
+
+// ChangeHook 切片的SLA或Play写入成功后调用, 不能阻塞
+type ChangeHook func(sliceID string, reason string)
+
+// NotifyStore 在SLA和Play写入成功后调用hook, 经由它写入的所有入口都能通知控制器, 而不需要各自触发
+// 其余操作直接交给内部的Store; 控制器自身应使用内部的Store写入, 否则每次控制的结果都会再次触发控制
+type NotifyStore struct {
+	Store
+	hook ChangeHook
+}
+
+// NewNotifyStore 包装store, hook为nil时与store相同
+func NewNotifyStore(store Store, hook ChangeHook) *NotifyStore {
+	return &NotifyStore{Store: store, hook: hook}
+}
+
+func (s *NotifyStore) CreateSLA(sla model.SLA) (model.SLA, error) {
+	sla, err := s.Store.CreateSLA(sla)
+	if err == nil {
+		s.notify(sla.SliceID, ChangeSLACreated)
+	}
+	return sla, err
+}
+
+func (s *NotifyStore) UpdateSLA(sla model.SLA) (model.SLA, error) {
+	sla, err := s.Store.UpdateSLA(sla)
+	if err == nil {
+		s.notify(sla.SliceID, ChangeSLAUpdated)
+	}
+	return sla, err
+}
+
+func (s *NotifyStore) CreatePlay(play model.Play) (model.Play, error) {
+	play, err := s.Store.CreatePlay(play)
+	if err == nil {
+		s.notify(play.SliceID, ChangePlayCreated)
+	}
+	return play, err
+}
+
+func (s *NotifyStore) UpdatePlay(play model.Play) (model.Play, error) {
+	play, err := s.Store.UpdatePlay(play)
+	if err == nil {
+		s.notify(play.SliceID, ChangePlayUpdated)
+	}
+	return play, err
+}
+
+func (s *NotifyStore) notify(sliceID string, reason string) {
+	if s.hook != nil && sliceID != "" {
+		s.hook(sliceID, reason)
+	}
+}
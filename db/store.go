@@ -4,6 +4,15 @@ import "slicer/model"
 
 type Store interface {
 	Querier
+	Dumper
+}
+
+// Dumper 用于备份与恢复, 以MongoDB扩展JSON格式导出/导入所有集合
+type Dumper interface {
+	// 导出所有集合, key为集合名称, value为该集合所有文档组成的JSON数组
+	DumpCollections() (map[string][]byte, error)
+	// 导入集合并覆盖原集合, 格式与DumpCollections一致, 导入失败时原集合不变
+	RestoreCollections(dump map[string][]byte) error
 }
 
 type Querier interface {
@@ -14,6 +23,7 @@ type Querier interface {
 	GetSliceBySliceID(sliceID string) (model.SliceAndAddress, error)
 	ListSlice() ([]model.SliceAndAddress, error)
 	ListSliceID() ([]string, error)
+	UpdateSlice(slice model.SliceAndAddress) (model.SliceAndAddress, error)
 
 	// monitor
 	CreateMonitor(monitor model.Monitor) (model.Monitor, error)
@@ -36,4 +46,10 @@ type Querier interface {
 	GetSLABySliceID(sliceID string) (model.SLA, error)
 	ListSLA() ([]model.SLA, error)
 	UpdateSLA(sla model.SLA) (model.SLA, error)
+
+	// 控制器的切片设置, 按切片ID存取
+	SaveSliceControl(control model.SliceControl) (model.SliceControl, error)
+	GetSliceControl(sliceID string) (model.SliceControl, error)
+	DeleteSliceControl(sliceID string) error
+	ListSliceControl() ([]model.SliceControl, error)
 }
@@ -0,0 +1,137 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"slicer/kubeclient"
+	"slicer/model"
+	"slicer/render"
+	"slicer/util"
+	"time"
+)
+
+// 交付方式
+const (
+	ModeApply = "apply" // 直接应用到集群
+	ModeGit   = "git"   // 提交到git仓库, 由Argo/Flux等同步到集群
+)
+
+// Deliverer 将切片及其Play交付到集群
+type Deliverer interface {
+	// Mode 交付方式
+	Mode() string
+	// DeliverSlice 交付切片资源, play为切片当前或即将交付的Play, 没有Play时为nil
+	// git方式将其与切片一并提交, 使每个提交都是完整的期望状态; apply方式忽略, Play由DeliverPlay应用
+	DeliverSlice(slice model.SliceAndAddress, play *model.Play) (model.DeliveryStatus, error)
+	// DeliverPlay 交付切片的Play
+	DeliverPlay(slice model.SliceAndAddress, play model.Play) (model.DeliveryStatus, error)
+	// RemoveSlice 删除切片资源
+	RemoveSlice(slice model.SliceAndAddress) (model.DeliveryStatus, error)
+}
+
+// IsRolloutError 判断交付错误是否为资源已应用但未就绪
+// 此时集群中已有新的资源, 调用方需决定保留还是回滚
+func IsRolloutError(err error) bool {
+	var rolloutErr *kubeclient.RolloutError
+	return errors.As(err, &rolloutErr)
+}
+
+// NewDeliverer 根据配置的DeliveryMode创建交付方式, 默认为apply
+func NewDeliverer(config util.Config, render *render.Render, clusters *kubeclient.Clusters) (Deliverer, error) {
+	switch config.DeliveryMode {
+	case "", ModeApply:
+		return NewApplyDeliverer(config, render, clusters), nil
+	case ModeGit:
+		return NewGitDeliverer(config, render)
+	default:
+		return nil, fmt.Errorf("不支持的交付方式: %s", config.DeliveryMode)
+	}
+}
+
+// ApplyDeliverer 通过kubeclient直接应用到切片所在的集群
+type ApplyDeliverer struct {
+	config   util.Config
+	render   *render.Render
+	clusters *kubeclient.Clusters
+}
+
+func NewApplyDeliverer(config util.Config, render *render.Render, clusters *kubeclient.Clusters) *ApplyDeliverer {
+	return &ApplyDeliverer{
+		config:   config,
+		render:   render,
+		clusters: clusters,
+	}
+}
+
+func (d *ApplyDeliverer) Mode() string {
+	return ModeApply
+}
+
+func (d *ApplyDeliverer) DeliverSlice(slice model.SliceAndAddress, _ *model.Play) (model.DeliveryStatus, error) {
+	contents, err := d.render.RenderSlice(slice)
+	if err != nil {
+		return model.DeliveryStatus{}, fmt.Errorf("渲染slice失败: %w", err)
+	}
+	kclient, err := d.clusters.ForSlice(slice)
+	if err != nil {
+		return model.DeliveryStatus{}, err
+	}
+	if err := kclient.ApplySlice(contents); err != nil {
+		return model.DeliveryStatus{}, fmt.Errorf("应用kube资源失败: %w", err)
+	}
+	return d.wait(slice)
+}
+
+func (d *ApplyDeliverer) DeliverPlay(slice model.SliceAndAddress, play model.Play) (model.DeliveryStatus, error) {
+	kclient, err := d.clusters.ForSlice(slice)
+	if err != nil {
+		return model.DeliveryStatus{}, err
+	}
+	report, err := kclient.Play(play, d.config.SliceNamespace(play.SliceID))
+	if err != nil {
+		status := d.status()
+		status.Play = &report
+		return status, fmt.Errorf("部署play失败: %w", err)
+	}
+	status, err := d.wait(slice)
+	status.Play = &report
+	return status, err
+}
+
+func (d *ApplyDeliverer) RemoveSlice(slice model.SliceAndAddress) (model.DeliveryStatus, error) {
+	contents, err := d.render.RenderSlice(slice)
+	if err != nil {
+		return model.DeliveryStatus{}, fmt.Errorf("渲染slice失败: %w", err)
+	}
+	kclient, err := d.clusters.ForSlice(slice)
+	if err != nil {
+		return model.DeliveryStatus{}, err
+	}
+	if err := kclient.DeleteSlice(contents); err != nil {
+		return model.DeliveryStatus{}, fmt.Errorf("删除kube资源失败: %w", err)
+	}
+	return d.status(), nil
+}
+
+// wait 设置了ROLLOUT_TIMEOUT时等待切片就绪, 未就绪时返回的状态中包含失败原因, 错误为*kubeclient.RolloutError
+func (d *ApplyDeliverer) wait(slice model.SliceAndAddress) (model.DeliveryStatus, error) {
+	status := d.status()
+	if d.config.RolloutTimeout <= 0 {
+		return status, nil
+	}
+	kclient, err := d.clusters.ForSlice(slice)
+	if err != nil {
+		return status, err
+	}
+	rollout, err := kclient.WaitForSlice(slice.SliceID(), d.config.RolloutTimeout)
+	status.Rollout = &rollout
+	if err != nil {
+		status.Message = err.Error()
+		return status, err
+	}
+	return status, nil
+}
+
+func (d *ApplyDeliverer) status() model.DeliveryStatus {
+	return model.DeliveryStatus{Mode: ModeApply, UpdatedAt: time.Now()}
+}
@@ -0,0 +1,240 @@
+package delivery
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slicer/kubeclient"
+	"slicer/model"
+	"slicer/render"
+	"slicer/util"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitDeliverer 将切片的Kustomize目录提交到本地git仓库
+// 目录结构为 <GitSubdir>/slice-<id>/{base,overlays/<namespace>}, Play以overlay中的patch表示
+type GitDeliverer struct {
+	mu sync.Mutex // git操作需串行
+
+	config util.Config
+	render *render.Render
+
+	repo   string
+	branch string
+	subdir string
+	name   string
+	email  string
+}
+
+func NewGitDeliverer(config util.Config, render *render.Render) (*GitDeliverer, error) {
+	if config.GitRepoPath == "" {
+		return nil, fmt.Errorf("git交付方式需要设置GIT_REPO_PATH")
+	}
+	d := &GitDeliverer{
+		config: config,
+		render: render,
+		repo:   config.GitRepoPath,
+		branch: orDefault(config.GitBranch, "main"),
+		subdir: orDefault(config.GitSubdir, "slices"),
+		name:   orDefault(config.GitAuthorName, "slicer"),
+		email:  orDefault(config.GitAuthorEmail, "slicer@localhost"),
+	}
+
+	if _, err := d.git("rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("%s 不是git仓库: %w", d.repo, err)
+	}
+	if err := d.checkoutBranch(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *GitDeliverer) Mode() string {
+	return ModeGit
+}
+
+func (d *GitDeliverer) DeliverSlice(slice model.SliceAndAddress, play *model.Play) (model.DeliveryStatus, error) {
+	msg := fmt.Sprintf("slice(%s): 部署切片\n\nSST: %d\nSD: %s\nDNN: %s\nUPF N3/N4: %s %s\nSMF N3/N4: %s %s",
+		slice.SliceID(), slice.SST, slice.SD, strings.Join(dnns(slice), ", "),
+		slice.UPFN3Addr, slice.UPFN4Addr, slice.SMFN3Addr, slice.SMFN4Addr)
+	if play != nil {
+		msg += "\n包含切片的Play"
+	}
+	return d.write(slice, play, msg)
+}
+
+func (d *GitDeliverer) DeliverPlay(slice model.SliceAndAddress, play model.Play) (model.DeliveryStatus, error) {
+	msg := fmt.Sprintf("play(%s): 更新Play\n\nCPU: %s/%s\n内存: %s/%s\n带宽: ingress %s, egress %s\n优先级: %d",
+		slice.SliceID(),
+		play.Resources.CPURequest, play.Resources.CPULimit,
+		play.Resources.MemoryRequest, play.Resources.MemoryLimit,
+		play.Bandwidth.Ingress, play.Bandwidth.Egress, play.Priority)
+	sections := play.Sections()
+	for _, nf := range play.SectionNames() {
+		if _, ok := play.NFs[nf]; !ok {
+			continue
+		}
+		s := sections[nf]
+		msg += fmt.Sprintf("\n%s: CPU %s/%s, 内存 %s/%s, 带宽 %s/%s, 优先级 %d", nf,
+			s.Resources.CPURequest, s.Resources.CPULimit,
+			s.Resources.MemoryRequest, s.Resources.MemoryLimit,
+			s.Bandwidth.Ingress, s.Bandwidth.Egress, s.Priority)
+	}
+	return d.write(slice, &play, msg)
+}
+
+func (d *GitDeliverer) RemoveSlice(slice model.SliceAndAddress) (model.DeliveryStatus, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dir := d.sliceDir(slice)
+	if err := os.RemoveAll(filepath.Join(d.repo, dir)); err != nil {
+		return model.DeliveryStatus{}, fmt.Errorf("删除切片目录失败: %w", err)
+	}
+	return d.commit(dir, fmt.Sprintf("slice(%s): 删除切片", slice.SliceID()))
+}
+
+// write 重新生成切片目录并提交, 目录中不再渲染的文件会被删除
+func (d *GitDeliverer) write(slice model.SliceAndAddress, play *model.Play, msg string) (model.DeliveryStatus, error) {
+	// 与直接应用相同的预检, 避免把无法应用的资源提交给Argo/Flux
+	contents, err := d.render.RenderSlice(slice)
+	if err != nil {
+		return model.DeliveryStatus{}, fmt.Errorf("渲染slice失败: %w", err)
+	}
+	if err := kubeclient.ValidateSlice(contents); err != nil {
+		return model.DeliveryStatus{}, err
+	}
+
+	files, err := d.render.RenderKustomize(slice, play)
+	if err != nil {
+		return model.DeliveryStatus{}, fmt.Errorf("渲染slice失败: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dir := d.sliceDir(slice)
+	if err := os.RemoveAll(filepath.Join(d.repo, dir)); err != nil {
+		return model.DeliveryStatus{}, fmt.Errorf("清理切片目录失败: %w", err)
+	}
+	for name, content := range files {
+		path := filepath.Join(d.repo, d.clusterDir(slice), filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return model.DeliveryStatus{}, fmt.Errorf("创建目录失败: %w", err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return model.DeliveryStatus{}, fmt.Errorf("写入文件 %s 失败: %w", path, err)
+		}
+	}
+	return d.commit(dir, msg)
+}
+
+// commit 提交dir下的变化, 无变化时不提交, 返回HEAD的SHA
+func (d *GitDeliverer) commit(dir, msg string) (model.DeliveryStatus, error) {
+	// 目录从未提交且已不存在时(如启用git交付前创建的切片, 或切片迁移到其他集群的目录)没有可提交的内容
+	// 此时 git add 会因pathspec不匹配而失败
+	tracked, err := d.git("ls-files", "--", dir)
+	if err != nil {
+		return model.DeliveryStatus{}, err
+	}
+	_, statErr := os.Stat(filepath.Join(d.repo, dir))
+	if tracked == "" && os.IsNotExist(statErr) {
+		slog.Debug("切片目录未提交过, 跳过提交", "dir", dir)
+	} else if err := d.commitDir(dir, msg); err != nil {
+		return model.DeliveryStatus{}, err
+	}
+
+	// 仓库中还没有任何提交时版本为空
+	sha, _ := d.git("rev-parse", "--verify", "--quiet", "HEAD")
+	return model.DeliveryStatus{
+		Mode:      ModeGit,
+		Revision:  sha,
+		Message:   strings.SplitN(msg, "\n", 2)[0],
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+// commitDir 暂存并提交dir下的变化, 无变化时不提交, 设置了远程仓库时推送
+func (d *GitDeliverer) commitDir(dir, msg string) error {
+	if _, err := d.git("add", "-A", "--", dir); err != nil {
+		return err
+	}
+
+	// 有暂存的变化时 diff --quiet 返回非0
+	if _, err := d.git("diff", "--cached", "--quiet", "--", dir); err == nil {
+		slog.Debug("切片目录无变化, 跳过提交", "dir", dir)
+		return nil
+	}
+	if _, err := d.git("commit", "-q", "-m", msg, "--", dir); err != nil {
+		return err
+	}
+	if d.config.GitRemote != "" {
+		if _, err := d.git("push", d.config.GitRemote, d.branch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkoutBranch 切换到配置的分支, 不存在时创建
+func (d *GitDeliverer) checkoutBranch() error {
+	if cur, err := d.git("symbolic-ref", "--short", "HEAD"); err == nil && cur == d.branch {
+		return nil
+	}
+	if _, err := d.git("rev-parse", "--verify", "--quiet", "refs/heads/"+d.branch); err == nil {
+		_, err = d.git("checkout", "-q", d.branch)
+		return err
+	}
+	_, err := d.git("checkout", "-q", "-b", d.branch)
+	return err
+}
+
+func (d *GitDeliverer) sliceDir(slice model.SliceAndAddress) string {
+	return filepath.Join(d.clusterDir(slice), "slice-"+slice.SliceID())
+}
+
+// clusterDir 切片所在集群的目录, 默认集群为subdir, 其余集群为subdir/<集群名>, 每个目录由对应集群的Argo/Flux同步
+func (d *GitDeliverer) clusterDir(slice model.SliceAndAddress) string {
+	if slice.Cluster == "" || slice.Cluster == kubeclient.DefaultCluster {
+		return d.subdir
+	}
+	return filepath.Join(d.subdir, slice.Cluster)
+}
+
+// git 在仓库中执行git命令, 返回去除首尾空白的标准输出
+func (d *GitDeliverer) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = d.repo
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME="+d.name,
+		"GIT_AUTHOR_EMAIL="+d.email,
+		"GIT_COMMITTER_NAME="+d.name,
+		"GIT_COMMITTER_EMAIL="+d.email,
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s 失败: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func dnns(slice model.SliceAndAddress) []string {
+	var names []string
+	for _, session := range slice.Sessions {
+		names = append(names, session.Name)
+	}
+	return names
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,89 @@
+package delivery
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"slicer/model"
+	"slicer/render"
+	"slicer/util"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testSlice = model.SliceAndAddress{
+	Slice: model.Slice{
+		SST:      1,
+		SD:       "000001",
+		Sessions: []model.Session{{Name: "internet"}},
+	},
+	AddressValue: model.AddressValue{
+		SessionSubnets: []string{"10.40.0.0/16"},
+		UPFN3Addr:      "10.10.3.1/24",
+		UPFN4Addr:      "10.10.4.1/24",
+		SMFN3Addr:      "10.10.3.2/24",
+		SMFN4Addr:      "10.10.4.2/24",
+	},
+}
+
+func TestGitDeliverer(t *testing.T) {
+	repo := t.TempDir()
+	require.NoError(t, exec.Command("git", "init", "-q", repo).Run())
+
+	config := util.Config{
+		KubeConfig:     util.KubeConfig{Namespace: "open5gs"},
+		DeliveryConfig: util.DeliveryConfig{DeliveryMode: ModeGit, GitRepoPath: repo, GitBranch: "gitops"},
+	}
+	r, err := render.NewRender(config)
+	require.NoError(t, err)
+	d, err := NewDeliverer(config, r, nil)
+	require.NoError(t, err)
+	require.Equal(t, ModeGit, d.Mode())
+
+	// 删除从未提交过的切片时不报错
+	other := testSlice
+	other.SD = "000002"
+	_, err = d.RemoveSlice(other)
+	require.NoError(t, err)
+
+	// 部署切片
+	status, err := d.DeliverSlice(testSlice, nil)
+	require.NoError(t, err)
+	require.Len(t, status.Revision, 40)
+	require.FileExists(t, filepath.Join(repo, "slices/slice-1-000001/base/upf-deployment.yaml"))
+
+	// 无变化时不产生新提交
+	same, err := d.DeliverSlice(testSlice, nil)
+	require.NoError(t, err)
+	require.Equal(t, status.Revision, same.Revision)
+
+	// Play以overlay的形式提交
+	play := model.Play{
+		SliceID:   "1-000001",
+		Resources: model.ResourceSpec{CPURequest: "500m", CPULimit: "1", MemoryRequest: "512Mi", MemoryLimit: "1Gi"},
+		Priority:  100,
+	}
+	played, err := d.DeliverPlay(testSlice, play)
+	require.NoError(t, err)
+	require.NotEqual(t, status.Revision, played.Revision)
+	require.FileExists(t, filepath.Join(repo, "slices/slice-1-000001/overlays/open5gs/play-patch-upf.yaml"))
+
+	// 重新交付切片时Play仍在提交中, 与DeliverPlay的结果相同
+	redelivered, err := d.DeliverSlice(testSlice, &play)
+	require.NoError(t, err)
+	require.Equal(t, played.Revision, redelivered.Revision)
+	require.FileExists(t, filepath.Join(repo, "slices/slice-1-000001/overlays/open5gs/play-patch-upf.yaml"))
+
+	// 删除切片
+	removed, err := d.RemoveSlice(testSlice)
+	require.NoError(t, err)
+	require.NotEqual(t, played.Revision, removed.Revision)
+	_, err = os.Stat(filepath.Join(repo, "slices/slice-1-000001"))
+	require.True(t, os.IsNotExist(err))
+
+	out, err := exec.Command("git", "-C", repo, "log", "--format=%an %s", "gitops").Output()
+	require.NoError(t, err)
+	require.Equal(t, "slicer slice(1-000001): 删除切片\nslicer play(1-000001): 更新Play\nslicer slice(1-000001): 部署切片\n", string(out))
+}
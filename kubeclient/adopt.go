@@ -0,0 +1,412 @@
+package kubeclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"slicer/model"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// SMF/UPF的Deployment名称, 模板的open5gs-smf1-000001或参考部署的open5gs-smf1, 同一后缀的SMF和UPF属于同一切片
+var adoptDeploymentPattern = regexp.MustCompile(`^open5gs-(smf|upf)([0-9]+(?:-[0-9A-Fa-f]{1,6})?)$`)
+
+// DiscoveredSlice 命名空间中部署的切片
+type DiscoveredSlice struct {
+	Name    string                 `json:"name"`              // Deployment名称中NF之后的部分, 如1-000001或1
+	SliceID string                 `json:"slice_id"`          // 由SMF配置中的S-NSSAI得出, 解析失败时可能为空
+	Slice   *model.SliceAndAddress `json:"slice,omitempty"`   // 解析失败时为空
+	Managed bool                   `json:"managed"`           // 已带有slicer的归属标签
+	Missing []string               `json:"missing,omitempty"` // 配置文件中没有, 需在接管请求中提供QoS/AMBR的会话(DNN)
+	Error   string                 `json:"error,omitempty"`   // 解析失败的原因
+
+	objects []adoptObject // 接管时打上归属标签的资源
+}
+
+type adoptObject struct {
+	kind string
+	name string
+}
+
+// adoptNF 一个NF的Deployment及其配置文件
+type adoptNF struct {
+	deployment *appsv1.Deployment
+	configMap  *corev1.ConfigMap
+	config     string
+}
+
+// DiscoverSlices 扫描命名空间中的SMF/UPF Deployment及其ConfigMap, 解析出切片及其地址
+// 每个切片单独报告解析错误, 只有列出资源失败时返回错误
+func (kc *KubeClient) DiscoverSlices(namespace string) ([]DiscoveredSlice, error) {
+	deployments, err := kc.GetDeployments(namespace)
+	if err != nil {
+		return nil, err
+	}
+	configMaps, err := kc.GetConfigMaps(namespace)
+	if err != nil {
+		return nil, err
+	}
+	configMapByName := make(map[string]*corev1.ConfigMap, len(configMaps))
+	for i := range configMaps {
+		configMapByName[configMaps[i].Name] = &configMaps[i]
+	}
+
+	nfs := make(map[string]map[string]*adoptNF)
+	for i := range deployments {
+		match := adoptDeploymentPattern.FindStringSubmatch(deployments[i].Name)
+		if match == nil {
+			continue
+		}
+		name := match[2]
+		if nfs[name] == nil {
+			nfs[name] = make(map[string]*adoptNF)
+		}
+		nfs[name][match[1]] = &adoptNF{deployment: &deployments[i]}
+	}
+
+	names := make([]string, 0, len(nfs))
+	for name := range nfs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	discovered := make([]DiscoveredSlice, 0, len(names))
+	found := make(map[string]string) // 切片ID -> 名称, 同一切片部署了两次时报错
+	for _, name := range names {
+		d := DiscoveredSlice{Name: name, Managed: true}
+		if strings.Contains(name, "-") {
+			d.SliceID = name
+		}
+		slice, err := parseDiscoveredSlice(name, nfs[name], configMapByName)
+		if err == nil {
+			d.SliceID = slice.SliceID()
+			if other, ok := found[d.SliceID]; ok {
+				err = fmt.Errorf("切片 %s 已由 %s 部署", d.SliceID, model.DeploymentName("smf", other))
+			}
+		}
+		if err != nil {
+			d.Error = err.Error()
+		} else {
+			found[d.SliceID] = name
+			d.Slice = &slice
+			d.Missing = missingSessions(slice.Sessions)
+		}
+		for _, nf := range []string{"smf", "upf"} {
+			n, ok := nfs[name][nf]
+			if !ok {
+				continue
+			}
+			d.objects = append(d.objects, adoptObject{"Deployment", n.deployment.Name})
+			d.Managed = d.Managed && n.deployment.Labels[ManagedByLabel] == ManagedByValue
+			if n.configMap != nil {
+				d.objects = append(d.objects, adoptObject{"ConfigMap", n.configMap.Name})
+			}
+		}
+		discovered = append(discovered, d)
+	}
+	return discovered, nil
+}
+
+// SetSessions 由接管请求按会话名称(DNN)补全QoS/AMBR, 补全后校验切片
+// 仍有会话缺少参数时返回错误, 不会使用默认值
+func (d *DiscoveredSlice) SetSessions(sessions []model.Session) error {
+	if d.Slice == nil {
+		return fmt.Errorf("切片未解析")
+	}
+	byName := make(map[string]model.Session, len(sessions))
+	for _, s := range sessions {
+		byName[s.Name] = s
+	}
+	for i := range d.Slice.Sessions {
+		s, ok := byName[d.Slice.Sessions[i].Name]
+		if !ok {
+			continue
+		}
+		d.Slice.Sessions[i].AMBR = s.AMBR
+		d.Slice.Sessions[i].QoS = s.QoS
+		d.Slice.Sessions[i].PCCRule = s.PCCRule
+		if s.Type != 0 {
+			d.Slice.Sessions[i].Type = s.Type
+		}
+	}
+	d.Missing = missingSessions(d.Slice.Sessions)
+	if len(d.Missing) > 0 {
+		return fmt.Errorf("请求中缺少会话 %s 的QoS/AMBR", strings.Join(d.Missing, ", "))
+	}
+	return d.Slice.Validate()
+}
+
+// missingSessions 返回尚未设置QoS/AMBR的会话名称
+func missingSessions(sessions []model.Session) []string {
+	var missing []string
+	for _, s := range sessions {
+		if s.QoS.Index == 0 || s.AMBR == (model.AMBR{}) {
+			missing = append(missing, s.Name)
+		}
+	}
+	return missing
+}
+
+// AdoptSlice 为切片的Deployment和ConfigMap打上slicer的归属标签, 之后可按切片清理和删除
+func (kc *KubeClient) AdoptSlice(namespace string, d DiscoveredSlice) error {
+	ctx := context.TODO()
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels": map[string]string{ManagedByLabel: ManagedByValue, SliceOwnerLabel: d.SliceID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for _, obj := range d.objects {
+		switch obj.kind {
+		case "Deployment":
+			_, err = kc.clientset.AppsV1().Deployments(namespace).Patch(ctx, obj.name, types.MergePatchType, patch, metav1.PatchOptions{})
+		case "ConfigMap":
+			_, err = kc.clientset.CoreV1().ConfigMaps(namespace).Patch(ctx, obj.name, types.MergePatchType, patch, metav1.PatchOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("标记 %s/%s 失败: %w", obj.kind, obj.name, err)
+		}
+	}
+	return nil
+}
+
+// parseDiscoveredSlice 由SMF/UPF的配置文件和Multus注解还原切片
+// 配置文件中没有会话的QoS/AMBR等签约参数, 这些参数留空, 由接管请求提供, 因此这里不校验会话
+func parseDiscoveredSlice(name string, nfs map[string]*adoptNF, configMaps map[string]*corev1.ConfigMap) (model.SliceAndAddress, error) {
+	var slice model.SliceAndAddress
+	for _, nf := range []string{"smf", "upf"} {
+		n, ok := nfs[nf]
+		if !ok {
+			return slice, fmt.Errorf("缺少 %s", model.DeploymentName(nf, name))
+		}
+		if err := n.loadConfig(nf, configMaps); err != nil {
+			return slice, err
+		}
+	}
+	smf, upf := nfs["smf"], nfs["upf"]
+
+	var smfCfg adoptSMFConfig
+	if err := yaml.Unmarshal([]byte(smf.config), &smfCfg); err != nil {
+		return slice, fmt.Errorf("解析SMF配置失败: %w", err)
+	}
+	var upfCfg adoptUPFConfig
+	if err := yaml.Unmarshal([]byte(upf.config), &upfCfg); err != nil {
+		return slice, fmt.Errorf("解析UPF配置失败: %w", err)
+	}
+
+	// S-NSSAI, 取自SMF配置, 名称中带有切片ID时需与之一致
+	for _, info := range smfCfg.SMF.Info {
+		for _, snssai := range info.SNSSAI {
+			if slice.SST != 0 && (snssai.SST != slice.SST || !strings.EqualFold(snssai.SD, slice.SD)) {
+				return slice, fmt.Errorf("SMF配置中有多个S-NSSAI")
+			}
+			slice.SST, slice.SD = snssai.SST, snssai.SD
+		}
+	}
+	if slice.SST == 0 {
+		return slice, fmt.Errorf("SMF配置中没有S-NSSAI")
+	}
+	if strings.Contains(name, "-") && !strings.EqualFold(name, slice.SliceID()) {
+		return slice, fmt.Errorf("SMF配置中的S-NSSAI %s 与名称不一致", slice.SliceID())
+	}
+
+	// 会话, UPF配置中同时有子网和DNN, 需与SMF配置中的子网一致
+	if len(upfCfg.UPF.Session) == 0 {
+		return slice, fmt.Errorf("UPF配置中没有会话")
+	}
+	// 配置文件中的子网可能写成网关地址(如10.41.0.1/16), 统一为网络地址
+	smfSubnets := make(map[string]bool, len(smfCfg.SMF.Session))
+	for _, s := range smfCfg.SMF.Session {
+		if subnet, err := networkAddr(s.Subnet); err == nil {
+			smfSubnets[subnet] = true
+		}
+	}
+	for _, s := range upfCfg.UPF.Session {
+		if s.DNN == "" || s.Subnet == "" {
+			return slice, fmt.Errorf("UPF配置中的会话缺少dnn或subnet")
+		}
+		subnet, err := networkAddr(s.Subnet)
+		if err != nil {
+			return slice, fmt.Errorf("UPF配置中的会话子网 %s 无效: %w", s.Subnet, err)
+		}
+		if !smfSubnets[subnet] {
+			return slice, fmt.Errorf("会话子网 %s 不在SMF配置中", s.Subnet)
+		}
+		slice.Sessions = append(slice.Sessions, model.Session{Name: s.DNN, Type: 1}) // IPv4
+		slice.SessionSubnets = append(slice.SessionSubnets, subnet)
+	}
+
+	// Multus注解中的静态地址
+	var err error
+	if slice.SMFN3Addr, slice.SMFN4Addr, err = multusAddrs(smf.deployment); err != nil {
+		return slice, fmt.Errorf("SMF: %w", err)
+	}
+	if slice.UPFN3Addr, slice.UPFN4Addr, err = multusAddrs(upf.deployment); err != nil {
+		return slice, fmt.Errorf("UPF: %w", err)
+	}
+	upfN4IP := strings.SplitN(slice.UPFN4Addr, "/", 2)[0]
+	found := false
+	for _, u := range smfCfg.SMF.PFCP.Client.UPF {
+		found = found || u.Address == upfN4IP
+	}
+	if !found {
+		return slice, fmt.Errorf("SMF配置中没有连接UPF的N4地址 %s", upfN4IP)
+	}
+
+	// NF参数, 保留与模板默认值可能不同的部分
+	slice.NF = &model.NFParams{
+		SMF: adoptNFParam(smf.deployment, smfCfg.adoptConfig, smfCfg.SMF.DNS, smfCfg.SMF.MTU),
+		UPF: adoptNFParam(upf.deployment, upfCfg.adoptConfig, nil, 0),
+	}
+	return slice, nil
+}
+
+// networkAddr 返回CIDR的网络地址, 如10.41.0.1/16为10.41.0.0/16
+func networkAddr(cidr string) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return network.String(), nil
+}
+
+// loadConfig 按Deployment的卷找到配置文件所在的ConfigMap
+func (n *adoptNF) loadConfig(nf string, configMaps map[string]*corev1.ConfigMap) error {
+	key := nf + "cfg.yaml"
+	for _, volume := range n.deployment.Spec.Template.Spec.Volumes {
+		var names []string
+		if volume.ConfigMap != nil {
+			names = append(names, volume.ConfigMap.Name)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					names = append(names, source.ConfigMap.Name)
+				}
+			}
+		}
+		for _, name := range names {
+			if cm, ok := configMaps[name]; ok && cm.Data[key] != "" {
+				n.configMap, n.config = cm, cm.Data[key]
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s 没有挂载包含 %s 的ConfigMap", n.deployment.Name, key)
+}
+
+// multusAddrs 从Pod模板的Multus注解中读取N3/N4的静态地址
+func multusAddrs(d *appsv1.Deployment) (n3, n4 string, err error) {
+	raw := d.Spec.Template.Annotations[MultusNetworksAnnotation]
+	if raw == "" {
+		return "", "", fmt.Errorf("缺少Multus注解")
+	}
+	var networks []struct {
+		Name      string   `json:"name"`
+		Interface string   `json:"interface"`
+		IPs       []string `json:"ips"`
+	}
+	if err := json.Unmarshal([]byte(raw), &networks); err != nil {
+		return "", "", fmt.Errorf("解析Multus注解失败: %w", err)
+	}
+	for _, network := range networks {
+		if len(network.IPs) == 0 {
+			continue
+		}
+		switch {
+		case network.Interface == "n3" || strings.HasSuffix(network.Name, "n3network"):
+			n3 = network.IPs[0]
+		case network.Interface == "n4" || strings.HasSuffix(network.Name, "n4network"):
+			n4 = network.IPs[0]
+		}
+	}
+	if n3 == "" || n4 == "" {
+		return "", "", fmt.Errorf("Multus注解中没有N3/N4的静态地址")
+	}
+	return n3, n4, nil
+}
+
+// adoptNFParam 由容器和配置文件还原NF参数
+func adoptNFParam(d *appsv1.Deployment, cfg adoptConfig, dns []string, mtu int) model.NFParam {
+	param := model.NFParam{
+		DNS:          dns,
+		MTU:          mtu,
+		MaxUE:        cfg.Global.Max.UE,
+		LogLevel:     cfg.Logger.Level,
+		LogFile:      cfg.Logger.File,
+		NodeSelector: d.Spec.Template.Spec.NodeSelector,
+	}
+	containers := d.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return param
+	}
+	param.Image = containers[0].Image
+	requests, limits := containers[0].Resources.Requests, containers[0].Resources.Limits
+	if !requests.Cpu().IsZero() && !requests.Memory().IsZero() && !limits.Cpu().IsZero() && !limits.Memory().IsZero() {
+		param.Resources = &model.ResourceSpec{
+			CPURequest:    model.Quantity(requests.Cpu().String()),
+			CPULimit:      model.Quantity(limits.Cpu().String()),
+			MemoryRequest: model.Quantity(requests.Memory().String()),
+			MemoryLimit:   model.Quantity(limits.Memory().String()),
+		}
+	}
+	return param
+}
+
+// adoptConfig SMF和UPF配置文件中共有的部分
+type adoptConfig struct {
+	Logger struct {
+		File  string `yaml:"file"`
+		Level string `yaml:"level"`
+	} `yaml:"logger"`
+	Global struct {
+		Max struct {
+			UE int `yaml:"ue"`
+		} `yaml:"max"`
+	} `yaml:"global"`
+}
+
+type adoptSMFConfig struct {
+	adoptConfig `yaml:",inline"`
+	SMF         struct {
+		Session []struct {
+			Subnet string `yaml:"subnet"`
+		} `yaml:"session"`
+		PFCP struct {
+			Client struct {
+				UPF []struct {
+					Address string `yaml:"address"`
+				} `yaml:"upf"`
+			} `yaml:"client"`
+		} `yaml:"pfcp"`
+		DNS  []string `yaml:"dns"`
+		MTU  int      `yaml:"mtu"`
+		Info []struct {
+			SNSSAI []struct {
+				SST int    `yaml:"sst"`
+				SD  string `yaml:"sd"`
+			} `yaml:"s_nssai"`
+		} `yaml:"info"`
+	} `yaml:"smf"`
+}
+
+type adoptUPFConfig struct {
+	adoptConfig `yaml:",inline"`
+	UPF         struct {
+		Session []struct {
+			Subnet string `yaml:"subnet"`
+			DNN    string `yaml:"dnn"`
+		} `yaml:"session"`
+	} `yaml:"upf"`
+}
@@ -0,0 +1,123 @@
+package kubeclient
+
+import (
+	"context"
+	"os"
+	"slicer/model"
+	"slicer/render"
+	"slicer/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestDiscoverSlices(t *testing.T) {
+	slice := model.SliceAndAddress{
+		Slice: model.Slice{
+			SST: 1,
+			SD:  "000001",
+			Sessions: []model.Session{
+				{Name: "internet", Type: 1, QoS: model.QoS{Index: 9, ARP: model.ARP{PriorityLevel: 8}}},
+				{Name: "ims", Type: 1, QoS: model.QoS{Index: 9, ARP: model.ARP{PriorityLevel: 8}}},
+			},
+		},
+		AddressValue: model.AddressValue{
+			SessionSubnets: []string{"10.40.0.0/16", "10.41.0.0/16"},
+			UPFN3Addr:      "10.10.3.11/24",
+			UPFN4Addr:      "10.10.4.11/24",
+			SMFN3Addr:      "10.10.3.12/24",
+			SMFN4Addr:      "10.10.4.12/24",
+		},
+	}
+	r, err := render.NewRender(util.Config{})
+	require.NoError(t, err)
+	files, err := r.RenderSliceFiles(slice)
+	require.NoError(t, err)
+
+	// 模拟手工部署的切片, 不带slicer的归属标签
+	var objects []runtime.Object
+	for _, name := range []string{"smf-configmap.yaml", "smf-deployment.yaml", "upf-configmap.yaml", "upf-deployment.yaml"} {
+		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(files[name], nil, nil)
+		require.NoError(t, err, name)
+		obj.(v1.Object).SetNamespace("open5gs")
+		objects = append(objects, obj)
+	}
+	// 缺少UPF的切片单独报告
+	orphan, _, err := scheme.Codecs.UniversalDeserializer().Decode(files["smf-deployment.yaml"], nil, nil)
+	require.NoError(t, err)
+	orphan.(v1.Object).SetName("open5gs-smf2-000002")
+	orphan.(v1.Object).SetNamespace("open5gs")
+	objects = append(objects, orphan)
+
+	clientset := fakeclientset.NewSimpleClientset(objects...)
+	kc := &KubeClient{clientset: clientset}
+
+	discovered, err := kc.DiscoverSlices("open5gs")
+	require.NoError(t, err)
+	require.Len(t, discovered, 2)
+	assert.Contains(t, discovered[1].Error, "open5gs-upf2-000002")
+
+	d := discovered[0]
+	require.Empty(t, d.Error)
+	assert.False(t, d.Managed)
+	assert.Equal(t, "1-000001", d.SliceID)
+	assert.Equal(t, slice.AddressValue, d.Slice.AddressValue)
+	assert.Equal(t, []string{"internet", "ims"}, []string{d.Slice.Sessions[0].Name, d.Slice.Sessions[1].Name})
+	assert.Equal(t, "000001", d.Slice.SD)
+	assert.Equal(t, "ghcr.io/niloysh/open5gs:v2.7.0-upf-metrics-v2", d.Slice.NF.UPF.Image)
+	assert.Equal(t, model.Quantity("500m"), d.Slice.NF.SMF.Resources.CPULimit)
+
+	// 配置文件中没有QoS/AMBR, 需由请求提供
+	assert.Equal(t, []string{"internet", "ims"}, d.Missing)
+	ambr := model.AMBR{Uplink: model.BitRate{Value: 1, Unit: 3}, Downlink: model.BitRate{Value: 1, Unit: 3}}
+	assert.ErrorContains(t, d.SetSessions([]model.Session{{Name: "internet", AMBR: ambr, QoS: slice.Sessions[0].QoS}}), "ims")
+	assert.Equal(t, []string{"ims"}, d.Missing)
+	require.NoError(t, d.SetSessions([]model.Session{
+		{Name: "internet", AMBR: ambr, QoS: slice.Sessions[0].QoS},
+		{Name: "ims", AMBR: ambr, QoS: slice.Sessions[1].QoS},
+	}))
+	assert.Empty(t, d.Missing)
+	assert.Equal(t, ambr, d.Slice.Sessions[1].AMBR)
+
+	// 接管后资源带有归属标签
+	require.NoError(t, kc.AdoptSlice("open5gs", d))
+	deployment, err := clientset.AppsV1().Deployments("open5gs").Get(context.TODO(), "open5gs-upf1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "1-000001", deployment.Labels[SliceOwnerLabel])
+	configMap, err := clientset.CoreV1().ConfigMaps("open5gs").Get(context.TODO(), "smf1-000001-configmap", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, ManagedByValue, configMap.Labels[ManagedByLabel])
+}
+
+func TestDiscoverReferenceSlice(t *testing.T) {
+	// 参考部署中的切片, Deployment名称中只有编号, S-NSSAI取自SMF配置
+	dir := "../external/Open5gs/open5gs/slices/slice1/"
+	var objects []runtime.Object
+	for _, name := range []string{"smf1/smf-configmap.yaml", "smf1/smf-deployment.yaml", "upf1/upf-configmap.yaml", "upf1/upf-deployment.yaml"} {
+		data, err := os.ReadFile(dir + name)
+		require.NoError(t, err)
+		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+		require.NoError(t, err, name)
+		obj.(v1.Object).SetNamespace("open5gs")
+		objects = append(objects, obj)
+	}
+	kc := &KubeClient{clientset: fakeclientset.NewSimpleClientset(objects...)}
+
+	discovered, err := kc.DiscoverSlices("open5gs")
+	require.NoError(t, err)
+	require.Len(t, discovered, 1)
+	d := discovered[0]
+	require.Empty(t, d.Error)
+	assert.Equal(t, "1", d.Name)
+	assert.Equal(t, "1-000001", d.SliceID)
+	assert.Equal(t, []string{"10.41.0.0/16"}, d.Slice.SessionSubnets)
+	assert.Equal(t, "10.10.4.1/24", d.Slice.UPFN4Addr)
+	assert.Equal(t, "10.10.3.101/24", d.Slice.SMFN3Addr)
+	assert.Equal(t, []string{"internet"}, d.Missing)
+}
@@ -0,0 +1,296 @@
+package kubeclient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"slicer/model"
+	"slicer/util"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CNI bandwidth插件使用的注解
+const (
+	IngressBandwidthAnnotation = "kubernetes.io/ingress-bandwidth"
+	EgressBandwidthAnnotation  = "kubernetes.io/egress-bandwidth"
+)
+
+// BandwidthEnforcer 带宽限制的执行方式, 由Play的bandwidth_enforcer按切片选择
+type BandwidthEnforcer interface {
+	// PatchTemplate 在更新工作负载前修改Pod模板
+	PatchTemplate(target EnforceTarget, template *corev1.PodTemplateSpec, bw model.BandwidthSpec) error
+	// Enforce 在更新工作负载前保存限速参数, 返回恢复操作, 无需恢复时返回nil
+	Enforce(ctx context.Context, target EnforceTarget, bw model.BandwidthSpec) (func() error, error)
+}
+
+// EnforceTarget 限速作用的工作负载及容器
+type EnforceTarget struct {
+	Namespace string
+	SliceID   string
+	Workload  string
+	Container string
+}
+
+// bandwidthEnforcer 返回NF使用的带宽限制方式, name为空时使用全局配置
+// tc只作用于UPF的N3/N6接口, 其余NF改用注解, 此时返回的说明不为空, 由调用方写入PlayReport
+func (kc *KubeClient) bandwidthEnforcer(name, nf string) (BandwidthEnforcer, string, error) {
+	if name == "" {
+		name = kc.config.BandwidthEnforcer
+	}
+	switch name {
+	case "", model.EnforcerAnnotation:
+		return annotationEnforcer{}, "", nil
+	case model.EnforcerTC:
+		if nf != model.UPFNF {
+			return annotationEnforcer{}, fmt.Sprintf("%s不支持tc限速, 已改用注解, Pod重建后生效", nf), nil
+		}
+		return &tcEnforcer{
+			clientset:  kc.clientset,
+			downlinkIf: defaultString(kc.config.TCN3Interface, "n3"),
+			uplinkIfs:  tcUplinkInterfaces(kc.config),
+		}, "", nil
+	}
+	return nil, "", fmt.Errorf("不支持的带宽限制方式 %q", name)
+}
+
+// tcUplinkInterfaces 上行限速的接口, 默认为N6_NETWORKS中各DNN的N6接口, 未配置N6网络时N6流量经eth0 NAT离开Pod
+// 每个接口各自按上行带宽限速, 边车跳过Pod中不存在的接口(切片未使用的DNN)
+func tcUplinkInterfaces(config util.Config) []string {
+	if config.TCN6Interface != "" {
+		return []string{config.TCN6Interface}
+	}
+	var ifs []string
+	for dnn := range config.N6Networks {
+		ifs = append(ifs, config.N6Interface(dnn))
+	}
+	if len(ifs) == 0 {
+		return []string{"eth0"}
+	}
+	sort.Strings(ifs)
+	return ifs
+}
+
+// annotationEnforcer 通过CNI bandwidth插件的注解限速, 作用于eth0, Pod重建后生效
+type annotationEnforcer struct{}
+
+func (annotationEnforcer) PatchTemplate(_ EnforceTarget, template *corev1.PodTemplateSpec, bw model.BandwidthSpec) error {
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	// 统一为CNI可识别的格式
+	for key, value := range map[string]model.Bandwidth{
+		IngressBandwidthAnnotation: bw.Ingress,
+		EgressBandwidthAnnotation:  bw.Egress,
+	} {
+		if value == "" {
+			continue
+		}
+		normalized, err := value.Normalize()
+		if err != nil {
+			return err
+		}
+		template.Annotations[key] = normalized.String()
+	}
+	return nil
+}
+
+func (annotationEnforcer) Enforce(context.Context, EnforceTarget, model.BandwidthSpec) (func() error, error) {
+	return nil, nil
+}
+
+// tc边车容器及其挂载的限速参数
+const (
+	TCSidecarName = "bandwidth-tc"
+	tcConfigDir   = "/etc/slicer/bandwidth"
+	tcDownlinkKey = "downlink" // 值为tbf参数, 如 "rate 100000000bit burst 125000b latency 50ms"
+	tcUplinkKey   = "uplink"
+)
+
+// tcEnforcer 由Pod中的tc边车容器限速, 用tbf限制接口的发送速率
+// 下行(ingress, 发往基站)限制N3接口, 上行(egress, 发往数据网络)限制N6接口
+// 限速参数保存在ConfigMap <工作负载>-bandwidth 中并挂载到边车, kubelet同步修改后边车重新执行tc,
+// 运行中的Pod无需重建; 新建的Pod启动时即按当前参数限速
+// 首次启用时需向Pod模板添加边车, 工作负载会滚动更新(Pod重建), playNF在PlayReport中说明
+type tcEnforcer struct {
+	clientset  kubernetes.Interface
+	downlinkIf string
+	uplinkIfs  []string
+}
+
+// BandwidthConfigMapName tc限速参数所在的ConfigMap名称
+func BandwidthConfigMapName(workload string) string {
+	return workload + "-bandwidth"
+}
+
+// PatchTemplate 移除注解方式的限速, 避免重复限速, 并添加tc边车; 未设置带宽时不修改
+func (t *tcEnforcer) PatchTemplate(target EnforceTarget, template *corev1.PodTemplateSpec, bw model.BandwidthSpec) error {
+	if bw.Ingress != "" {
+		delete(template.Annotations, IngressBandwidthAnnotation)
+	}
+	if bw.Egress != "" {
+		delete(template.Annotations, EgressBandwidthAnnotation)
+	}
+	if bw == (model.BandwidthSpec{}) {
+		return nil
+	}
+
+	// 边车使用被限速容器的镜像, UPF镜像中已有tc
+	container := findContainer(&template.Spec, []string{target.Container})
+	if container == nil {
+		return fmt.Errorf("没有容器 %s", target.Container)
+	}
+	sidecar := corev1.Container{
+		Name:    TCSidecarName,
+		Image:   container.Image,
+		Command: []string{"sh", "-c", t.script()},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m"), corev1.ResourceMemory: resource.MustParse("16Mi")},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("32Mi")},
+		},
+		VolumeMounts: []corev1.VolumeMount{{Name: TCSidecarName, MountPath: tcConfigDir, ReadOnly: true}},
+		SecurityContext: &corev1.SecurityContext{
+			Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_ADMIN"}},
+		},
+	}
+	replaced := false
+	for i := range template.Spec.Containers {
+		if template.Spec.Containers[i].Name == TCSidecarName {
+			template.Spec.Containers[i] = sidecar
+			replaced = true
+		}
+	}
+	if !replaced {
+		template.Spec.Containers = append(template.Spec.Containers, sidecar)
+	}
+
+	// ConfigMap不存在时边车不限速, 不影响Pod启动
+	optional := true
+	volume := corev1.Volume{Name: TCSidecarName, VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+		LocalObjectReference: corev1.LocalObjectReference{Name: BandwidthConfigMapName(target.Workload)},
+		Optional:             &optional,
+	}}}
+	for i := range template.Spec.Volumes {
+		if template.Spec.Volumes[i].Name == TCSidecarName {
+			template.Spec.Volumes[i] = volume
+			return nil
+		}
+	}
+	template.Spec.Volumes = append(template.Spec.Volumes, volume)
+	return nil
+}
+
+// script 边车执行的脚本, 参数变化时替换接口的根qdisc, 参数为空时删除, 失败后下次检查时重试
+func (t *tcEnforcer) script() string {
+	return strings.Join([]string{
+		`last=""`,
+		`while true; do`,
+		fmt.Sprintf(`  cur="$(cat %[1]s/%[2]s 2>/dev/null; echo /; cat %[1]s/%[3]s 2>/dev/null)"`, tcConfigDir, tcDownlinkKey, tcUplinkKey),
+		`  if [ "$cur" != "$last" ]; then`,
+		`    ok=1`,
+		fmt.Sprintf(`    for pair in %s; do`, strings.Join(t.pairs(), " ")),
+		`      dev=${pair%%:*}; args="$(cat ` + tcConfigDir + `/${pair#*:} 2>/dev/null)"`,
+		`      [ -e /sys/class/net/$dev ] || continue`,
+		`      if [ -n "$args" ]; then tc qdisc replace dev $dev root tbf $args || ok=0;`,
+		`      else tc qdisc del dev $dev root 2>/dev/null; fi`,
+		`    done`,
+		`    [ $ok = 1 ] && last="$cur" && echo "bandwidth: $cur"`,
+		`  fi`,
+		`  sleep 5`,
+		`done`,
+	}, "\n")
+}
+
+// pairs 接口与限速参数的对应关系, 格式为 <接口>:<参数名>
+func (t *tcEnforcer) pairs() []string {
+	pairs := []string{t.downlinkIf + ":" + tcDownlinkKey}
+	for _, dev := range t.uplinkIfs {
+		pairs = append(pairs, dev+":"+tcUplinkKey)
+	}
+	return pairs
+}
+
+// Enforce 将限速参数写入ConfigMap, 未设置的方向保留原有参数
+func (t *tcEnforcer) Enforce(ctx context.Context, target EnforceTarget, bw model.BandwidthSpec) (func() error, error) {
+	configMaps := t.clientset.CoreV1().ConfigMaps(target.Namespace)
+	name := BandwidthConfigMapName(target.Workload)
+	existing, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	found := err == nil
+
+	data := make(map[string]string)
+	if found {
+		maps.Copy(data, existing.Data)
+	}
+	for key, value := range map[string]model.Bandwidth{tcDownlinkKey: bw.Ingress, tcUplinkKey: bw.Egress} {
+		if value == "" {
+			continue
+		}
+		bps := value.Mbps() * 1e6
+		if bps <= 0 {
+			return nil, fmt.Errorf("非法的带宽 %q", value)
+		}
+		data[key] = strings.Join(tbfArgs(bps, 0), " ")
+	}
+
+	if !found {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: target.Namespace,
+				Labels:    map[string]string{ManagedByLabel: ManagedByValue, SliceOwnerLabel: target.SliceID, PlayResourceLabel: "bandwidth"},
+			},
+			Data: data,
+		}
+		if _, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+			return nil, err
+		}
+		slog.Info("已保存tc限速参数", "configmap", name, "bandwidth", bw)
+		return func() error {
+			return ignoreNotFound(configMaps.Delete(ctx, name, metav1.DeleteOptions{}))
+		}, nil
+	}
+	if maps.Equal(existing.Data, data) {
+		return nil, nil
+	}
+	updated := existing.DeepCopy()
+	updated.Data = data
+	if _, err := configMaps.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return nil, err
+	}
+	slog.Info("已保存tc限速参数", "configmap", name, "bandwidth", bw)
+	return func() error {
+		current, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Data = existing.Data
+		_, err = configMaps.Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	}, nil
+}
+
+// tbfArgs 生成tbf的参数, burst为0时按10ms的流量计算
+func tbfArgs(bps float64, burst uint64) []string {
+	if burst == 0 {
+		burst = uint64(math.Max(bps/8/100, 16*1024))
+	}
+	return []string{"rate", fmt.Sprintf("%.0fbit", bps), "burst", fmt.Sprintf("%db", burst), "latency", "50ms"}
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,308 @@
+package kubeclient
+
+import (
+	"context"
+	"fmt"
+	"slicer/model"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// cacheLabel 由informer缓存的资源的标签, 切片及共享NF的资源均带有该标签
+const (
+	cacheLabel      = "app"
+	cacheLabelValue = "open5gs"
+)
+
+// sliceNFs 切片中以工作负载部署的NF, 用于按名称关联已删除对象的事件
+var sliceNFs = []string{"smf", "upf"}
+
+// clusterCache 通过informer缓存open5gs的Pod、Service、Deployment、StatefulSet及相关的Event
+// 在缓存同步完成前, 查询退化为直接访问API Server
+type clusterCache struct {
+	namespace    string // 监听的命名空间, 为空表示所有命名空间
+	pods         corelisters.PodLister
+	services     corelisters.ServiceLister
+	deployments  appslisters.DeploymentLister
+	statefulSets appslisters.StatefulSetLister
+	events       corelisters.EventLister // 监听所有命名空间时为nil
+	synced       []cache.InformerSynced
+	stop         chan struct{}
+	closeOnce    sync.Once
+}
+
+// newClusterCache 创建并启动informer
+// 切片独立命名空间时监听所有命名空间, 否则只监听namespace
+// Event不带标签, 无法按标签过滤, 只在监听单个命名空间时缓存, 查询时按涉及的对象关联到切片;
+// 监听所有命名空间时缓存Event会包含集群中的全部事件, 改为查询时按切片命名空间直接访问API Server
+func newClusterCache(clientset kubernetes.Interface, namespace string) *clusterCache {
+	selector := labels.Set{cacheLabel: cacheLabelValue}.String()
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *v1.ListOptions) {
+			options.LabelSelector = selector
+		}),
+	)
+
+	c := &clusterCache{
+		namespace:    namespace,
+		pods:         factory.Core().V1().Pods().Lister(),
+		services:     factory.Core().V1().Services().Lister(),
+		deployments:  factory.Apps().V1().Deployments().Lister(),
+		statefulSets: factory.Apps().V1().StatefulSets().Lister(),
+		synced: []cache.InformerSynced{
+			factory.Core().V1().Pods().Informer().HasSynced,
+			factory.Core().V1().Services().Informer().HasSynced,
+			factory.Apps().V1().Deployments().Informer().HasSynced,
+			factory.Apps().V1().StatefulSets().Informer().HasSynced,
+		},
+		stop: make(chan struct{}),
+	}
+	factory.Start(c.stop)
+	if namespace != v1.NamespaceAll {
+		eventFactory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithNamespace(namespace))
+		c.events = eventFactory.Core().V1().Events().Lister()
+		c.synced = append(c.synced, eventFactory.Core().V1().Events().Informer().HasSynced)
+		eventFactory.Start(c.stop)
+	}
+	return c
+}
+
+// close 停止所有informer, 可重复调用
+func (c *clusterCache) close() {
+	if c == nil {
+		return
+	}
+	c.closeOnce.Do(func() { close(c.stop) })
+}
+
+// ready 所有informer是否已完成首次同步
+func (c *clusterCache) ready() bool {
+	if c == nil {
+		return false
+	}
+	for _, synced := range c.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// covers 缓存已同步且监听了namespace
+func (c *clusterCache) covers(namespace string) bool {
+	return c.ready() && (c.namespace == "" || c.namespace == namespace)
+}
+
+// cached 判断查询能否由缓存满足: 缓存覆盖该命名空间且标签选择器要求app=open5gs
+func (c *clusterCache) cached(namespace string, labelSelector []string) (labels.Selector, bool) {
+	if !c.covers(namespace) {
+		return nil, false
+	}
+	selector, err := labels.Parse(strings.Join(labelSelector, ","))
+	if err != nil {
+		return nil, false
+	}
+	if value, ok := selector.RequiresExactMatch(cacheLabel); !ok || value != cacheLabelValue {
+		return nil, false
+	}
+	return selector, true
+}
+
+// cachedPods 从缓存获取Pod, 不满足缓存条件时ok为false
+func (kc *KubeClient) cachedPods(namespace string, labelSelector []string) (pods []corev1.Pod, ok bool, err error) {
+	selector, ok := kc.cache.cached(namespace, labelSelector)
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := kc.cache.pods.Pods(namespace).List(selector)
+	if err != nil {
+		return nil, true, err
+	}
+	for _, item := range items {
+		pods = append(pods, *item.DeepCopy())
+	}
+	return pods, true, nil
+}
+
+// cachedServices 从缓存获取Service, 不满足缓存条件时ok为false
+func (kc *KubeClient) cachedServices(namespace string, labelSelector []string) (services []corev1.Service, ok bool, err error) {
+	selector, ok := kc.cache.cached(namespace, labelSelector)
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := kc.cache.services.Services(namespace).List(selector)
+	if err != nil {
+		return nil, true, err
+	}
+	for _, item := range items {
+		services = append(services, *item.DeepCopy())
+	}
+	return services, true, nil
+}
+
+// cachedDeployments 从缓存获取Deployment, 不满足缓存条件时ok为false
+func (kc *KubeClient) cachedDeployments(namespace string, labelSelector []string) (deployments []appsv1.Deployment, ok bool, err error) {
+	selector, ok := kc.cache.cached(namespace, labelSelector)
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := kc.cache.deployments.Deployments(namespace).List(selector)
+	if err != nil {
+		return nil, true, err
+	}
+	for _, item := range items {
+		deployments = append(deployments, *item.DeepCopy())
+	}
+	return deployments, true, nil
+}
+
+// cachedStatefulSets 从缓存获取StatefulSet, 不满足缓存条件时ok为false
+func (kc *KubeClient) cachedStatefulSets(namespace string, labelSelector []string) (statefulSets []appsv1.StatefulSet, ok bool, err error) {
+	selector, ok := kc.cache.cached(namespace, labelSelector)
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := kc.cache.statefulSets.StatefulSets(namespace).List(selector)
+	if err != nil {
+		return nil, true, err
+	}
+	for _, item := range items {
+		statefulSets = append(statefulSets, *item.DeepCopy())
+	}
+	return statefulSets, true, nil
+}
+
+// SliceEvent 与切片中对象相关的Kubernetes事件
+type SliceEvent struct {
+	Type      string    `json:"type"`   // Normal或Warning
+	Reason    string    `json:"reason"` // 如OOMKilling, FailedScheduling, BackOff
+	Message   string    `json:"message"`
+	Kind      string    `json:"kind"` // 涉及对象的类型
+	Name      string    `json:"name"` // 涉及对象的名称
+	Count     int32     `json:"count"`
+	FirstTime time.Time `json:"first_time"`
+	LastTime  time.Time `json:"last_time"`
+}
+
+// SliceEvents 获取切片对象的事件, 按最近发生时间排序, eventType非空时只返回该类型的事件
+// 事件属于切片: 涉及的对象带有切片标签, 或名称以切片的工作负载名称开头(如已删除的Pod和ReplicaSet)
+func (kc *KubeClient) SliceEvents(sliceID, eventType string) ([]SliceEvent, error) {
+	namespace := kc.config.SliceNamespace(sliceID)
+
+	var events []corev1.Event
+	if kc.cache.covers(namespace) && kc.cache.events != nil {
+		items, err := kc.cache.events.Events(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("获取事件失败: %w", err)
+		}
+		for _, item := range items {
+			events = append(events, *item)
+		}
+	} else {
+		list, err := kc.clientset.CoreV1().Events(namespace).List(context.TODO(), v1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("获取事件失败: %w", err)
+		}
+		events = list.Items
+	}
+
+	owned, err := kc.sliceObjectNames(sliceID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var prefixes []string
+	for _, nf := range sliceNFs {
+		prefixes = append(prefixes, model.DeploymentName(nf, sliceID))
+	}
+
+	result := []SliceEvent{}
+	for _, event := range events {
+		if eventType != "" && event.Type != eventType {
+			continue
+		}
+		object := event.InvolvedObject
+		if !owned[object.Kind+"/"+object.Name] && !hasNamePrefix(object.Name, prefixes) {
+			continue
+		}
+		result = append(result, SliceEvent{
+			Type:      event.Type,
+			Reason:    event.Reason,
+			Message:   event.Message,
+			Kind:      object.Kind,
+			Name:      object.Name,
+			Count:     event.Count,
+			FirstTime: eventTime(event, event.FirstTimestamp),
+			LastTime:  eventTime(event, event.LastTimestamp),
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastTime.After(result[j].LastTime)
+	})
+	return result, nil
+}
+
+// sliceObjectNames 切片中带标签的Pod、Service、Deployment、StatefulSet, 键为 Kind/Name
+func (kc *KubeClient) sliceObjectNames(sliceID, namespace string) (map[string]bool, error) {
+	selector := sliceSelector(sliceID)
+	names := map[string]bool{}
+	pods, err := kc.GetPods(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		names["Pod/"+pod.Name] = true
+	}
+	services, err := kc.GetServices(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		names["Service/"+service.Name] = true
+	}
+	deployments, err := kc.GetDeployments(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments {
+		names["Deployment/"+deployment.Name] = true
+	}
+	statefulSets, err := kc.GetStatefulSets(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, statefulSet := range statefulSets {
+		names["StatefulSet/"+statefulSet.Name] = true
+	}
+	return names, nil
+}
+
+// hasNamePrefix 名称等于某个前缀或以 "前缀-" 开头
+func hasNamePrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if name == prefix || strings.HasPrefix(name, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// eventTime 事件时间, 新版事件API只设置EventTime, 此时使用EventTime
+func eventTime(event corev1.Event, t v1.Time) time.Time {
+	if t.IsZero() {
+		return event.EventTime.Time
+	}
+	return t.Time
+}
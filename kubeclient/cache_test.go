@@ -0,0 +1,80 @@
+package kubeclient
+
+import (
+	"slicer/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClusterCacheAndSliceEvents(t *testing.T) {
+	labels := map[string]string{"app": "open5gs", "nf": "upf", "slice": "1-000001", "name": "upf1-000001"}
+	event := func(name, kind, object, eventType string, last time.Time) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     v1.ObjectMeta{Name: name, Namespace: "open5gs"},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: object, Namespace: "open5gs"},
+			Type:           eventType,
+			Reason:         name,
+			LastTimestamp:  v1.NewTime(last),
+		}
+	}
+	now := time.Now()
+	clientset := fakeclientset.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "upf-a", Namespace: "open5gs", Labels: labels}},
+		&corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "monarch", Namespace: "open5gs", Labels: map[string]string{"app": "monarch"}}},
+		&appsv1.StatefulSet{ObjectMeta: v1.ObjectMeta{Name: "upf-pool", Namespace: "open5gs", Labels: labels}},
+		event("BackOff", "Pod", "upf-a", corev1.EventTypeWarning, now),
+		event("SuccessfulCreate", "StatefulSet", "upf-pool", corev1.EventTypeNormal, now.Add(-3*time.Minute)),
+		event("ScalingReplicaSet", "Deployment", "open5gs-upf1-000001", corev1.EventTypeNormal, now.Add(-time.Minute)),
+		event("FailedScheduling", "Pod", "open5gs-smf1-000001-7d9c-x2", corev1.EventTypeWarning, now.Add(-2*time.Minute)),
+		event("Other", "Pod", "open5gs-smf1-000002-7d9c-x2", corev1.EventTypeWarning, now),
+	)
+	kc := &KubeClient{
+		clientset: clientset,
+		config:    util.Config{KubeConfig: util.KubeConfig{Namespace: "open5gs"}},
+		cache:     newClusterCache(clientset, "open5gs"),
+	}
+	defer kc.Close()
+	require.Eventually(t, kc.cache.ready, 5*time.Second, 10*time.Millisecond)
+
+	// 缓存只包含app=open5gs的对象, 其余选择器直接访问API Server
+	pods, err := kc.GetPods("open5gs", "app=open5gs")
+	require.NoError(t, err)
+	require.Len(t, pods, 1)
+	assert.Equal(t, "upf-a", pods[0].Name)
+	pods, err = kc.GetPods("open5gs", "app=monarch")
+	require.NoError(t, err)
+	assert.Len(t, pods, 1)
+
+	events, err := kc.SliceEvents("1-000001", "")
+	require.NoError(t, err)
+	var reasons []string
+	for _, e := range events {
+		reasons = append(reasons, e.Reason)
+	}
+	assert.Equal(t, []string{"BackOff", "ScalingReplicaSet", "FailedScheduling", "SuccessfulCreate"}, reasons)
+
+	events, err = kc.SliceEvents("1-000001", corev1.EventTypeWarning)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	// 监听所有命名空间时不缓存事件, 按切片的命名空间直接查询
+	all := &KubeClient{
+		clientset: clientset,
+		config:    util.Config{KubeConfig: util.KubeConfig{Namespace: "open5gs"}},
+		cache:     newClusterCache(clientset, v1.NamespaceAll),
+	}
+	defer all.Close()
+	require.Eventually(t, all.cache.ready, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, all.cache.events)
+	events, err = all.SliceEvents("1-000001", "")
+	require.NoError(t, err)
+	assert.Len(t, events, 4)
+}
@@ -37,6 +37,8 @@ type KubeClient struct {
 	clientset     kubernetes.Interface // 核心API客户端
 	dynamicClient dynamic.Interface    // 动态资源客户端
 	restMapper    meta.RESTMapper      // 资源类型映射器
+	stream        podStreamer          // 在Pod中执行命令并流式返回输出, 用于读取日志文件
+	cache         *clusterCache        // open5gs资源及事件的informer缓存, 为nil时直接访问API Server
 }
 
 // NewKubeClient 创建Kubernetes客户端
@@ -55,7 +57,26 @@ func NewKubeClient(config util.Config) (kc *KubeClient, err error) {
 			return nil, fmt.Errorf("创建集群内配置失败: %v", err)
 		}
 	}
+	return newKubeClient(config, kconfig)
+}
 
+// NewKubeClientForContext 使用kubeconfig中指定上下文创建Kubernetes客户端
+// 未设置KubeconfigPath时按KUBECONFIG环境变量或~/.kube/config查找kubeconfig
+func NewKubeClientForContext(config util.Config, kubeContext string) (*KubeClient, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if config.KubeconfigPath != "" {
+		rules.ExplicitPath = config.KubeconfigPath
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	kconfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("创建上下文 %s 的Kubernetes配置失败: %v", kubeContext, err)
+	}
+	return newKubeClient(config, kconfig)
+}
+
+// newKubeClient 由rest配置创建客户端, 并确保config中的命名空间存在
+func newKubeClient(config util.Config, kconfig *rest.Config) (kc *KubeClient, err error) {
 	// clientset 用于核心API（如Pod、Service）
 	clientset, err := kubernetes.NewForConfig(kconfig)
 	if err != nil {
@@ -77,11 +98,13 @@ func NewKubeClient(config util.Config) (kc *KubeClient, err error) {
 	// restMapper 用于资源类型映射
 	restMapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
 
+	stream := newPodStreamer(kconfig, clientset)
 	kc = &KubeClient{
 		config:        config,
 		clientset:     clientset,
 		dynamicClient: dynamicClient,
 		restMapper:    restMapper,
+		stream:        stream,
 	}
 
 	// 获取所有namespaces, 作为测试
@@ -112,9 +135,21 @@ func NewKubeClient(config util.Config) (kc *KubeClient, err error) {
 		}
 	}
 
+	// 启动informer缓存, 同步完成前查询直接访问API Server
+	cacheNamespace := config.Namespace
+	if config.NamespacePerSlice {
+		cacheNamespace = v1.NamespaceAll
+	}
+	kc.cache = newClusterCache(clientset, cacheNamespace)
+
 	return
 }
 
+// Close 停止informer缓存, 之后的查询直接访问API Server
+func (kc *KubeClient) Close() {
+	kc.cache.close()
+}
+
 // GetNamespaces 获取所有命名空间
 func (kc *KubeClient) GetNamespaces() ([]corev1.Namespace, error) {
 	namespaces, err := kc.clientset.CoreV1().Namespaces().List(context.TODO(), v1.ListOptions{})
@@ -124,8 +159,14 @@ func (kc *KubeClient) GetNamespaces() ([]corev1.Namespace, error) {
 	return namespaces.Items, nil
 }
 
-// GetPods 获取指定命名空间下的Pod列表
+// GetPods 获取指定命名空间下的Pod列表, 选择器包含app=open5gs时从缓存获取
 func (kc *KubeClient) GetPods(namespace string, labelSelector ...string) ([]corev1.Pod, error) {
+	if items, ok, err := kc.cachedPods(namespace, labelSelector); ok {
+		if err != nil {
+			return nil, fmt.Errorf("获取Pod列表失败: %v", err)
+		}
+		return items, nil
+	}
 	pods, err := kc.clientset.CoreV1().Pods(namespace).List(context.TODO(), v1.ListOptions{
 		LabelSelector: strings.Join(labelSelector, ","),
 	})
@@ -135,8 +176,14 @@ func (kc *KubeClient) GetPods(namespace string, labelSelector ...string) ([]core
 	return pods.Items, nil
 }
 
-// GetServices 获取指定命名空间下的Service列表,支持标签选择器(可选)
+// GetServices 获取指定命名空间下的Service列表,支持标签选择器(可选), 选择器包含app=open5gs时从缓存获取
 func (kc *KubeClient) GetServices(namespace string, labelSelector ...string) ([]corev1.Service, error) {
+	if items, ok, err := kc.cachedServices(namespace, labelSelector); ok {
+		if err != nil {
+			return nil, fmt.Errorf("获取Service列表失败: %v", err)
+		}
+		return items, nil
+	}
 	// 使用标签选择器过滤Service
 	serviceList, err := kc.clientset.CoreV1().Services(namespace).List(context.TODO(), v1.ListOptions{
 		LabelSelector: strings.Join(labelSelector, ","),
@@ -147,7 +194,14 @@ func (kc *KubeClient) GetServices(namespace string, labelSelector ...string) ([]
 	return serviceList.Items, nil
 }
 
+// GetDeployments 获取指定命名空间下的Deployment列表, 选择器包含app=open5gs时从缓存获取
 func (kc *KubeClient) GetDeployments(namespace string, labelSelector ...string) ([]appsv1.Deployment, error) {
+	if items, ok, err := kc.cachedDeployments(namespace, labelSelector); ok {
+		if err != nil {
+			return nil, fmt.Errorf("获取Deployment列表失败: %v", err)
+		}
+		return items, nil
+	}
 	// 使用标签选择器过滤Deployment
 	deploymentList, err := kc.clientset.AppsV1().Deployments(namespace).List(context.TODO(), v1.ListOptions{
 		LabelSelector: strings.Join(labelSelector, ","),
@@ -158,6 +212,34 @@ func (kc *KubeClient) GetDeployments(namespace string, labelSelector ...string)
 	return deploymentList.Items, nil
 }
 
+// GetStatefulSets 获取StatefulSet, 如多副本的UPF
+func (kc *KubeClient) GetStatefulSets(namespace string, labelSelector ...string) ([]appsv1.StatefulSet, error) {
+	if items, ok, err := kc.cachedStatefulSets(namespace, labelSelector); ok {
+		if err != nil {
+			return nil, fmt.Errorf("获取StatefulSet列表失败: %v", err)
+		}
+		return items, nil
+	}
+	statefulSetList, err := kc.clientset.AppsV1().StatefulSets(namespace).List(context.TODO(), v1.ListOptions{
+		LabelSelector: strings.Join(labelSelector, ","),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取StatefulSet列表失败: %v", err)
+	}
+	return statefulSetList.Items, nil
+}
+
+func (kc *KubeClient) GetConfigMaps(namespace string, labelSelector ...string) ([]corev1.ConfigMap, error) {
+	// 使用标签选择器过滤ConfigMap
+	configMapList, err := kc.clientset.CoreV1().ConfigMaps(namespace).List(context.TODO(), v1.ListOptions{
+		LabelSelector: strings.Join(labelSelector, ","),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取ConfigMap列表失败: %v", err)
+	}
+	return configMapList.Items, nil
+}
+
 func (kc *KubeClient) GetNodes(labelSelector ...string) ([]corev1.Node, error) {
 	// 使用标签选择器过滤节点
 	nodeList, err := kc.clientset.CoreV1().Nodes().List(context.TODO(), v1.ListOptions{
@@ -171,6 +253,11 @@ func (kc *KubeClient) GetNodes(labelSelector ...string) ([]corev1.Node, error) {
 
 // Apply 将YAML配置应用到集群，支持多资源文档（以---分隔）
 func (kc *KubeClient) Apply(yamlData []byte, namespace string) error {
+	return kc.apply(yamlData, namespace, nil)
+}
+
+// apply 应用前为每个资源打上slicer的归属标签, labels为额外的标签
+func (kc *KubeClient) apply(yamlData []byte, namespace string, labels map[string]string) error {
 	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(yamlData), 100)
 	for {
 		var rawObj unstructured.Unstructured
@@ -181,16 +268,22 @@ func (kc *KubeClient) Apply(yamlData []byte, namespace string) error {
 			return fmt.Errorf("YAML解码失败: %v", err)
 		}
 
+		stampOwnership(&rawObj, labels)
+
 		gvk := rawObj.GroupVersionKind()
 		mapping, err := kc.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 		if err != nil {
 			return fmt.Errorf("资源映射失败（类型 %s）: %v", gvk, err)
 		}
 
-		// 动态判断是否需指定命名空间
+		// 动态判断是否需指定命名空间, YAML中定义了namespace时优先使用
 		var resourceClient dynamic.ResourceInterface
 		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
-			resourceClient = kc.dynamicClient.Resource(mapping.Resource).Namespace(namespace)
+			resNamespace := rawObj.GetNamespace()
+			if resNamespace == "" {
+				resNamespace = namespace
+			}
+			resourceClient = kc.dynamicClient.Resource(mapping.Resource).Namespace(resNamespace)
 		} else {
 			resourceClient = kc.dynamicClient.Resource(mapping.Resource)
 		}
@@ -232,8 +325,12 @@ func (kc *KubeClient) Apply(yamlData []byte, namespace string) error {
 }
 
 func (kc *KubeClient) ApplyMulti(yamlDatas [][]byte, namespace string) error {
+	return kc.applyMulti(yamlDatas, namespace, nil)
+}
+
+func (kc *KubeClient) applyMulti(yamlDatas [][]byte, namespace string, labels map[string]string) error {
 	for _, yamlData := range yamlDatas {
-		if err := kc.Apply(yamlData, namespace); err != nil {
+		if err := kc.apply(yamlData, namespace, labels); err != nil {
 			return fmt.Errorf("应用YAML失败: %v", err)
 		}
 	}
@@ -0,0 +1,307 @@
+package kubeclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slicer/model"
+	"slicer/util"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// DefaultCluster 默认集群的名称, 即KubeconfigPath的当前上下文或集群内配置
+// 未设置放置规则的切片, 以及共享NF、监控等均使用默认集群
+const DefaultCluster = "default"
+
+// clusterStatusTimeout 查询单个集群状态的超时时间
+const clusterStatusTimeout = 10 * time.Second
+
+// Clusters 已注册的集群及其客户端
+// 启动时无法连接的集群仍然注册, 在下次使用时重新连接
+type Clusters struct {
+	mu       sync.Mutex
+	config   util.Config
+	names    []string               // 默认集群在前, 其余按名称排序
+	contexts map[string]string      // 集群名称到kubeconfig上下文, 默认集群为空
+	clients  map[string]*KubeClient // 未连接的集群为nil
+}
+
+// NewClusters 创建默认集群和config.Clusters中各集群的客户端
+// 默认集群连接失败时返回错误, 其余集群连接失败只记录日志
+func NewClusters(config util.Config) (*Clusters, error) {
+	kc, err := NewKubeClient(config)
+	if err != nil {
+		return nil, err
+	}
+	c := &Clusters{
+		config:   config,
+		names:    []string{DefaultCluster},
+		contexts: map[string]string{DefaultCluster: ""},
+		clients:  map[string]*KubeClient{DefaultCluster: kc},
+	}
+
+	var names []string
+	for name := range config.Clusters {
+		if name == DefaultCluster {
+			return nil, fmt.Errorf("集群名称 %s 为默认集群保留", DefaultCluster)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.names = append(c.names, name)
+		c.contexts[name] = config.Clusters[name]
+		if _, err := c.connect(name); err != nil {
+			slog.Error("连接集群失败, 将在使用时重试", "cluster", name, "context", config.Clusters[name], "error", err)
+		}
+	}
+	return c, nil
+}
+
+// connect 创建集群的客户端
+func (c *Clusters) connect(name string) (*KubeClient, error) {
+	kc, err := NewKubeClientForContext(c.config, c.contexts[name])
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 并发重连时保留先连接的客户端
+	if existing := c.clients[name]; existing != nil {
+		kc.Close()
+		return existing, nil
+	}
+	c.clients[name] = kc
+	return kc, nil
+}
+
+// Close 关闭所有已连接集群的客户端
+func (c *Clusters) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, kc := range c.clients {
+		if kc != nil {
+			kc.Close()
+		}
+	}
+}
+
+// Names 所有已注册的集群名称, 默认集群在前
+func (c *Clusters) Names() []string {
+	return append([]string(nil), c.names...)
+}
+
+// Has 集群是否已注册
+func (c *Clusters) Has(name string) bool {
+	_, ok := c.contexts[name]
+	return ok
+}
+
+// Default 默认集群的客户端
+func (c *Clusters) Default() *KubeClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clients[DefaultCluster]
+}
+
+// Get 获取集群的客户端, name为空时返回默认集群, 未连接的集群先尝试重新连接
+func (c *Clusters) Get(name string) (*KubeClient, error) {
+	if name == "" {
+		name = DefaultCluster
+	}
+	if !c.Has(name) {
+		return nil, fmt.Errorf("集群 %s 未注册", name)
+	}
+	c.mu.Lock()
+	kc := c.clients[name]
+	c.mu.Unlock()
+	if kc != nil {
+		return kc, nil
+	}
+	kc, err := c.connect(name)
+	if err != nil {
+		return nil, fmt.Errorf("连接集群 %s 失败: %w", name, err)
+	}
+	slog.Info("已重新连接集群", "cluster", name)
+	return kc, nil
+}
+
+// ForSlice 获取切片所在集群的客户端
+func (c *Clusters) ForSlice(slice model.SliceAndAddress) (*KubeClient, error) {
+	return c.Get(slice.Cluster)
+}
+
+// Labels 集群的站点标签
+func (c *Clusters) Labels(name string) map[string]string {
+	return c.config.ClusterLabels[name]
+}
+
+// Resolve 按放置规则选择集群, 返回集群名称
+// 未设置规则时为默认集群; 按站点标签选择时, 在匹配的集群中取第一个已连接的(默认集群优先, 其余按名称)
+func (c *Clusters) Resolve(placement *model.Placement) (string, error) {
+	if placement == nil || (placement.Cluster == "" && len(placement.SiteSelector) == 0) {
+		return DefaultCluster, nil
+	}
+	if placement.Cluster != "" {
+		if !c.Has(placement.Cluster) {
+			return "", fmt.Errorf("集群 %s 未注册", placement.Cluster)
+		}
+		return placement.Cluster, nil
+	}
+
+	var matched []string
+	for _, name := range c.names {
+		if placement.Matches(c.Labels(name)) {
+			matched = append(matched, name)
+		}
+	}
+	if len(matched) == 0 {
+		return "", fmt.Errorf("没有集群匹配站点标签 %v", placement.SiteSelector)
+	}
+	for _, name := range matched {
+		if _, err := c.Get(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("匹配站点标签 %v 的集群 %v 均无法连接", placement.SiteSelector, matched)
+}
+
+// ClusterStatus 集群的连接状态与容量
+type ClusterStatus struct {
+	Name      string            `json:"name"`
+	Context   string            `json:"context,omitempty"` // kubeconfig上下文, 默认集群为空
+	Labels    map[string]string `json:"labels,omitempty"`  // 站点标签
+	Default   bool              `json:"default"`
+	Connected bool              `json:"connected"`
+	Version   string            `json:"version,omitempty"` // Kubernetes版本
+	Error     string            `json:"error,omitempty"`   // 连接或查询失败的原因
+	Capacity  *ClusterCapacity  `json:"capacity,omitempty"`
+}
+
+// ClusterCapacity 集群中节点可分配的资源及已被Pod请求的资源
+type ClusterCapacity struct {
+	Nodes       int             `json:"nodes"`
+	ReadyNodes  int             `json:"ready_nodes"`
+	Allocatable ClusterResource `json:"allocatable"` // 就绪节点的可分配资源之和
+	Requested   ClusterResource `json:"requested"`   // 未结束的Pod请求的资源之和
+}
+
+// ClusterResource 集群的资源总量
+type ClusterResource struct {
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+	Pods   int64  `json:"pods"`
+}
+
+// Status 查询所有集群的连接状态与容量, 各集群并行查询
+func (c *Clusters) Status() []ClusterStatus {
+	statuses := make([]ClusterStatus, len(c.names))
+	var wg sync.WaitGroup
+	for i, name := range c.names {
+		statuses[i] = ClusterStatus{
+			Name:    name,
+			Context: c.contexts[name],
+			Labels:  c.Labels(name),
+			Default: name == DefaultCluster,
+		}
+		wg.Add(1)
+		go func(status *ClusterStatus) {
+			defer wg.Done()
+			kc, err := c.Get(status.Name)
+			if err != nil {
+				status.Error = err.Error()
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), clusterStatusTimeout)
+			defer cancel()
+			info, err := kc.serverVersion(ctx)
+			if err != nil {
+				status.Error = fmt.Sprintf("获取集群版本失败: %v", err)
+				return
+			}
+			status.Connected = true
+			status.Version = info.GitVersion
+			capacity, err := kc.Capacity(ctx)
+			if err != nil {
+				status.Error = err.Error()
+				return
+			}
+			status.Capacity = &capacity
+		}(&statuses[i])
+	}
+	wg.Wait()
+	return statuses
+}
+
+// serverVersion 获取API Server版本, 与Discovery().ServerVersion()相同但受ctx的超时约束
+func (kc *KubeClient) serverVersion(ctx context.Context) (*version.Info, error) {
+	body, err := kc.clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("解析集群版本失败: %w", err)
+	}
+	return &info, nil
+}
+
+// Capacity 统计集群节点的可分配资源及Pod已请求的资源
+func (kc *KubeClient) Capacity(ctx context.Context) (ClusterCapacity, error) {
+	var capacity ClusterCapacity
+	nodes, err := kc.clientset.CoreV1().Nodes().List(ctx, v1.ListOptions{})
+	if err != nil {
+		return capacity, fmt.Errorf("获取节点列表失败: %w", err)
+	}
+	allocCPU, allocMemory := resource.Quantity{}, resource.Quantity{}
+	for _, node := range nodes.Items {
+		capacity.Nodes++
+		if !nodeReady(node) {
+			continue
+		}
+		capacity.ReadyNodes++
+		allocCPU.Add(node.Status.Allocatable[corev1.ResourceCPU])
+		allocMemory.Add(node.Status.Allocatable[corev1.ResourceMemory])
+		capacity.Allocatable.Pods += node.Status.Allocatable.Pods().Value()
+	}
+	capacity.Allocatable.CPU, capacity.Allocatable.Memory = allocCPU.String(), allocMemory.String()
+
+	pods, err := kc.clientset.CoreV1().Pods("").List(ctx, v1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return capacity, fmt.Errorf("获取Pod列表失败: %w", err)
+	}
+	reqCPU, reqMemory := resource.Quantity{}, resource.Quantity{}
+	for _, pod := range pods.Items {
+		// 部分fake客户端不支持字段选择器, 再过滤一次
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		capacity.Requested.Pods++
+		for _, container := range pod.Spec.Containers {
+			reqCPU.Add(container.Resources.Requests[corev1.ResourceCPU])
+			reqMemory.Add(container.Resources.Requests[corev1.ResourceMemory])
+		}
+	}
+	capacity.Requested.CPU, capacity.Requested.Memory = reqCPU.String(), reqMemory.String()
+	return capacity, nil
+}
+
+// nodeReady 节点的Ready状态是否为True
+func nodeReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
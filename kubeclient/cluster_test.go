@@ -0,0 +1,99 @@
+package kubeclient
+
+import (
+	"context"
+	"slicer/model"
+	"slicer/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClustersResolve(t *testing.T) {
+	config := util.Config{KubeConfig: util.KubeConfig{
+		Clusters: map[string]string{"edge1": "ctx-edge1", "edge2": "ctx-edge2"},
+		ClusterLabels: map[string]map[string]string{
+			"edge1": {"site": "beijing", "zone": "a"},
+			"edge2": {"site": "beijing", "zone": "b"},
+		},
+	}}
+	c := &Clusters{
+		config:   config,
+		names:    []string{DefaultCluster, "edge1", "edge2"},
+		contexts: map[string]string{DefaultCluster: "", "edge1": "ctx-edge1", "edge2": "ctx-edge2"},
+		clients: map[string]*KubeClient{
+			DefaultCluster: {clientset: fakeclientset.NewSimpleClientset()},
+			"edge1":        {clientset: fakeclientset.NewSimpleClientset()},
+			"edge2":        {clientset: fakeclientset.NewSimpleClientset()},
+		},
+	}
+
+	for _, tc := range []struct {
+		placement *model.Placement
+		want      string
+	}{
+		{nil, DefaultCluster},
+		{&model.Placement{Cluster: "edge2"}, "edge2"},
+		{&model.Placement{SiteSelector: map[string]string{"site": "beijing"}}, "edge1"},
+		{&model.Placement{SiteSelector: map[string]string{"site": "beijing", "zone": "b"}}, "edge2"},
+	} {
+		got, err := c.Resolve(tc.placement)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got)
+	}
+
+	_, err := c.Resolve(&model.Placement{Cluster: "edge3"})
+	assert.Error(t, err)
+	_, err = c.Resolve(&model.Placement{SiteSelector: map[string]string{"site": "shanghai"}})
+	assert.Error(t, err)
+
+	kc, err := c.ForSlice(model.SliceAndAddress{Cluster: "edge1"})
+	require.NoError(t, err)
+	assert.Same(t, c.clients["edge1"], kc)
+}
+
+func TestCapacity(t *testing.T) {
+	node := func(name string, ready corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("4"),
+					corev1.ResourceMemory: resource.MustParse("8Gi"),
+					corev1.ResourcePods:   resource.MustParse("110"),
+				},
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			},
+		}
+	}
+	pod := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "open5gs"},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "main",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("512Mi"),
+				}},
+			}}},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+	kc := &KubeClient{clientset: fakeclientset.NewSimpleClientset(
+		node("n1", corev1.ConditionTrue), node("n2", corev1.ConditionTrue), node("n3", corev1.ConditionFalse),
+		pod("p1", corev1.PodRunning), pod("p2", corev1.PodPending), pod("p3", corev1.PodSucceeded),
+	)}
+
+	capacity, err := kc.Capacity(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, 3, capacity.Nodes)
+	assert.Equal(t, 2, capacity.ReadyNodes)
+	assert.Equal(t, ClusterResource{CPU: "8", Memory: "16Gi", Pods: 220}, capacity.Allocatable)
+	assert.Equal(t, ClusterResource{CPU: "1", Memory: "1Gi", Pods: 2}, capacity.Requested)
+}
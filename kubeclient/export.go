@@ -0,0 +1,61 @@
+package kubeclient
+
+import (
+	"bytes"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// ExportSlice 导出集群中切片当前实际运行的资源(ConfigMap/Deployment/Service), 格式为多文档YAML
+// 用于归档, 会去除managedFields和status等运行时字段
+func (kc *KubeClient) ExportSlice(sliceID, namespace string) ([]byte, error) {
+	selector := "slice=" + sliceID
+
+	var objs []runtime.Object
+	configmaps, err := kc.GetConfigMaps(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	for i := range configmaps {
+		cm := &configmaps[i]
+		cm.APIVersion, cm.Kind = "v1", "ConfigMap"
+		cm.ManagedFields = nil
+		objs = append(objs, cm)
+	}
+
+	deployments, err := kc.GetDeployments(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	for i := range deployments {
+		dep := &deployments[i]
+		dep.APIVersion, dep.Kind = "apps/v1", "Deployment"
+		dep.ManagedFields = nil
+		dep.Status.Reset()
+		objs = append(objs, dep)
+	}
+
+	services, err := kc.GetServices(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	for i := range services {
+		svc := &services[i]
+		svc.APIVersion, svc.Kind = "v1", "Service"
+		svc.ManagedFields = nil
+		objs = append(objs, svc)
+	}
+
+	var buf bytes.Buffer
+	for _, obj := range objs {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("序列化资源失败: %v", err)
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,240 @@
+package kubeclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slicer/model"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// 日志来源
+const (
+	LogSourceContainer = "container" // 容器的标准输出
+	LogSourceFile      = "file"      // Open5GS写入的日志文件, 通过exec tail读取
+)
+
+// defaultLogFileTail 读取日志文件且未指定行数时返回的末尾行数
+const defaultLogFileTail = 100
+
+// Open5GS的日志级别, 由高到低
+var logLevels = []string{"fatal", "error", "warn", "info", "debug", "trace"}
+
+// open5gsLogLevel 匹配Open5GS日志行中的级别, 如 "10/18 12:00:00.123: [smf] INFO: ..."
+var open5gsLogLevel = regexp.MustCompile(`\] (FATAL|ERROR|WARNING|INFO|DEBUG|TRACE): `)
+
+// LogOptions 读取切片NF日志的参数
+type LogOptions struct {
+	NF        string        // smf或upf
+	Pod       string        // 可选, 只读取该Pod, 默认读取NF的所有Pod
+	Source    string        // container(默认)或file
+	File      string        // 日志文件路径, Source为file时使用, 默认 /open5gs/install/var/log/open5gs/<nf>.log
+	Follow    bool          // 持续输出新日志
+	Since     time.Duration // 只返回该时间段内的日志, 仅支持容器日志
+	TailLines int64         // 只返回末尾的行数, 为0时容器日志返回全部, 日志文件返回100行
+	Level     string        // 最低日志级别, 如warn时只返回fatal/error/warn, 无级别的行(如堆栈)跟随上一行
+}
+
+// Validate 校验日志参数并填充默认值
+func (o *LogOptions) Validate() error {
+	if !slices.Contains(sliceNFs, o.NF) {
+		return fmt.Errorf("nf只能为 %s", strings.Join(sliceNFs, "/"))
+	}
+	switch o.Source {
+	case "":
+		o.Source = LogSourceContainer
+	case LogSourceContainer:
+	case LogSourceFile:
+		if o.Since > 0 {
+			return fmt.Errorf("since仅支持容器日志")
+		}
+		if o.File == "" {
+			o.File = "/open5gs/install/var/log/open5gs/" + o.NF + ".log"
+		}
+	default:
+		return fmt.Errorf("不支持的日志来源: %s", o.Source)
+	}
+	if o.Level != "" && !slices.Contains(logLevels, o.Level) {
+		return fmt.Errorf("日志级别只能为 %s", strings.Join(logLevels, "/"))
+	}
+	if o.Since < 0 || o.TailLines < 0 {
+		return fmt.Errorf("since和tail不能为负数")
+	}
+	return nil
+}
+
+// StreamSliceLogs 将切片NF的日志逐行写入w, 直到日志结束, 或Follow时ctx取消
+// 有多个Pod(如多副本UPF)时并行读取, 每行前加上 [Pod名称]
+// 开始输出前的错误(参数非法、找不到Pod)直接返回, 此时w中没有任何内容
+func (kc *KubeClient) StreamSliceLogs(ctx context.Context, sliceID string, opts LogOptions, w io.Writer) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	namespace := kc.config.SliceNamespace(sliceID)
+	pods, err := kc.GetPods(namespace, sliceSelector(sliceID), "nf="+opts.NF)
+	if err != nil {
+		return err
+	}
+	if opts.Pod != "" {
+		pods = slices.DeleteFunc(pods, func(pod corev1.Pod) bool { return pod.Name != opts.Pod })
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("切片 %s 中没有 %s 的Pod", sliceID, opts.NF)
+	}
+	slices.SortFunc(pods, func(a, b corev1.Pod) int { return strings.Compare(a.Name, b.Name) })
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, len(pods))
+	for i, pod := range pods {
+		prefix := ""
+		if len(pods) > 1 {
+			prefix = "[" + pod.Name + "] "
+		}
+		filter := newLevelFilter(opts.Level)
+		emit := func(line string) error {
+			if !filter(line) {
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			_, err := io.WriteString(w, prefix+line+"\n")
+			return err
+		}
+		run := func() {
+			errs[i] = kc.streamPodLogs(ctx, namespace, pod, sliceID, opts, emit)
+		}
+		// 不持续输出时按Pod依次输出, 避免不同Pod的日志交错
+		if !opts.Follow {
+			run()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run()
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("读取Pod %s 的日志失败: %w", pods[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// streamPodLogs 读取单个Pod的容器日志或日志文件, 每行调用一次emit
+func (kc *KubeClient) streamPodLogs(ctx context.Context, namespace string, pod corev1.Pod, sliceID string, opts LogOptions, emit func(string) error) error {
+	container := ""
+	if c := findContainer(&pod.Spec, model.NFPlay{}.ContainerNames(opts.NF, sliceID)); c != nil {
+		container = c.Name
+	} else if len(pod.Spec.Containers) > 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+
+	if opts.Source == LogSourceFile {
+		tail := opts.TailLines
+		if tail == 0 {
+			tail = defaultLogFileTail
+		}
+		command := []string{"tail", "-n", strconv.FormatInt(tail, 10)}
+		if opts.Follow {
+			command = append(command, "-F")
+		}
+		command = append(command, opts.File)
+
+		reader, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(kc.stream(ctx, namespace, pod.Name, container, command, writer))
+		}()
+		defer reader.Close()
+		return scanLines(reader, emit)
+	}
+
+	logOptions := &corev1.PodLogOptions{Container: container, Follow: opts.Follow}
+	if opts.Since > 0 {
+		seconds := int64(opts.Since.Seconds())
+		logOptions.SinceSeconds = &seconds
+	}
+	if opts.TailLines > 0 {
+		logOptions.TailLines = &opts.TailLines
+	}
+	stream, err := kc.clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, logOptions).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	return scanLines(stream, emit)
+}
+
+// scanLines 逐行读取r直到结束
+func scanLines(r io.Reader, emit func(string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := emit(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// newLevelFilter 返回按最低级别过滤日志行的函数, level为空时不过滤
+// 无级别的行与上一行的结果相同, 使多行日志保持完整
+func newLevelFilter(level string) func(string) bool {
+	if level == "" {
+		return func(string) bool { return true }
+	}
+	limit := slices.Index(logLevels, level)
+	keep := true
+	return func(line string) bool {
+		if m := open5gsLogLevel.FindStringSubmatch(line); m != nil {
+			name := strings.ToLower(m[1])
+			if name == "warning" {
+				name = "warn"
+			}
+			keep = slices.Index(logLevels, name) <= limit
+		}
+		return keep
+	}
+}
+
+// podStreamer 在Pod的容器中执行命令, 标准输出持续写入stdout, 直到命令结束或ctx取消
+type podStreamer func(ctx context.Context, namespace, pod, container string, command []string, stdout io.Writer) error
+
+// newPodStreamer 通过API Server的exec子资源执行命令并流式返回输出
+func newPodStreamer(config *rest.Config, clientset kubernetes.Interface) podStreamer {
+	return func(ctx context.Context, namespace, pod, container string, command []string, stdout io.Writer) error {
+		req := clientset.CoreV1().RESTClient().Post().
+			Resource("pods").Namespace(namespace).Name(pod).SubResource("exec").
+			VersionedParams(&corev1.PodExecOptions{
+				Container: container,
+				Command:   command,
+				Stdout:    true,
+				Stderr:    true,
+			}, scheme.ParameterCodec)
+		executor, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+		if err != nil {
+			return fmt.Errorf("创建exec连接失败: %w", err)
+		}
+		var stderr bytes.Buffer
+		if err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: stdout, Stderr: &stderr}); err != nil {
+			return fmt.Errorf("%s: %w %s", strings.Join(command, " "), err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+}
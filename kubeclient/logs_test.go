@@ -0,0 +1,61 @@
+package kubeclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slicer/util"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStreamSliceLogs(t *testing.T) {
+	pod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "open5gs", Labels: map[string]string{"app": "open5gs", "nf": "upf", "slice": "1-000001"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "upf"}}},
+		}
+	}
+	var commands []string
+	kc := &KubeClient{
+		clientset: fakeclientset.NewSimpleClientset(pod("upf-0"), pod("upf-1")),
+		config:    util.Config{KubeConfig: util.KubeConfig{Namespace: "open5gs"}},
+		stream: func(ctx context.Context, namespace, pod, container string, command []string, stdout io.Writer) error {
+			commands = append(commands, container+": "+strings.Join(command, " "))
+			_, err := io.WriteString(stdout, strings.Join([]string{
+				"10/18 12:00:00.001: [upf] INFO: pfcp associated (../src/upf/pfcp-sm.c:184)",
+				"10/18 12:00:01.002: [upf] WARNING: no session (../src/upf/n4-handler.c:61)",
+				"  detail of warning",
+				"10/18 12:00:02.003: [upf] DEBUG: heartbeat (../src/upf/pfcp-sm.c:200)",
+			}, "\n"))
+			return err
+		},
+	}
+
+	var out bytes.Buffer
+	opts := LogOptions{NF: "upf", Source: LogSourceFile, Level: "warn", TailLines: 20}
+	require.NoError(t, kc.StreamSliceLogs(context.TODO(), "1-000001", opts, &out))
+	assert.Equal(t, []string{
+		"upf: tail -n 20 /open5gs/install/var/log/open5gs/upf.log",
+		"upf: tail -n 20 /open5gs/install/var/log/open5gs/upf.log",
+	}, commands)
+	assert.Equal(t, strings.Join([]string{
+		"[upf-0] 10/18 12:00:01.002: [upf] WARNING: no session (../src/upf/n4-handler.c:61)",
+		"[upf-0]   detail of warning",
+		"[upf-1] 10/18 12:00:01.002: [upf] WARNING: no session (../src/upf/n4-handler.c:61)",
+		"[upf-1]   detail of warning",
+	}, "\n")+"\n", out.String())
+
+	// 参数非法和找不到Pod时不输出任何内容
+	out.Reset()
+	assert.Error(t, kc.StreamSliceLogs(context.TODO(), "1-000001", LogOptions{NF: "upf", Source: LogSourceFile, Since: 1}, &out))
+	assert.Error(t, kc.StreamSliceLogs(context.TODO(), "1-000001", LogOptions{NF: "smf"}, &out))
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,218 @@
+package kubeclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"slicer/util"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// nadResource Multus的NetworkAttachmentDefinition
+var nadResource = schema.GroupVersionResource{Group: "k8s.cni.cncf.io", Version: "v1", Resource: "network-attachment-definitions"}
+
+// NADSubnetAnnotation 记录NAD对应的网络, 用于发现与IPAM配置不一致的NAD
+const NADSubnetAnnotation = "slicer.io/subnet"
+
+// ScaledNetworkSuffix 多副本UPF使用的不带IPAM的NAD名称后缀, 与UPF模板一致
+const ScaledNetworkSuffix = "-noipam"
+
+// 可用作NAD和接口名称的DNN, 接口名称n6-<dnn>不能超过15个字符
+var dnnNADPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,10}[a-z0-9])?$`)
+
+// NetworkSpec slicer管理的一个Multus网络
+type NetworkSpec struct {
+	Name   string // NAD名称, 与模板中Multus注解的name一致
+	Type   string // macvlan或ipvlan
+	Master string // 主机接口
+	Subnet string // 网络的CIDR, 为空时不校验
+	NoIPAM bool   // 不配置IPAM, 地址由Pod自行配置, 用于多副本UPF
+	DHCP   bool   // 地址由所在网络的DHCP分配, 用于N6网络
+}
+
+// cniConfig NAD中CNI配置里slicer关心的字段
+type cniConfig struct {
+	CNIVersion   string          `json:"cniVersion"`
+	Type         string          `json:"type"`
+	Master       string          `json:"master"`
+	Mode         string          `json:"mode,omitempty"`
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+	IPAM         struct {
+		Type string `json:"type,omitempty"`
+	} `json:"ipam"`
+}
+
+// Config 生成NAD的CNI配置, 使用static IPAM, 地址由Pod的Multus注解指定
+// NoIPAM时IPAM为空, Multus只创建接口; DHCP时使用dhcp IPAM
+func (n NetworkSpec) Config() string {
+	cfg := cniConfig{
+		CNIVersion: "0.3.1",
+		Type:       n.Type,
+		Master:     n.Master,
+	}
+	switch n.Type {
+	case "macvlan":
+		cfg.Mode = "bridge"
+	case "ipvlan":
+		cfg.Mode = "l2"
+	}
+	switch {
+	case n.DHCP:
+		cfg.IPAM.Type = "dhcp"
+	case !n.NoIPAM:
+		cfg.Capabilities = map[string]bool{"ips": true}
+		cfg.IPAM.Type = "static"
+	}
+	data, _ := json.Marshal(cfg)
+	return string(data)
+}
+
+// diff 返回已有NAD与期望不一致的地方
+// 他人创建的NAD(如仓库中的参考NAD)可能未声明ips能力, 只对slicer管理的NAD检查
+func (n NetworkSpec) diff(obj *unstructured.Unstructured, managed bool) []string {
+	raw, _, _ := unstructured.NestedString(obj.Object, "spec", "config")
+	var cfg cniConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return []string{fmt.Sprintf("CNI配置不是合法的JSON: %v", err)}
+	}
+	var diffs []string
+	if cfg.Type != n.Type {
+		diffs = append(diffs, fmt.Sprintf("type为%q, 应为%q", cfg.Type, n.Type))
+	}
+	if cfg.Master != n.Master {
+		diffs = append(diffs, fmt.Sprintf("master为%q, 应为%q", cfg.Master, n.Master))
+	}
+	switch {
+	case n.NoIPAM:
+		if cfg.IPAM.Type != "" {
+			diffs = append(diffs, fmt.Sprintf("ipam为%q, 应为空", cfg.IPAM.Type))
+		}
+	case n.DHCP:
+		if cfg.IPAM.Type != "dhcp" {
+			diffs = append(diffs, fmt.Sprintf("ipam为%q, 应为dhcp", cfg.IPAM.Type))
+		}
+	case cfg.IPAM.Type != "static":
+		diffs = append(diffs, fmt.Sprintf("ipam为%q, 应为static", cfg.IPAM.Type))
+	case managed && !cfg.Capabilities["ips"]:
+		diffs = append(diffs, "未启用ips能力, 无法指定静态地址")
+	}
+	if subnet := obj.GetAnnotations()[NADSubnetAnnotation]; n.Subnet != "" && subnet != "" && subnet != n.Subnet {
+		diffs = append(diffs, fmt.Sprintf("网络为%s, 应为%s", subnet, n.Subnet))
+	}
+	return diffs
+}
+
+// NetworkStatus 一个Multus网络的协调结果
+type NetworkStatus struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`           // created, updated, unchanged 或 mismatch
+	Detail string `json:"detail,omitempty"` // 不一致之处
+}
+
+// NetworkSpecs 由IPAM配置生成slicer管理的Multus网络, 未配置NAD_MASTER和N6_NETWORKS时返回空
+func NetworkSpecs(config util.IPAMConfig) ([]NetworkSpec, error) {
+	nadType := config.NADType
+	switch nadType {
+	case "":
+		nadType = "macvlan"
+	case "macvlan", "ipvlan":
+	default:
+		return nil, fmt.Errorf("不支持的NAD类型 %q", nadType)
+	}
+
+	var specs []NetworkSpec
+	if config.NADMaster != "" {
+		for name, subnet := range map[string]string{"n3network": config.N3Network, "n4network": config.N4Network} {
+			if _, _, err := net.ParseCIDR(subnet); err != nil {
+				return nil, fmt.Errorf("%s的网络 %q 不合法: %w", name, subnet, err)
+			}
+			specs = append(specs,
+				NetworkSpec{Name: name, Type: nadType, Master: config.NADMaster, Subnet: subnet},
+				// 多副本UPF的Pod由wrapper.sh按序号配置地址, 使用不带IPAM的网络
+				NetworkSpec{Name: name + ScaledNetworkSuffix, Type: nadType, Master: config.NADMaster, Subnet: subnet, NoIPAM: true})
+		}
+	}
+	for dnn, master := range config.N6Networks {
+		if !dnnNADPattern.MatchString(dnn) {
+			return nil, fmt.Errorf("DNN %q 不能用作NAD和接口名称(小写字母、数字和-, 不超过12个字符)", dnn)
+		}
+		if master == "" {
+			return nil, fmt.Errorf("DNN %s 的N6网络缺少主机接口", dnn)
+		}
+		specs = append(specs, NetworkSpec{Name: util.N6NetworkName(dnn), Type: nadType, Master: master, DHCP: true})
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs, nil
+}
+
+// ReconcileNetworks 在slicer的命名空间中创建或更新NAD
+// slicer创建的NAD与配置不一致时更新; 他人创建的NAD不做修改, 只报告不一致之处, 此时返回错误
+func (kc *KubeClient) ReconcileNetworks(specs []NetworkSpec) ([]NetworkStatus, error) {
+	ctx := context.TODO()
+	client := kc.dynamicClient.Resource(nadResource).Namespace(kc.config.Namespace)
+	statuses := make([]NetworkStatus, 0, len(specs))
+	var errs []error
+	for _, spec := range specs {
+		status := NetworkStatus{Name: spec.Name}
+		existing, err := client.Get(ctx, spec.Name, v1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			obj := &unstructured.Unstructured{}
+			obj.SetAPIVersion("k8s.cni.cncf.io/v1")
+			obj.SetKind("NetworkAttachmentDefinition")
+			obj.SetName(spec.Name)
+			obj.SetNamespace(kc.config.Namespace)
+			obj.SetLabels(map[string]string{ManagedByLabel: ManagedByValue})
+			if spec.Subnet != "" {
+				obj.SetAnnotations(map[string]string{NADSubnetAnnotation: spec.Subnet})
+			}
+			if err := unstructured.SetNestedField(obj.Object, spec.Config(), "spec", "config"); err != nil {
+				return statuses, err
+			}
+			if _, err := client.Create(ctx, obj, v1.CreateOptions{}); err != nil {
+				return statuses, fmt.Errorf("创建NAD %s 失败: %w", spec.Name, err)
+			}
+			status.Action = "created"
+		case err != nil:
+			return statuses, fmt.Errorf("获取NAD %s 失败: %w", spec.Name, err)
+		default:
+			managed := existing.GetLabels()[ManagedByLabel] == ManagedByValue
+			diffs := spec.diff(existing, managed)
+			status.Detail = strings.Join(diffs, "; ")
+			switch {
+			case len(diffs) == 0:
+				status.Action = "unchanged"
+			case !managed:
+				status.Action = "mismatch"
+				errs = append(errs, fmt.Errorf("NAD %s 与配置不一致: %s", spec.Name, status.Detail))
+			default:
+				if err := unstructured.SetNestedField(existing.Object, spec.Config(), "spec", "config"); err != nil {
+					return statuses, err
+				}
+				if spec.Subnet != "" {
+					annotations := existing.GetAnnotations()
+					if annotations == nil {
+						annotations = make(map[string]string)
+					}
+					annotations[NADSubnetAnnotation] = spec.Subnet
+					existing.SetAnnotations(annotations)
+				}
+				if _, err := client.Update(ctx, existing, v1.UpdateOptions{}); err != nil {
+					return statuses, fmt.Errorf("更新NAD %s 失败: %w", spec.Name, err)
+				}
+				status.Action = "updated"
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, kerrors.NewAggregate(errs)
+}
@@ -0,0 +1,98 @@
+package kubeclient
+
+import (
+	"context"
+	"slicer/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestReconcileNetworks(t *testing.T) {
+	nad := func(name, config string, managed bool) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("k8s.cni.cncf.io/v1")
+		obj.SetKind("NetworkAttachmentDefinition")
+		obj.SetName(name)
+		obj.SetNamespace("open5gs")
+		if managed {
+			obj.SetLabels(map[string]string{ManagedByLabel: ManagedByValue})
+		}
+		require.NoError(t, unstructured.SetNestedField(obj.Object, config, "spec", "config"))
+		return obj
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{nadResource: "NetworkAttachmentDefinitionList"})
+	client := dynamicClient.Resource(nadResource).Namespace("open5gs")
+	for _, obj := range []*unstructured.Unstructured{
+		// 手工创建的参考NAD, 未声明ips能力, 不视为不一致
+		nad("n3network", `{"cniVersion":"0.3.1","type":"macvlan","master":"eth1","mode":"bridge","ipam":{"type":"static"}}`, false),
+		// 手工创建, master与配置不一致
+		nad("n4network", `{"cniVersion":"0.3.1","type":"macvlan","master":"eth0","ipam":{"type":"static"}}`, false),
+		// slicer创建, 类型已修改
+		nad("n3network-noipam", `{"cniVersion":"0.3.1","type":"ipvlan","master":"eth1","ipam":{}}`, true),
+		// slicer创建, IPAM已修改
+		nad("n6network-internet", `{"cniVersion":"0.3.1","type":"macvlan","master":"eth2","capabilities":{"ips":true},"ipam":{"type":"static"}}`, true),
+	} {
+		_, err := client.Create(context.TODO(), obj, v1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	config := util.Config{}
+	config.Namespace = "open5gs"
+	kc := &KubeClient{config: config, dynamicClient: dynamicClient}
+
+	specs, err := NetworkSpecs(util.IPAMConfig{
+		N3Network:  "10.10.3.0/24",
+		N4Network:  "10.10.4.0/24",
+		NADMaster:  "eth1",
+		N6Networks: map[string]string{"internet": "eth2"},
+	})
+	require.NoError(t, err)
+	require.Len(t, specs, 5)
+
+	statuses, err := kc.ReconcileNetworks(specs)
+	require.Error(t, err)
+	assert.Equal(t, []NetworkStatus{
+		{Name: "n3network", Action: "unchanged"},
+		{Name: "n3network-noipam", Action: "updated", Detail: `type为"ipvlan", 应为"macvlan"`},
+		{Name: "n4network", Action: "mismatch", Detail: `master为"eth0", 应为"eth1"`},
+		{Name: "n4network-noipam", Action: "created"},
+		{Name: "n6network-internet", Action: "updated", Detail: `ipam为"static", 应为dhcp`},
+	}, statuses)
+
+	// 再次协调时无需修改
+	n4, err := client.Get(context.TODO(), "n4network-noipam", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "10.10.4.0/24", n4.GetAnnotations()[NADSubnetAnnotation])
+	statuses, err = kc.ReconcileNetworks(specs[:2])
+	require.NoError(t, err)
+	assert.Equal(t, "unchanged", statuses[0].Action)
+	assert.Equal(t, "unchanged", statuses[1].Action)
+
+	// 多副本UPF使用的NAD不带IPAM
+	n3Scaled, err := client.Get(context.TODO(), "n3network-noipam", v1.GetOptions{})
+	require.NoError(t, err)
+	raw, _, _ := unstructured.NestedString(n3Scaled.Object, "spec", "config")
+	assert.JSONEq(t, `{"cniVersion":"0.3.1","type":"macvlan","master":"eth1","mode":"bridge","ipam":{}}`, raw)
+
+	// N6网络的地址由数据网络的DHCP分配
+	n6, err := client.Get(context.TODO(), "n6network-internet", v1.GetOptions{})
+	require.NoError(t, err)
+	raw, _, _ = unstructured.NestedString(n6.Object, "spec", "config")
+	assert.JSONEq(t, `{"cniVersion":"0.3.1","type":"macvlan","master":"eth2","mode":"bridge","ipam":{"type":"dhcp"}}`, raw)
+
+	_, err = NetworkSpecs(util.IPAMConfig{NADMaster: "eth1", NADType: "bridge"})
+	assert.Error(t, err)
+	// DNN需能用作接口名称
+	_, err = NetworkSpecs(util.IPAMConfig{N6Networks: map[string]string{"Internet_1": "eth2"}})
+	assert.Error(t, err)
+	_, err = NetworkSpecs(util.IPAMConfig{N6Networks: map[string]string{"enterprise-lan": "eth2"}})
+	assert.Error(t, err)
+}
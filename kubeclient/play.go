@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"slicer/model"
+	"sort"
+	"strings"
 
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 
 	networkingv1 "k8s.io/api/networking/v1"
 	schedulingv1 "k8s.io/api/scheduling/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -37,110 +40,512 @@ import (
 // 	Annotations map[string]string `json:"annotations"`
 // }
 
-func (kc *KubeClient) Play(play model.Play, namespace string) error {
-	deploymentName := fmt.Sprintf("open5gs-upf%s", play.SliceID)
+// PlayError Play应用失败, Report中记录了已恢复的变更
+type PlayError struct {
+	Report model.PlayReport
+	Err    error
+}
+
+func (e *PlayError) Error() string {
+	msg := e.Err.Error()
+	if len(e.Report.Reverted) > 0 {
+		msg += fmt.Sprintf(", 已恢复: %s", strings.Join(e.Report.Reverted, "; "))
+	}
+	if len(e.Report.RevertErrors) > 0 {
+		msg += fmt.Sprintf(", 恢复失败: %s", strings.Join(e.Report.RevertErrors, "; "))
+	}
+	return msg
+}
+
+func (e *PlayError) Unwrap() error {
+	return e.Err
+}
+
+// playTxn 记录Play已执行的变更及其恢复操作
+type playTxn struct {
+	report model.PlayReport
+	undo   []playUndo
+}
+
+type playUndo struct {
+	step string
+	fn   func() error
+}
+
+// done 记录一个已执行的变更, undo为nil时表示无需恢复
+func (t *playTxn) done(step string, undo func() error) {
+	t.report.Applied = append(t.report.Applied, step)
+	if undo != nil {
+		t.undo = append(t.undo, playUndo{step: step, fn: undo})
+	}
+}
+
+// fail 逆序恢复已执行的变更, 返回*PlayError
+func (t *playTxn) fail(step string, err error) error {
+	err = fmt.Errorf("%s失败: %v", step, err)
+	t.report.Failed = err.Error()
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		u := t.undo[i]
+		if undoErr := u.fn(); undoErr != nil {
+			t.report.RevertErrors = append(t.report.RevertErrors, fmt.Sprintf("%s: %v", u.step, undoErr))
+		} else {
+			t.report.Reverted = append(t.report.Reverted, u.step)
+		}
+	}
+	return &PlayError{Report: t.report, Err: err}
+}
+
+// Play 将Play的各NF参数应用到切片中对应的工作负载和容器
+// 执行前记录受影响资源的快照, 任一步骤失败时恢复已执行的变更, 使集群与存储中的Play保持一致
+func (kc *KubeClient) Play(play model.Play, namespace string) (model.PlayReport, error) {
 	ctx := context.Background()
+	var txn playTxn
+
+	// 0. 切片独立命名空间时先调整配额, 避免更新后的Pod被旧配额拒绝
+	if err := kc.applyQuota(ctx, &txn, play, namespace); err != nil {
+		return txn.report, err
+	}
+
+	// 1. 逐个NF更新Deployment, 记录被替换的优先级类
+	sections := play.Sections()
+	replaced := make(map[string]bool)
+	for _, nf := range play.SectionNames() {
+		enforcer, note, err := kc.bandwidthEnforcer(play.BandwidthEnforcer, nf)
+		if err != nil {
+			return txn.report, txn.fail("选择"+nf+"带宽限制方式", err)
+		}
+		if note != "" && sections[nf].Bandwidth != (model.BandwidthSpec{}) {
+			txn.report.Notes = append(txn.report.Notes, note)
+		}
+		old, err := kc.playNF(ctx, &txn, play.SliceID, nf, sections[nf], enforcer, namespace)
+		if err != nil {
+			return txn.report, err
+		}
+		if old != "" {
+			replaced[old] = true
+		}
+	}
+
+	// 2. 删除不再被切片中任何Deployment引用的旧优先级类
+	if err := kc.deleteUnusedPriorityClasses(ctx, &txn, play.SliceID, replaced, namespace); err != nil {
+		return txn.report, err
+	}
+
+	// 3. 创建/更新网络策略
+	if err := kc.applyNetworkPolicy(ctx, &txn, &play.NetworkPolicy, namespace); err != nil {
+		return txn.report, err
+	}
+
+	// 4. 同步放行规则对应的网络策略
+	if err := kc.applyAllowRules(ctx, &txn, play.SliceID, play.AllowRules, namespace); err != nil {
+		return txn.report, err
+	}
 
-	// 1. 获取现有Deployment
-	deployment, err := kc.clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+	return txn.report, nil
+}
+
+// playNF 将单个NF的参数应用到其Deployment(UPF多副本时为StatefulSet), 返回被替换的旧优先级类名称(未替换时为空)
+func (kc *KubeClient) playNF(ctx context.Context, txn *playTxn, sliceID, nf string, section model.NFPlay, enforcer BandwidthEnforcer, namespace string) (string, error) {
+	name := model.DeploymentName(nf, sliceID)
+	priorityClasses := kc.clientset.SchedulingV1().PriorityClasses()
+
+	// 1. 获取现有工作负载, 作为快照
+	w, err := kc.getWorkload(ctx, namespace, name)
 	if err != nil {
-		return fmt.Errorf("获取Deployment失败: %v", err)
+		return "", txn.fail("获取 "+name, err)
 	}
+	template := w.template
+	podSpec := &template.Spec
 
-	// 2. 更新资源请求/限制
-	container := &deployment.Spec.Template.Spec.Containers[0]
-	container.Resources = corev1.ResourceRequirements{
-		Requests: corev1.ResourceList{
-			corev1.ResourceCPU:    resource.MustParse(play.Resources.CPURequest),
-			corev1.ResourceMemory: resource.MustParse(play.Resources.MemoryRequest),
-		},
-		Limits: corev1.ResourceList{
-			corev1.ResourceCPU:    resource.MustParse(play.Resources.CPULimit),
-			corev1.ResourceMemory: resource.MustParse(play.Resources.MemoryLimit),
-		},
+	// 2. 按名称匹配容器, 更新资源请求/限制
+	names := section.ContainerNames(nf, sliceID)
+	container := findContainer(podSpec, names)
+	if container == nil {
+		return "", txn.fail("查找容器", fmt.Errorf("%s 中没有容器 %s", w.ref(), strings.Join(names, " 或 ")))
+	}
+	if section.Resources != (model.ResourceSpec{}) {
+		resources, err := resourceRequirements(section.Resources)
+		if err != nil {
+			return "", txn.fail("解析"+nf+"资源参数", err)
+		}
+		container.Resources = resources
 	}
 
-	// 3. 注入带宽限制（通过CNI注解）
-	if deployment.Spec.Template.Annotations == nil {
-		deployment.Spec.Template.Annotations = make(map[string]string)
+	// 3. 带宽限制, 由所选的执行方式修改Pod模板
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	target := EnforceTarget{Namespace: namespace, SliceID: sliceID, Workload: name, Container: container.Name}
+	hadSidecar := findContainer(&template.Spec, []string{TCSidecarName}) != nil
+	if err := enforcer.PatchTemplate(target, template, section.Bandwidth); err != nil {
+		return "", txn.fail("解析"+nf+"带宽", err)
+	}
+	if !hadSidecar && findContainer(&template.Spec, []string{TCSidecarName}) != nil {
+		txn.report.Notes = append(txn.report.Notes, fmt.Sprintf("首次启用tc限速, %s 添加边车容器后滚动更新, Pod将重建", w.ref()))
 	}
-	deployment.Spec.Template.Annotations["kubernetes.io/ingress-bandwidth"] = play.Bandwidth.Ingress
-	deployment.Spec.Template.Annotations["kubernetes.io/egress-bandwidth"] = play.Bandwidth.Egress
 
 	// 4. 更新调度规则
 	// 4.1 调度器名称
-	deployment.Spec.Template.Spec.SchedulerName = play.Scheduling.SchedulerName
+	if section.Scheduling.SchedulerName != "" {
+		podSpec.SchedulerName = section.Scheduling.SchedulerName
+	}
 	// 4.2 直接节点绑定（高优先级）
-	if play.Scheduling.NodeName != "" {
-		deployment.Spec.Template.Spec.NodeName = play.Scheduling.NodeName
+	if section.Scheduling.NodeName != "" {
+		podSpec.NodeName = section.Scheduling.NodeName
 	}
 	// 4.3 合并节点选择器（避免覆盖原有标签）
-	for k, v := range play.Scheduling.NodeSelector {
-		deployment.Spec.Template.Spec.NodeSelector[k] = v
+	if len(section.Scheduling.NodeSelector) > 0 && podSpec.NodeSelector == nil {
+		podSpec.NodeSelector = make(map[string]string)
+	}
+	for k, v := range section.Scheduling.NodeSelector {
+		podSpec.NodeSelector[k] = v
+	}
+	// 4.4 亲和性、容忍、拓扑分布和运行时整体替换, 与kustomize补丁的效果一致
+	if section.Scheduling.Affinity != nil {
+		podSpec.Affinity = section.Scheduling.Affinity.DeepCopy()
+	}
+	if len(section.Scheduling.Tolerations) > 0 {
+		podSpec.Tolerations = append([]corev1.Toleration(nil), section.Scheduling.Tolerations...)
+	}
+	if len(section.Scheduling.TopologySpreadConstraints) > 0 {
+		podSpec.TopologySpreadConstraints = append([]corev1.TopologySpreadConstraint(nil), section.Scheduling.TopologySpreadConstraints...)
+	}
+	if section.Scheduling.RuntimeClassName != "" {
+		runtimeClass := section.Scheduling.RuntimeClassName
+		podSpec.RuntimeClassName = &runtimeClass
 	}
 
 	// 5. 合并注解（保留系统注解）
-	for k, v := range play.Annotations {
-		deployment.Spec.Template.Annotations[k] = v
+	for k, v := range section.Annotations {
+		template.Annotations[k] = v
 	}
 
 	// 6. 优先级Priority处理
-	if play.Priority != 0 { // 0表示不设置优先级
-		if err := play.Priority.Validate(); err != nil {
-			return fmt.Errorf("优先级参数错误: %v", err)
+	// 先创建新的优先级类, 所有Deployment更新成功后再删除旧的, 保证Deployment始终引用存在的优先级类
+	oldPriorityClassName := podSpec.PriorityClassName
+	replaced := ""
+	if section.Priority != 0 { // 0表示不设置优先级
+		if err := section.Priority.Validate(); err != nil {
+			return "", txn.fail("检查优先级", err)
 		}
 
-		priorityClassName := play.Priority.ClassName(play.SliceID)
-		oldPriorityClassName := deployment.Spec.Template.Spec.PriorityClassName
+		priorityClassName := section.Priority.ClassName(sliceID)
 		// 若相同,直接跳过
 		if oldPriorityClassName != priorityClassName {
-			if oldPriorityClassName != "" {
-				// 删除旧的优先级类
-				if err := kc.clientset.SchedulingV1().PriorityClasses().Delete(ctx, oldPriorityClassName, metav1.DeleteOptions{}); err != nil {
-					return fmt.Errorf("删除旧的优先级类失败: %v", err)
-				}
-			}
 			// 创建新的优先级类
 			priorityClass := &schedulingv1.PriorityClass{
 				ObjectMeta: metav1.ObjectMeta{
-					Name: priorityClassName,
+					Name:   priorityClassName,
+					Labels: map[string]string{ManagedByLabel: ManagedByValue, SliceOwnerLabel: sliceID},
 				},
-				Value:         int32(play.Priority),
+				Value:         int32(section.Priority),
 				GlobalDefault: false,
-				Description:   fmt.Sprintf("Priority class for slice %s", play.SliceID),
+				Description:   fmt.Sprintf("Priority class for slice %s", sliceID),
 				PreemptionPolicy: func() *corev1.PreemptionPolicy {
 					policy := corev1.PreemptLowerPriority
 					return &policy
 				}(),
 			}
-			_, err := kc.clientset.SchedulingV1().PriorityClasses().Create(ctx, priorityClass, metav1.CreateOptions{})
-			if err != nil {
-				return fmt.Errorf("创建新的优先级类失败: %v", err)
+			step := "创建 PriorityClass/" + priorityClassName
+			_, err := priorityClasses.Create(ctx, priorityClass, metav1.CreateOptions{})
+			switch {
+			case errors.IsAlreadyExists(err):
+				// 已存在(如之前失败的残留, 或同一切片中其他NF刚创建的), 直接复用, 回滚时也不删除
+				txn.done(step, nil)
+			case err != nil:
+				return "", txn.fail(step, err)
+			default:
+				txn.done(step, func() error {
+					return ignoreNotFound(priorityClasses.Delete(ctx, priorityClassName, metav1.DeleteOptions{}))
+				})
 			}
 			// 更新Deployment中的优先级类名称
-			deployment.Spec.Template.Spec.PriorityClassName = priorityClassName
+			podSpec.PriorityClassName = priorityClassName
+			replaced = oldPriorityClassName
+		}
+	}
+
+	// 7. 副本数, 设置autoscaling时由HPA管理
+	if section.Autoscaling == nil && section.Replicas > 0 {
+		w.setReplicas(section.Replicas)
+	}
+
+	// 8. 保存限速参数, 在工作负载更新前生效, 使新建的Pod启动时即按其限速
+	if section.Bandwidth != (model.BandwidthSpec{}) {
+		step := "限速 " + w.ref()
+		undo, err := enforcer.Enforce(ctx, target, section.Bandwidth)
+		if err != nil {
+			return "", txn.fail(step, err)
+		}
+		if undo != nil {
+			txn.done(step, undo)
+		}
+	}
+
+	// 9. 更新工作负载
+	step := "更新 " + w.ref()
+	if err := w.update(ctx); err != nil {
+		return "", txn.fail(step, err)
+	}
+	txn.done(step, func() error { return w.restore(ctx) })
+
+	// 10. 创建/更新/删除HPA
+	if err := kc.applyHPA(ctx, txn, sliceID, w, section.Autoscaling, namespace); err != nil {
+		return "", err
+	}
+	return replaced, nil
+}
+
+// applyHPA 按autoscaling创建或更新与工作负载同名的HPA, autoscaling为nil时删除slicer创建的HPA
+func (kc *KubeClient) applyHPA(ctx context.Context, txn *playTxn, sliceID string, w *workload, autoscaling *model.AutoscalingSpec, namespace string) error {
+	hpas := kc.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace)
+	existing, err := hpas.Get(ctx, w.name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return txn.fail("获取 HorizontalPodAutoscaler/"+w.name, err)
+	}
+	found := err == nil
+
+	if autoscaling == nil {
+		if !found || existing.Labels[ManagedByLabel] != ManagedByValue {
+			return nil
+		}
+		step := "删除 HorizontalPodAutoscaler/" + w.name
+		if err := hpas.Delete(ctx, w.name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return txn.fail(step, err)
+		}
+		txn.done(step, func() error {
+			restored := existing.DeepCopy()
+			restored.ResourceVersion = ""
+			restored.UID = ""
+			_, err := hpas.Create(ctx, restored, metav1.CreateOptions{})
+			return err
+		})
+		return nil
+	}
+
+	spec := autoscaling.HPASpec(w.kind, w.name)
+	if !found {
+		step := "创建 HorizontalPodAutoscaler/" + w.name
+		hpa := &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   w.name,
+				Labels: map[string]string{ManagedByLabel: ManagedByValue, SliceOwnerLabel: sliceID},
+			},
+			Spec: spec,
 		}
+		if _, err := hpas.Create(ctx, hpa, metav1.CreateOptions{}); err != nil {
+			return txn.fail(step, err)
+		}
+		txn.done(step, func() error {
+			return ignoreNotFound(hpas.Delete(ctx, w.name, metav1.DeleteOptions{}))
+		})
+		return nil
 	}
 
-	// 6. 更新Deployment
-	if _, err := kc.clientset.AppsV1().Deployments(namespace).Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
-		return fmt.Errorf("更新Deployment失败: %v", err)
+	step := "更新 HorizontalPodAutoscaler/" + w.name
+	updated := existing.DeepCopy()
+	updated.Spec = spec
+	if _, err := hpas.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return txn.fail(step, err)
 	}
+	txn.done(step, func() error {
+		current, err := hpas.Get(ctx, w.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Spec = existing.Spec
+		_, err = hpas.Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	return nil
+}
 
-	// 7. 创建/更新网络策略
-	if err := kc.applyNetworkPolicy(&play.NetworkPolicy, namespace); err != nil {
-		return fmt.Errorf("网络策略更新失败: %v", err)
+// deleteUnusedPriorityClasses 删除被替换且不再被切片中任何工作负载引用的优先级类
+func (kc *KubeClient) deleteUnusedPriorityClasses(ctx context.Context, txn *playTxn, sliceID string, replaced map[string]bool, namespace string) error {
+	if len(replaced) == 0 {
+		return nil
+	}
+	listOptions := metav1.ListOptions{LabelSelector: sliceSelector(sliceID)}
+	deployments, err := kc.clientset.AppsV1().Deployments(namespace).List(ctx, listOptions)
+	if err != nil {
+		return txn.fail("获取切片Deployment", err)
+	}
+	statefulSets, err := kc.clientset.AppsV1().StatefulSets(namespace).List(ctx, listOptions)
+	if err != nil {
+		return txn.fail("获取切片StatefulSet", err)
+	}
+	used := make(map[string]bool)
+	for _, d := range deployments.Items {
+		used[d.Spec.Template.Spec.PriorityClassName] = true
+	}
+	for _, s := range statefulSets.Items {
+		used[s.Spec.Template.Spec.PriorityClassName] = true
 	}
 
+	names := make([]string, 0, len(replaced))
+	for name := range replaced {
+		if !used[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	priorityClasses := kc.clientset.SchedulingV1().PriorityClasses()
+	for _, name := range names {
+		old, err := priorityClasses.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return txn.fail("获取旧的优先级类", err)
+		}
+		step := "删除 PriorityClass/" + name
+		if err := priorityClasses.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return txn.fail(step, err)
+		}
+		txn.done(step, func() error {
+			restored := old.DeepCopy()
+			restored.ResourceVersion = ""
+			restored.UID = ""
+			_, err := priorityClasses.Create(ctx, restored, metav1.CreateOptions{})
+			return err
+		})
+	}
+	return nil
+}
+
+// findContainer 按名称依次查找容器
+func findContainer(spec *corev1.PodSpec, names []string) *corev1.Container {
+	for _, name := range names {
+		for i := range spec.Containers {
+			if spec.Containers[i].Name == name {
+				return &spec.Containers[i]
+			}
+		}
+	}
 	return nil
 }
 
-// 独立处理NetworkPolicy
-func (kc *KubeClient) applyNetworkPolicy(np *networkingv1.NetworkPolicy, namespace string) error {
-	if np == nil { // 允许空策略
+// 独立处理NetworkPolicy, 不存在时创建, 存在时更新, 并记录恢复操作
+func (kc *KubeClient) applyNetworkPolicy(ctx context.Context, txn *playTxn, np *networkingv1.NetworkPolicy, namespace string) error {
+	if np == nil || np.Name == "" { // 允许空策略
+		return nil
+	}
+	policies := kc.clientset.NetworkingV1().NetworkPolicies(namespace)
+	step := "更新 NetworkPolicy/" + np.Name
+
+	existing, err := policies.Get(ctx, np.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		step = "创建 NetworkPolicy/" + np.Name
+		policy := np.DeepCopy()
+		policy.Namespace = namespace
+		policy.ResourceVersion = ""
+		if _, err := policies.Create(ctx, policy, metav1.CreateOptions{}); err != nil {
+			return txn.fail(step, err)
+		}
+		txn.done(step, func() error {
+			return ignoreNotFound(policies.Delete(ctx, np.Name, metav1.DeleteOptions{}))
+		})
+		return nil
+	}
+	if err != nil {
+		return txn.fail(step, err)
+	}
+
+	updated := existing.DeepCopy()
+	updated.Spec = np.Spec
+	if len(np.Labels) > 0 && updated.Labels == nil {
+		updated.Labels = make(map[string]string, len(np.Labels))
+	}
+	for k, v := range np.Labels {
+		updated.Labels[k] = v
+	}
+	if _, err := policies.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return txn.fail(step, err)
+	}
+	txn.done(step, func() error {
+		current, err := policies.Get(ctx, np.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Spec = existing.Spec
+		_, err = policies.Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	return nil
+}
+
+// applyAllowRules 为每条放行规则创建或更新NetworkPolicy, 并删除切片中已不在Play里的规则
+func (kc *KubeClient) applyAllowRules(ctx context.Context, txn *playTxn, sliceID string, rules []model.NetworkAllowRule, namespace string) error {
+	wanted := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		np := rule.NetworkPolicy(sliceID)
+		np.Labels[ManagedByLabel] = ManagedByValue
+		np.Labels[SliceOwnerLabel] = sliceID
+		np.Labels[PlayRuleLabel] = rule.Name
+		if err := kc.applyNetworkPolicy(ctx, txn, &np, namespace); err != nil {
+			return err
+		}
+		wanted[np.Name] = true
+	}
+
+	policies := kc.clientset.NetworkingV1().NetworkPolicies(namespace)
+	selector := fmt.Sprintf("%s=%s,%s=%s,%s", ManagedByLabel, ManagedByValue, SliceOwnerLabel, sliceID, PlayRuleLabel)
+	list, err := policies.List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return txn.fail("获取放行规则", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	for _, item := range list.Items {
+		if wanted[item.Name] {
+			continue
+		}
+		old := item.DeepCopy()
+		step := "删除 NetworkPolicy/" + old.Name
+		if err := policies.Delete(ctx, old.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return txn.fail(step, err)
+		}
+		txn.done(step, func() error {
+			restored := old.DeepCopy()
+			restored.ResourceVersion = ""
+			restored.UID = ""
+			_, err := policies.Create(ctx, restored, metav1.CreateOptions{})
+			return err
+		})
+	}
+	return nil
+}
+
+// resourceRequirements 将Play中的资源转换为容器的ResourceRequirements, 数值非法时返回错误
+func resourceRequirements(spec model.ResourceSpec) (corev1.ResourceRequirements, error) {
+	if err := spec.Validate(); err != nil {
+		return corev1.ResourceRequirements{}, err
+	}
+	list := func(cpu, memory model.Quantity) (corev1.ResourceList, error) {
+		cpuQ, err := cpu.Quantity()
+		if err != nil {
+			return nil, err
+		}
+		memoryQ, err := memory.Quantity()
+		if err != nil {
+			return nil, err
+		}
+		return corev1.ResourceList{corev1.ResourceCPU: cpuQ, corev1.ResourceMemory: memoryQ}, nil
+	}
+	requests, err := list(spec.CPURequest, spec.MemoryRequest)
+	if err != nil {
+		return corev1.ResourceRequirements{}, err
+	}
+	limits, err := list(spec.CPULimit, spec.MemoryLimit)
+	if err != nil {
+		return corev1.ResourceRequirements{}, err
+	}
+	return corev1.ResourceRequirements{Requests: requests, Limits: limits}, nil
+}
+
+func ignoreNotFound(err error) error {
+	if errors.IsNotFound(err) {
 		return nil
 	}
-	_, err := kc.clientset.NetworkingV1().NetworkPolicies(namespace).Update(
-		context.Background(), np, metav1.UpdateOptions{})
 	return err
 }
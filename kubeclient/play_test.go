@@ -0,0 +1,289 @@
+package kubeclient
+
+import (
+	"context"
+	"fmt"
+	"slicer/model"
+	"slicer/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPlayRevert(t *testing.T) {
+	labels := map[string]string{"app": "open5gs", "slice": "1-000001"}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{Name: "open5gs-upf1-000001", Namespace: "open5gs", Labels: labels},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			PriorityClassName: "priority-1-000001-10",
+			Containers:        []corev1.Container{{Name: "upf", Image: "upf"}},
+		}}},
+	}
+	// SMF的容器名称带切片ID, 且不是第一个容器
+	smf := &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{Name: "open5gs-smf1-000001", Namespace: "open5gs", Labels: labels},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			PriorityClassName: "priority-1-000001-10",
+			Containers:        []corev1.Container{{Name: "exporter", Image: "exporter"}, {Name: "smf1-000001", Image: "smf"}},
+		}}},
+	}
+	oldClass := &schedulingv1.PriorityClass{ObjectMeta: v1.ObjectMeta{Name: "priority-1-000001-10"}, Value: 10}
+	clientset := fakeclientset.NewSimpleClientset(deployment, smf, oldClass)
+	// 最后一步(网络策略)失败
+	clientset.PrependReactor("create", "networkpolicies", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("模拟失败")
+	})
+	kc := &KubeClient{clientset: clientset}
+
+	play := model.Play{
+		SliceID:   "1-000001",
+		Resources: model.ResourceSpec{CPURequest: "100m", CPULimit: "200m", MemoryRequest: "128Mi", MemoryLimit: "256Mi"},
+		Bandwidth: model.BandwidthSpec{Ingress: "100M", Egress: "100M"},
+		Priority:  20,
+		Scheduling: model.SchedulingSpec{
+			NodeSelector: map[string]string{"zone": "edge"},
+		},
+		NetworkPolicy: networkingv1.NetworkPolicy{ObjectMeta: v1.ObjectMeta{Name: "upf1-000001"}},
+		NFs: map[string]model.NFPlay{
+			"smf": {Resources: model.ResourceSpec{CPURequest: "50m", CPULimit: "100m", MemoryRequest: "64Mi", MemoryLimit: "128Mi"}, Priority: 20},
+		},
+	}
+	report, err := kc.Play(play, "open5gs")
+	var playErr *PlayError
+	require.ErrorAs(t, err, &playErr)
+	assert.Contains(t, report.Failed, "NetworkPolicy")
+	// 新优先级类由SMF创建, UPF复用, 只恢复一次
+	assert.Equal(t, []string{
+		"删除 PriorityClass/priority-1-000001-10",
+		"更新 Deployment/open5gs-upf1-000001",
+		"更新 Deployment/open5gs-smf1-000001",
+		"创建 PriorityClass/priority-1-000001-20",
+	}, report.Reverted)
+	assert.Empty(t, report.RevertErrors)
+
+	// 集群恢复为Play之前的状态
+	ctx := context.TODO()
+	restored, err := clientset.AppsV1().Deployments("open5gs").Get(ctx, "open5gs-upf1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, deployment.Spec, restored.Spec)
+	restored, err = clientset.AppsV1().Deployments("open5gs").Get(ctx, "open5gs-smf1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, smf.Spec, restored.Spec)
+	_, err = clientset.SchedulingV1().PriorityClasses().Get(ctx, "priority-1-000001-10", v1.GetOptions{})
+	assert.NoError(t, err)
+	_, err = clientset.SchedulingV1().PriorityClasses().Get(ctx, "priority-1-000001-20", v1.GetOptions{})
+	assert.Error(t, err)
+
+	// 没有网络策略时成功
+	play.NetworkPolicy = networkingv1.NetworkPolicy{}
+	report, err = kc.Play(play, "open5gs")
+	require.NoError(t, err)
+	assert.Len(t, report.Applied, 5)
+	updated, err := clientset.AppsV1().Deployments("open5gs").Get(ctx, "open5gs-upf1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "priority-1-000001-20", updated.Spec.Template.Spec.PriorityClassName)
+	assert.Equal(t, "edge", updated.Spec.Template.Spec.NodeSelector["zone"])
+	updated, err = clientset.AppsV1().Deployments("open5gs").Get(ctx, "open5gs-smf1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Spec.Template.Spec.Containers[0].Resources.Limits)
+	assert.Equal(t, "100m", updated.Spec.Template.Spec.Containers[1].Resources.Limits.Cpu().String())
+	assert.Empty(t, updated.Spec.Template.Spec.NodeSelector)
+
+	// 旧优先级类仍被其他Deployment引用时保留
+	play.NFs = map[string]model.NFPlay{"smf": {Priority: 30}}
+	_, err = kc.Play(play, "open5gs")
+	require.NoError(t, err)
+	_, err = clientset.SchedulingV1().PriorityClasses().Get(ctx, "priority-1-000001-20", v1.GetOptions{})
+	assert.NoError(t, err)
+
+	// 找不到容器时失败
+	play.NFs = map[string]model.NFPlay{"smf": {Container: "missing"}}
+	_, err = kc.Play(play, "open5gs")
+	require.Error(t, err)
+}
+
+func TestPlayScaledUPF(t *testing.T) {
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: v1.ObjectMeta{Name: "open5gs-upf1-000001", Namespace: "open5gs"},
+		Spec: appsv1.StatefulSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "upf", Image: "upf"}},
+		}}},
+	}
+	clientset := fakeclientset.NewSimpleClientset(statefulSet)
+	kc := &KubeClient{clientset: clientset}
+	ctx := context.TODO()
+
+	play := model.Play{
+		SliceID: "1-000001",
+		NFs: map[string]model.NFPlay{
+			"upf": {Autoscaling: &model.AutoscalingSpec{MinReplicas: 2, MaxReplicas: 4, Metric: &model.MetricTarget{Name: "upf_throughput_mbps", AverageValue: "800"}}},
+		},
+	}
+	_, err := kc.Play(play, "open5gs")
+	require.NoError(t, err)
+	hpa, err := clientset.AutoscalingV2().HorizontalPodAutoscalers("open5gs").Get(ctx, "open5gs-upf1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "StatefulSet", hpa.Spec.ScaleTargetRef.Kind)
+	assert.Equal(t, int32(4), hpa.Spec.MaxReplicas)
+	assert.Equal(t, "upf_throughput_mbps", hpa.Spec.Metrics[0].Pods.Metric.Name)
+
+	// 改为固定副本数时删除HPA
+	play.NFs["upf"] = model.NFPlay{Replicas: 3}
+	report, err := kc.Play(play, "open5gs")
+	require.NoError(t, err)
+	assert.Contains(t, report.Applied, "删除 HorizontalPodAutoscaler/open5gs-upf1-000001")
+	updated, err := clientset.AppsV1().StatefulSets("open5gs").Get(ctx, "open5gs-upf1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), *updated.Spec.Replicas)
+}
+
+func TestPlayAllowRules(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{Name: "open5gs-upf1-000001", Namespace: "open5gs"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "upf", Image: "upf"}},
+		}}},
+	}
+	clientset := fakeclientset.NewSimpleClientset(deployment)
+	kc := &KubeClient{clientset: clientset}
+	ctx := context.TODO()
+	policies := clientset.NetworkingV1().NetworkPolicies("open5gs")
+	probePort := intstr.FromInt32(8080)
+
+	play := model.Play{
+		SliceID: "1-000001",
+		AllowRules: []model.NetworkAllowRule{
+			{Name: "probe", NF: "upf", Ports: []networkingv1.NetworkPolicyPort{{Port: &probePort}}},
+			{Name: "lab", NF: "upf", From: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "192.168.0.0/16"}}}},
+		},
+	}
+	_, err := kc.Play(play, "open5gs")
+	require.NoError(t, err)
+	np, err := policies.Get(ctx, "upf1-000001-allow-probe", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "probe", np.Labels[PlayRuleLabel])
+	assert.Equal(t, "1-000001", np.Spec.PodSelector.MatchLabels["slice"])
+
+	// 重复应用是幂等的, 移除的规则被删除
+	play.AllowRules = play.AllowRules[1:]
+	report, err := kc.Play(play, "open5gs")
+	require.NoError(t, err)
+	assert.Contains(t, report.Applied, "更新 NetworkPolicy/upf1-000001-allow-lab")
+	assert.Contains(t, report.Applied, "删除 NetworkPolicy/upf1-000001-allow-probe")
+	list, err := policies.List(ctx, v1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "upf1-000001-allow-lab", list.Items[0].Name)
+}
+
+func TestPlayTCBandwidth(t *testing.T) {
+	podLabels := map[string]string{"app": "open5gs", "nf": "upf", "slice": "1-000001"}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{Name: "open5gs-upf1-000001", Namespace: "open5gs"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: v1.ObjectMeta{Labels: podLabels, Annotations: map[string]string{"kubernetes.io/ingress-bandwidth": "10M"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "upf", Image: "upf"}}},
+		}},
+	}
+	smf := &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{Name: "open5gs-smf1-000001", Namespace: "open5gs"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "smf1-000001", Image: "smf"}}},
+		}},
+	}
+	clientset := fakeclientset.NewSimpleClientset(deployment, smf)
+	failUpdate := true
+	clientset.PrependReactor("update", "deployments", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if failUpdate {
+			return true, nil, fmt.Errorf("update failed")
+		}
+		return false, nil, nil
+	})
+	kc := &KubeClient{clientset: clientset}
+	ctx := context.TODO()
+	configMaps := clientset.CoreV1().ConfigMaps("open5gs")
+
+	play := model.Play{
+		SliceID:           "1-000001",
+		Bandwidth:         model.BandwidthSpec{Ingress: "100M", Egress: "50M"},
+		BandwidthEnforcer: model.EnforcerTC,
+	}
+	report, err := kc.Play(play, "open5gs")
+	require.Error(t, err)
+
+	// Deployment更新失败时删除已保存的限速参数
+	assert.Contains(t, report.Reverted, "限速 Deployment/open5gs-upf1-000001")
+	_, err = configMaps.Get(ctx, "open5gs-upf1-000001-bandwidth", v1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+
+	// 成功时移除注解方式的限速, 添加边车, 参数保存在ConfigMap中
+	// SMF不支持tc, 改用注解; 首次启用tc时UPF滚动更新, 均在报告中说明
+	failUpdate = false
+	play.NFs = map[string]model.NFPlay{"smf": {Bandwidth: model.BandwidthSpec{Ingress: "10M"}}}
+	report, err = kc.Play(play, "open5gs")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"smf不支持tc限速, 已改用注解, Pod重建后生效",
+		"首次启用tc限速, Deployment/open5gs-upf1-000001 添加边车容器后滚动更新, Pod将重建",
+	}, report.Notes)
+	smfUpdated, err := clientset.AppsV1().Deployments("open5gs").Get(ctx, "open5gs-smf1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "10M", smfUpdated.Spec.Template.Annotations["kubernetes.io/ingress-bandwidth"])
+	updated, err := clientset.AppsV1().Deployments("open5gs").Get(ctx, "open5gs-upf1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	template := updated.Spec.Template
+	assert.NotContains(t, template.Annotations, "kubernetes.io/ingress-bandwidth")
+	require.Len(t, template.Spec.Containers, 2)
+	sidecar := template.Spec.Containers[1]
+	assert.Equal(t, TCSidecarName, sidecar.Name)
+	assert.Equal(t, "upf", sidecar.Image)
+	assert.Contains(t, sidecar.Command[2], "for pair in n3:downlink eth0:uplink; do")
+	assert.Contains(t, sidecar.Command[2], "[ -e /sys/class/net/$dev ] || continue")
+	require.Len(t, template.Spec.Volumes, 1)
+	assert.Equal(t, "open5gs-upf1-000001-bandwidth", template.Spec.Volumes[0].ConfigMap.Name)
+	configMap, err := configMaps.Get(ctx, "open5gs-upf1-000001-bandwidth", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"downlink": "rate 100000000bit burst 125000b latency 50ms",
+		"uplink":   "rate 50000000bit burst 62500b latency 50ms",
+	}, configMap.Data)
+
+	// 再次修改带宽只更新ConfigMap, Pod模板不变, 不重建Pod
+	play.Bandwidth = model.BandwidthSpec{Ingress: "200M"}
+	play.NFs = nil
+	report, err = kc.Play(play, "open5gs")
+	require.NoError(t, err)
+	assert.Empty(t, report.Notes)
+	again, err := clientset.AppsV1().Deployments("open5gs").Get(ctx, "open5gs-upf1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, template, again.Spec.Template)
+	configMap, err = configMaps.Get(ctx, "open5gs-upf1-000001-bandwidth", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "rate 200000000bit burst 250000b latency 50ms", configMap.Data["downlink"])
+	assert.Equal(t, "rate 50000000bit burst 62500b latency 50ms", configMap.Data["uplink"])
+}
+
+func TestTCUplinkInterfaces(t *testing.T) {
+	// 未配置N6网络时N6流量经eth0 NAT
+	assert.Equal(t, []string{"eth0"}, tcUplinkInterfaces(util.Config{}))
+
+	// 默认限速各DNN的N6接口
+	config := util.Config{IPAMConfig: util.IPAMConfig{N6Networks: map[string]string{"internet": "eth2", "ims": "eth3"}}}
+	assert.Equal(t, []string{"n6-ims", "n6-internet"}, tcUplinkInterfaces(config))
+
+	// 显式配置时优先
+	config.TCN6Interface = "n6"
+	assert.Equal(t, []string{"n6"}, tcUplinkInterfaces(config))
+}
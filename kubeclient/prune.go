@@ -0,0 +1,171 @@
+package kubeclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// 归属标签, slicer应用的每个资源都会带有
+const (
+	ManagedByLabel    = "app.kubernetes.io/managed-by"
+	ManagedByValue    = "slicer"
+	SliceOwnerLabel   = "slicer.io/slice"         // 切片资源所属的切片ID
+	PlayRuleLabel     = "slicer.io/play-rule"     // Play放行规则生成的NetworkPolicy, 值为规则名称
+	PlayResourceLabel = "slicer.io/play-resource" // Play创建的其他资源(如tc限速参数的ConfigMap), 值为用途
+)
+
+// prunableResources 清理时检查的资源类型, 模板新增资源类型时需同步添加
+var prunableResources = []schema.GroupVersionResource{
+	{Version: "v1", Resource: "configmaps"},
+	{Version: "v1", Resource: "services"},
+	{Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Version: "v1", Resource: "statefulsets"},
+	{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"},
+}
+
+// playResources Play创建的带归属标签的资源, 不在渲染结果中, 只在删除切片(docs为空)时清理
+var playResources = []schema.GroupVersionResource{
+	{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"},
+}
+
+// priorityClassResource Play创建的优先级类, 集群级资源, 清理时删除不再被切片工作负载引用的
+var priorityClassResource = schema.GroupVersionResource{Group: "scheduling.k8s.io", Version: "v1", Resource: "priorityclasses"}
+
+// PrunedObject 被清理(或dry-run时将被清理)的资源
+type PrunedObject struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// stampOwnership 为资源打上slicer的归属标签
+func stampOwnership(obj *unstructured.Unstructured, labels map[string]string) {
+	merged := obj.GetLabels()
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	merged[ManagedByLabel] = ManagedByValue
+	for k, v := range labels {
+		merged[k] = v
+	}
+	obj.SetLabels(merged)
+}
+
+// PruneSlice 删除带有该切片归属标签, 但不在docs中的资源
+// 同时删除该切片不再被保留的工作负载引用的优先级类, 如Play回滚到切片模板后遗留的
+// dryRun为true时只返回将被删除的资源
+func (kc *KubeClient) PruneSlice(sliceID string, docs [][]byte, dryRun bool) ([]PrunedObject, error) {
+	keep, _, err := bundleObjects(docs)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.TODO()
+	selector := fmt.Sprintf("%s=%s,%s=%s", ManagedByLabel, ManagedByValue, SliceOwnerLabel, sliceID)
+	pruned := []PrunedObject{}
+	usedPriorityClasses := make(map[string]bool)
+	resources := prunableResources
+	if len(keep) == 0 {
+		resources = append(append([]schema.GroupVersionResource{}, prunableResources...), playResources...)
+	}
+	for _, gvr := range resources {
+		client := kc.dynamicClient.Resource(gvr).Namespace(kc.config.SliceNamespace(sliceID))
+		list, err := client.List(ctx, v1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return pruned, fmt.Errorf("获取 %s 失败: %w", gvr.Resource, err)
+		}
+		for _, item := range list.Items {
+			obj := PrunedObject{Kind: item.GetKind(), Name: item.GetName()}
+			if keep[obj] {
+				if name, _, _ := unstructured.NestedString(item.Object, "spec", "template", "spec", "priorityClassName"); name != "" {
+					usedPriorityClasses[name] = true
+				}
+				continue
+			}
+			// Play放行规则等资源不在渲染结果中, 由Play维护, 只随切片删除
+			if len(keep) > 0 && (item.GetLabels()[PlayRuleLabel] != "" || item.GetLabels()[PlayResourceLabel] != "") {
+				continue
+			}
+			if !dryRun {
+				policy := v1.DeletePropagationBackground
+				err := client.Delete(ctx, obj.Name, v1.DeleteOptions{PropagationPolicy: &policy})
+				if err != nil && !errors.IsNotFound(err) {
+					return pruned, fmt.Errorf("删除 %s/%s 失败: %w", obj.Kind, obj.Name, err)
+				}
+			}
+			pruned = append(pruned, obj)
+		}
+	}
+
+	classes, err := kc.dynamicClient.Resource(priorityClassResource).List(ctx, v1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return pruned, fmt.Errorf("获取 %s 失败: %w", priorityClassResource.Resource, err)
+	}
+	for _, item := range classes.Items {
+		if usedPriorityClasses[item.GetName()] {
+			continue
+		}
+		obj := PrunedObject{Kind: item.GetKind(), Name: item.GetName()}
+		if !dryRun {
+			err := kc.dynamicClient.Resource(priorityClassResource).Delete(ctx, obj.Name, v1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return pruned, fmt.Errorf("删除 %s/%s 失败: %w", obj.Kind, obj.Name, err)
+			}
+		}
+		pruned = append(pruned, obj)
+	}
+	sort.Slice(pruned, func(i, j int) bool {
+		if pruned[i].Kind != pruned[j].Kind {
+			return pruned[i].Kind < pruned[j].Kind
+		}
+		return pruned[i].Name < pruned[j].Name
+	})
+	return pruned, nil
+}
+
+// prune 应用或删除后清理过期资源, 失败只记录日志
+func (kc *KubeClient) prune(sliceID string, docs [][]byte) {
+	pruned, err := kc.PruneSlice(sliceID, docs, false)
+	if err != nil {
+		slog.Warn("清理过期资源失败", "sliceID", sliceID, "err", err)
+		return
+	}
+	for _, obj := range pruned {
+		slog.Info("已清理过期资源", "sliceID", sliceID, "kind", obj.Kind, "name", obj.Name)
+	}
+}
+
+// bundleObjects 返回docs中所有资源的类型和名称, 以及标签slice的值
+func bundleObjects(docs [][]byte) (map[PrunedObject]bool, string, error) {
+	objects := make(map[PrunedObject]bool)
+	sliceID := ""
+	for _, data := range docs {
+		decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 100)
+		for {
+			var rawObj unstructured.Unstructured
+			if err := decoder.Decode(&rawObj); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, "", fmt.Errorf("YAML解码失败: %v", err)
+			}
+			if rawObj.Object == nil {
+				continue
+			}
+			objects[PrunedObject{Kind: rawObj.GetKind(), Name: rawObj.GetName()}] = true
+			if id := rawObj.GetLabels()["slice"]; id != "" && sliceID == "" {
+				sliceID = id
+			}
+		}
+	}
+	return objects, sliceID, nil
+}
@@ -0,0 +1,98 @@
+package kubeclient
+
+import (
+	"context"
+	"slicer/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestPruneSlice(t *testing.T) {
+	object := func(apiVersion, kind, name, sliceID string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetNamespace("open5gs")
+		stampOwnership(obj, map[string]string{SliceOwnerLabel: sliceID})
+		return obj
+	}
+	allowRule := object("networking.k8s.io/v1", "NetworkPolicy", "upf1-000001-allow-lab", "1-000001") // Play放行规则
+	stampOwnership(allowRule, map[string]string{PlayRuleLabel: "lab"})
+	smf := object("apps/v1", "Deployment", "open5gs-smf1-000001", "1-000001")
+	require.NoError(t, unstructured.SetNestedField(smf.Object, "priority-1-000001-500", "spec", "template", "spec", "priorityClassName"))
+	priorityClass := func(name string) *unstructured.Unstructured {
+		obj := object("scheduling.k8s.io/v1", "PriorityClass", name, "1-000001")
+		obj.SetNamespace("")
+		return obj
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Version: "v1", Resource: "configmaps"}:                                     "ConfigMapList",
+			{Version: "v1", Resource: "services"}:                                       "ServiceList",
+			{Group: "apps", Version: "v1", Resource: "deployments"}:                     "DeploymentList",
+			{Group: "apps", Version: "v1", Resource: "statefulsets"}:                    "StatefulSetList",
+			{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"}: "HorizontalPodAutoscalerList",
+			{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"}:    "NetworkPolicyList",
+			priorityClassResource: "PriorityClassList",
+		},
+		object("v1", "ConfigMap", "smf1-000001-configmap", "1-000001"),
+		object("v1", "Service", "smf1-000001-old", "1-000001"),  // 已不再渲染
+		object("v1", "Service", "smf1-000002-nsmf", "1-000002"), // 其他切片
+		object("apps/v1", "Deployment", "open5gs-upf1-000001-old", "1-000001"),
+		object("autoscaling/v2", "HorizontalPodAutoscaler", "open5gs-upf1-000001", "1-000001"), // 由Play创建
+		allowRule,
+		smf,
+		priorityClass("priority-1-000001-500"),
+		priorityClass("priority-1-000001-300"), // Play回滚后不再被引用
+	)
+	config := util.Config{}
+	config.Namespace = "open5gs"
+	kc := &KubeClient{config: config, dynamicClient: dynamicClient}
+
+	docs := [][]byte{
+		[]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: smf1-000001-configmap\n  labels:\n    slice: 1-000001\n"),
+		[]byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: open5gs-smf1-000001\n"),
+	}
+	want := []PrunedObject{
+		{Kind: "Deployment", Name: "open5gs-upf1-000001-old"},
+		{Kind: "PriorityClass", Name: "priority-1-000001-300"},
+		{Kind: "Service", Name: "smf1-000001-old"},
+	}
+
+	// dry-run只列出
+	pruned, err := kc.PruneSlice("1-000001", docs, true)
+	require.NoError(t, err)
+	assert.Equal(t, want, pruned)
+	services, err := dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "services"}).Namespace("open5gs").List(context.TODO(), v1.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, services.Items, 2)
+
+	pruned, err = kc.PruneSlice("1-000001", docs, false)
+	require.NoError(t, err)
+	assert.Equal(t, want, pruned)
+
+	// 再次清理时没有需要删除的资源, 其他切片的资源保留
+	pruned, err = kc.PruneSlice("1-000001", docs, true)
+	require.NoError(t, err)
+	assert.Empty(t, pruned)
+	services, err = dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "services"}).Namespace("open5gs").List(context.TODO(), v1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, services.Items, 1)
+	assert.Equal(t, "smf1-000002-nsmf", services.Items[0].GetName())
+
+	// 删除切片时才清理Play创建的HPA和放行规则
+	pruned, err = kc.PruneSlice("1-000001", nil, true)
+	require.NoError(t, err)
+	assert.Contains(t, pruned, PrunedObject{Kind: "HorizontalPodAutoscaler", Name: "open5gs-upf1-000001"})
+	assert.Contains(t, pruned, PrunedObject{Kind: "NetworkPolicy", Name: "upf1-000001-allow-lab"})
+	assert.Contains(t, pruned, PrunedObject{Kind: "PriorityClass", Name: "priority-1-000001-500"})
+}
@@ -0,0 +1,302 @@
+package kubeclient
+
+import (
+	"context"
+	"fmt"
+	"slicer/model"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 切片独立命名空间中由Play维护的配额对象
+const (
+	SliceQuotaName      = "slice-quota"
+	SliceLimitRangeName = "slice-limits"
+)
+
+// quotaSurge 配额相对于工作负载资源的倍数, 为滚动更新时新旧Pod同时存在留出余量
+const quotaSurge = 2
+
+// 未设置资源的容器(如initContainer)的默认请求和限制, 由LimitRange设置
+var (
+	defaultContainerRequests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("64Mi")}
+	defaultContainerLimits   = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m"), corev1.ResourceMemory: resource.MustParse("128Mi")}
+)
+
+// quotaWorkload 计算配额的一个工作负载, Pod模板已按Play修改
+type quotaWorkload struct {
+	name     string
+	replicas int64
+	spec     corev1.PodSpec
+}
+
+// ensureSliceNamespace 切片独立命名空间时创建切片的命名空间, 已存在时不修改
+func (kc *KubeClient) ensureSliceNamespace(ctx context.Context, sliceID string) error {
+	if !kc.config.NamespacePerSlice {
+		return nil
+	}
+	name := kc.config.SliceNamespace(sliceID)
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{ManagedByLabel: ManagedByValue, SliceOwnerLabel: sliceID},
+		},
+	}
+	_, err := kc.clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("创建命名空间 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+// deleteSliceNamespace 删除slicer为切片创建的命名空间, 命名空间中的资源随之删除
+func (kc *KubeClient) deleteSliceNamespace(ctx context.Context, sliceID string) error {
+	if !kc.config.NamespacePerSlice {
+		return nil
+	}
+	name := kc.config.SliceNamespace(sliceID)
+	namespaces := kc.clientset.CoreV1().Namespaces()
+	existing, err := namespaces.Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("获取命名空间 %s 失败: %w", name, err)
+	}
+	if existing.Labels[ManagedByLabel] != ManagedByValue || existing.Labels[SliceOwnerLabel] != sliceID {
+		return fmt.Errorf("命名空间 %s 不是slicer为切片 %s 创建的, 不删除", name, sliceID)
+	}
+	return ignoreNotFound(namespaces.Delete(ctx, name, metav1.DeleteOptions{}))
+}
+
+// quotaWorkloads 返回切片中所有工作负载按Play修改后的Pod模板及副本数
+// Play中的NF使用其资源参数和副本数(设置autoscaling时为最大副本数), 其余工作负载按集群中的设置
+func (kc *KubeClient) quotaWorkloads(ctx context.Context, play model.Play, namespace string) ([]quotaWorkload, error) {
+	listOptions := metav1.ListOptions{LabelSelector: sliceSelector(play.SliceID)}
+	deployments, err := kc.clientset.AppsV1().Deployments(namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, fmt.Errorf("获取切片Deployment失败: %w", err)
+	}
+	statefulSets, err := kc.clientset.AppsV1().StatefulSets(namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, fmt.Errorf("获取切片StatefulSet失败: %w", err)
+	}
+	replicas := func(n *int32) int64 {
+		if n == nil {
+			return 1
+		}
+		return int64(*n)
+	}
+	var workloads []quotaWorkload
+	templates := make(map[string]*corev1.PodTemplateSpec)
+	for _, d := range deployments.Items {
+		workloads = append(workloads, quotaWorkload{name: d.Name, replicas: replicas(d.Spec.Replicas)})
+		templates[d.Name] = d.Spec.Template.DeepCopy()
+	}
+	for _, s := range statefulSets.Items {
+		workloads = append(workloads, quotaWorkload{name: s.Name, replicas: replicas(s.Spec.Replicas)})
+		templates[s.Name] = s.Spec.Template.DeepCopy()
+	}
+
+	sections := play.Sections()
+	for i := range workloads {
+		w := &workloads[i]
+		template := templates[w.name]
+		for nf, section := range sections {
+			name := model.DeploymentName(nf, play.SliceID)
+			if name != w.name {
+				continue
+			}
+			if container := findContainer(&template.Spec, section.ContainerNames(nf, play.SliceID)); container != nil && section.Resources != (model.ResourceSpec{}) {
+				if container.Resources, err = resourceRequirements(section.Resources); err != nil {
+					return nil, fmt.Errorf("解析%s资源参数失败: %w", nf, err)
+				}
+				// 与playNF相同, 带宽限制方式可能添加边车容器, 错误由playNF报告
+				if enforcer, _, err := kc.bandwidthEnforcer(play.BandwidthEnforcer, nf); err == nil {
+					target := EnforceTarget{Namespace: namespace, SliceID: play.SliceID, Workload: name, Container: container.Name}
+					_ = enforcer.PatchTemplate(target, template, section.Bandwidth)
+				}
+			}
+			switch {
+			case section.Autoscaling != nil:
+				w.replicas = int64(section.Autoscaling.MaxReplicas)
+			case section.Replicas > 0:
+				w.replicas = int64(section.Replicas)
+			}
+		}
+		w.spec = template.Spec
+	}
+	return workloads, nil
+}
+
+// sliceQuota 由切片中的工作负载计算命名空间的配额及容器的默认资源
+// 各工作负载的Pod资源按副本数累加, 再乘以quotaSurge; 没有工作负载时返回ok为false, 不设置配额
+func sliceQuota(workloads []quotaWorkload) (quota corev1.ResourceList, limits corev1.LimitRangeItem, ok bool) {
+	if len(workloads) == 0 {
+		return nil, limits, false
+	}
+	// 上限取所有容器及默认值中最大的, 使现有容器都不会被拒绝
+	limits = corev1.LimitRangeItem{
+		Type:           corev1.LimitTypeContainer,
+		Max:            defaultContainerLimits.DeepCopy(),
+		Default:        defaultContainerLimits.DeepCopy(),
+		DefaultRequest: defaultContainerRequests.DeepCopy(),
+	}
+	resourceNames := []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}
+
+	totals := map[corev1.ResourceName]*resource.Quantity{}
+	add := func(name corev1.ResourceName, q resource.Quantity, n int64) {
+		if totals[name] == nil {
+			totals[name] = resource.NewQuantity(0, q.Format)
+		}
+		for i := int64(0); i < n; i++ {
+			totals[name].Add(q)
+		}
+	}
+	var pods int64
+	for _, w := range workloads {
+		n := w.replicas * quotaSurge
+		pods += n
+		for _, name := range resourceNames {
+			add("requests."+name, podResource(w.spec, name, false), n)
+			add("limits."+name, podResource(w.spec, name, true), n)
+			for _, c := range append(append([]corev1.Container{}, w.spec.InitContainers...), w.spec.Containers...) {
+				if q, ok := c.Resources.Limits[name]; ok && q.Cmp(limits.Max[name]) > 0 {
+					limits.Max[name] = q
+				}
+			}
+		}
+	}
+
+	quota = corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(pods, resource.DecimalSI)}
+	for name, q := range totals {
+		quota[name] = *q
+	}
+	return quota, limits, true
+}
+
+// podResource 返回Pod的资源请求或限制, 为容器之和与initContainer最大值中的较大者, 与调度器的计算方式一致
+// 未设置资源的容器按LimitRange的默认值计算
+func podResource(spec corev1.PodSpec, name corev1.ResourceName, limit bool) resource.Quantity {
+	value := func(c corev1.Container) resource.Quantity {
+		list, defaults := c.Resources.Requests, defaultContainerRequests
+		if limit {
+			list, defaults = c.Resources.Limits, defaultContainerLimits
+		}
+		if q, ok := list[name]; ok {
+			return q
+		}
+		return defaults[name]
+	}
+	total := resource.NewQuantity(0, resource.DecimalSI)
+	for _, c := range spec.Containers {
+		total.Add(value(c))
+	}
+	for _, c := range spec.InitContainers {
+		if q := value(c); q.Cmp(*total) > 0 {
+			total = &q
+		}
+	}
+	return *total
+}
+
+// applyQuota 切片独立命名空间时按Play及切片中的工作负载创建或更新命名空间的LimitRange和ResourceQuota
+// 在更新工作负载前执行, 使新的资源参数不会被旧配额拒绝
+func (kc *KubeClient) applyQuota(ctx context.Context, txn *playTxn, play model.Play, namespace string) error {
+	if !kc.config.NamespacePerSlice {
+		return nil
+	}
+	// Play中没有任何资源参数时不设置配额
+	hasResources := false
+	for _, section := range play.Sections() {
+		hasResources = hasResources || section.Resources != (model.ResourceSpec{})
+	}
+	if !hasResources {
+		return nil
+	}
+	workloads, err := kc.quotaWorkloads(ctx, play, namespace)
+	if err != nil {
+		return txn.fail("计算配额", err)
+	}
+	quota, limits, ok := sliceQuota(workloads)
+	if !ok {
+		return nil
+	}
+	labels := map[string]string{ManagedByLabel: ManagedByValue, SliceOwnerLabel: play.SliceID}
+
+	// 1. LimitRange, 为未设置资源的容器(如initContainer)提供默认值
+	limitRanges := kc.clientset.CoreV1().LimitRanges(namespace)
+	step := "创建 LimitRange/" + SliceLimitRangeName
+	existingLR, err := limitRanges.Get(ctx, SliceLimitRangeName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		lr := &corev1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{Name: SliceLimitRangeName, Labels: labels},
+			Spec:       corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{limits}},
+		}
+		if _, err := limitRanges.Create(ctx, lr, metav1.CreateOptions{}); err != nil {
+			return txn.fail(step, err)
+		}
+		txn.done(step, func() error {
+			return ignoreNotFound(limitRanges.Delete(ctx, SliceLimitRangeName, metav1.DeleteOptions{}))
+		})
+	case err != nil:
+		return txn.fail(step, err)
+	default:
+		step = "更新 LimitRange/" + SliceLimitRangeName
+		updated := existingLR.DeepCopy()
+		updated.Spec.Limits = []corev1.LimitRangeItem{limits}
+		if _, err := limitRanges.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return txn.fail(step, err)
+		}
+		txn.done(step, func() error {
+			current, err := limitRanges.Get(ctx, SliceLimitRangeName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			current.Spec = existingLR.Spec
+			_, err = limitRanges.Update(ctx, current, metav1.UpdateOptions{})
+			return err
+		})
+	}
+
+	// 2. ResourceQuota
+	quotas := kc.clientset.CoreV1().ResourceQuotas(namespace)
+	step = "创建 ResourceQuota/" + SliceQuotaName
+	existingRQ, err := quotas.Get(ctx, SliceQuotaName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		rq := &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: SliceQuotaName, Labels: labels},
+			Spec:       corev1.ResourceQuotaSpec{Hard: quota},
+		}
+		if _, err := quotas.Create(ctx, rq, metav1.CreateOptions{}); err != nil {
+			return txn.fail(step, err)
+		}
+		txn.done(step, func() error {
+			return ignoreNotFound(quotas.Delete(ctx, SliceQuotaName, metav1.DeleteOptions{}))
+		})
+	case err != nil:
+		return txn.fail(step, err)
+	default:
+		step = "更新 ResourceQuota/" + SliceQuotaName
+		updated := existingRQ.DeepCopy()
+		updated.Spec.Hard = quota
+		if _, err := quotas.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return txn.fail(step, err)
+		}
+		txn.done(step, func() error {
+			current, err := quotas.Get(ctx, SliceQuotaName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			current.Spec = existingRQ.Spec
+			_, err = quotas.Update(ctx, current, metav1.UpdateOptions{})
+			return err
+		})
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package kubeclient
+
+import (
+	"context"
+	"slicer/model"
+	"slicer/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSliceQuota(t *testing.T) {
+	podLabels := map[string]string{"app": "open5gs", "slice": "1-000001"}
+	resources := func(cpuRequest, cpuLimit, memoryRequest, memoryLimit string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpuRequest), corev1.ResourceMemory: resource.MustParse(memoryRequest)},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpuLimit), corev1.ResourceMemory: resource.MustParse(memoryLimit)},
+		}
+	}
+	smf := &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{Name: "open5gs-smf1-000001", Namespace: "slice-1-000001", Labels: podLabels},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "smf", Resources: resources("100m", "200m", "128Mi", "256Mi")}},
+		}}},
+	}
+	upf := &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{Name: "open5gs-upf1-000001", Namespace: "slice-1-000001", Labels: podLabels},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "wait-smf"}},
+			Containers:     []corev1.Container{{Name: "upf", Resources: resources("100m", "200m", "128Mi", "256Mi")}},
+		}}},
+	}
+	kc := &KubeClient{clientset: fakeclientset.NewSimpleClientset(smf, upf)}
+
+	// Play中只有UPF设置了资源, SMF按模板中的资源计算
+	play := model.Play{
+		SliceID:   "1-000001",
+		Resources: model.ResourceSpec{CPURequest: "500m", CPULimit: "1", MemoryRequest: "512Mi", MemoryLimit: "1Gi"},
+		NFs: map[string]model.NFPlay{
+			"upf": {Autoscaling: &model.AutoscalingSpec{MinReplicas: 1, MaxReplicas: 3, TargetCPUUtilization: 70}},
+		},
+	}
+	workloads, err := kc.quotaWorkloads(context.TODO(), play, "slice-1-000001")
+	require.NoError(t, err)
+	quota, limits, ok := sliceQuota(workloads)
+	require.True(t, ok)
+
+	// SMF 1个副本, UPF最多3个副本, 均按2倍计算; UPF的initContainer未设置资源, 按默认值计算但小于容器之和
+	for name, want := range map[corev1.ResourceName]string{
+		corev1.ResourcePods:           "8",
+		corev1.ResourceRequestsCPU:    "3200m",
+		corev1.ResourceLimitsCPU:      "6400m",
+		corev1.ResourceRequestsMemory: "3328Mi",
+		corev1.ResourceLimitsMemory:   "6656Mi",
+	} {
+		q := quota[name]
+		assert.Zero(t, q.Cmp(resource.MustParse(want)), "%s: %s", name, q.String())
+	}
+	maxCPU, defaultMemory, defaultCPURequest := limits.Max[corev1.ResourceCPU], limits.Default[corev1.ResourceMemory], limits.DefaultRequest[corev1.ResourceCPU]
+	assert.Zero(t, maxCPU.Cmp(resource.MustParse("1")))
+	assert.Zero(t, defaultMemory.Cmp(resource.MustParse("128Mi")))
+	assert.Zero(t, defaultCPURequest.Cmp(resource.MustParse("100m")))
+
+	// initContainer大于容器之和时按initContainer计算
+	pod := corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Resources: resources("1", "2", "1Gi", "2Gi")}},
+		Containers:     []corev1.Container{{Name: "main"}},
+	}
+	cpu := podResource(pod, corev1.ResourceCPU, false)
+	assert.Zero(t, cpu.Cmp(resource.MustParse("1")))
+
+	// 没有工作负载时不设置配额
+	_, _, ok = sliceQuota(nil)
+	assert.False(t, ok)
+}
+
+func TestSliceNamespaceLifecycle(t *testing.T) {
+	clientset := fakeclientset.NewSimpleClientset()
+	kc := &KubeClient{clientset: clientset, config: util.Config{KubeConfig: util.KubeConfig{Namespace: "open5gs", NamespacePerSlice: true}}}
+	ctx := context.TODO()
+
+	require.NoError(t, kc.ensureSliceNamespace(ctx, "1-000001"))
+	require.NoError(t, kc.ensureSliceNamespace(ctx, "1-000001"))
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, "slice-1-000001", v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "1-000001", ns.Labels[SliceOwnerLabel])
+
+	// 非slicer创建的同名命名空间不删除
+	_, err = clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "slice-1-000002"}}, v1.CreateOptions{})
+	require.NoError(t, err)
+	assert.Error(t, kc.deleteSliceNamespace(ctx, "1-000002"))
+
+	require.NoError(t, kc.deleteSliceNamespace(ctx, "1-000001"))
+	_, err = clientset.CoreV1().Namespaces().Get(ctx, "slice-1-000001", v1.GetOptions{})
+	assert.Error(t, err)
+}
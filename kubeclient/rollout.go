@@ -0,0 +1,241 @@
+package kubeclient
+
+import (
+	"context"
+	"fmt"
+	"slicer/model"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	rolloutPollInterval = 2 * time.Second
+	rolloutLogTailLines = int64(20)
+)
+
+// 出现以下等待原因时容器无法自行恢复, 不再继续等待
+var failedWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// RolloutError 资源已被API Server接受, 但在超时前未就绪
+type RolloutError struct {
+	Status model.RolloutStatus
+}
+
+func (e *RolloutError) Error() string {
+	return fmt.Sprintf("切片未就绪: %s", e.Status.Reason)
+}
+
+// WaitForSlice 监听切片的Deployment直到全部完成滚动更新(含多副本UPF的StatefulSet)或超时
+// 发现崩溃或无法拉取镜像的容器时立即返回*RolloutError, 其中包含上次退出原因和日志末尾
+func (kc *KubeClient) WaitForSlice(sliceID string, timeout time.Duration) (model.RolloutStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	namespace := kc.config.SliceNamespace(sliceID)
+
+	w, err := kc.clientset.AppsV1().Deployments(namespace).Watch(ctx, v1.ListOptions{LabelSelector: sliceSelector(sliceID)})
+	if err != nil {
+		return model.RolloutStatus{}, fmt.Errorf("监听Deployment失败: %w", err)
+	}
+	defer w.Stop()
+	events := w.ResultChan()
+
+	// pod状态变化不会产生Deployment事件, 需定期检查容器
+	ticker := time.NewTicker(rolloutPollInterval)
+	defer ticker.Stop()
+
+	var status model.RolloutStatus
+	for {
+		status, err = kc.sliceRolloutStatus(ctx, sliceID, namespace)
+		if err != nil && ctx.Err() == nil {
+			return status, err
+		}
+		if status.Ready {
+			return status, nil
+		}
+		if len(status.Failures) > 0 {
+			return status, &RolloutError{Status: status}
+		}
+
+		select {
+		case <-ctx.Done():
+			status.Reason = fmt.Sprintf("%s内未完成滚动更新: %s", timeout, status.Reason)
+			return status, &RolloutError{Status: status}
+		case _, ok := <-events:
+			if !ok {
+				// watch被服务端关闭, 退化为轮询
+				events = nil
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// sliceRolloutStatus 汇总切片Deployment的滚动更新进度和失败的容器
+func (kc *KubeClient) sliceRolloutStatus(ctx context.Context, sliceID, namespace string) (model.RolloutStatus, error) {
+	var status model.RolloutStatus
+	deployments, err := kc.clientset.AppsV1().Deployments(namespace).List(ctx, v1.ListOptions{LabelSelector: sliceSelector(sliceID)})
+	if err != nil {
+		return status, fmt.Errorf("获取Deployment失败: %w", err)
+	}
+	if len(deployments.Items) == 0 {
+		// 只检查是否存在Deployment, 切片中SMF始终以Deployment部署
+		status.Reason = "未找到切片的Deployment"
+		return status, nil
+	}
+
+	// 多副本的UPF以StatefulSet部署
+	statefulSets, err := kc.clientset.AppsV1().StatefulSets(namespace).List(ctx, v1.ListOptions{LabelSelector: sliceSelector(sliceID)})
+	if err != nil {
+		return status, fmt.Errorf("获取StatefulSet失败: %w", err)
+	}
+
+	var pending []string
+	for _, d := range deployments.Items {
+		ds := deploymentStatus(d)
+		status.Deployments = append(status.Deployments, ds)
+		if !ds.Complete {
+			pending = append(pending, fmt.Sprintf("%s(%s)", ds.Name, ds.Message))
+		}
+	}
+	for _, s := range statefulSets.Items {
+		ss := statefulSetStatus(s)
+		status.Deployments = append(status.Deployments, ss)
+		if !ss.Complete {
+			pending = append(pending, fmt.Sprintf("%s(%s)", ss.Name, ss.Message))
+		}
+	}
+
+	pods, err := kc.clientset.CoreV1().Pods(namespace).List(ctx, v1.ListOptions{LabelSelector: sliceSelector(sliceID)})
+	if err != nil {
+		return status, fmt.Errorf("获取Pod失败: %w", err)
+	}
+	for _, pod := range pods.Items {
+		for _, cs := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			if cs.State.Waiting == nil || !failedWaitingReasons[cs.State.Waiting.Reason] {
+				continue
+			}
+			failure := model.ContainerFailure{
+				Pod:          pod.Name,
+				Container:    cs.Name,
+				Reason:       cs.State.Waiting.Reason,
+				Message:      cs.State.Waiting.Message,
+				RestartCount: cs.RestartCount,
+			}
+			if term := cs.LastTerminationState.Terminated; term != nil {
+				failure.LastReason = term.Reason
+				failure.ExitCode = term.ExitCode
+				if term.Message != "" {
+					failure.Message = term.Message
+				}
+			}
+			if cs.RestartCount > 0 {
+				failure.LogTail = kc.logTail(ctx, namespace, pod.Name, cs.Name)
+			}
+			status.Failures = append(status.Failures, failure)
+		}
+	}
+
+	switch {
+	case len(status.Failures) > 0:
+		f := status.Failures[0]
+		status.Reason = fmt.Sprintf("容器 %s/%s %s", f.Pod, f.Container, f.Reason)
+		if f.LastReason != "" {
+			status.Reason += fmt.Sprintf(", 上次退出: %s(%d)", f.LastReason, f.ExitCode)
+		}
+	case len(pending) > 0:
+		status.Reason = "等待 " + strings.Join(pending, ", ")
+	default:
+		status.Ready = true
+	}
+	return status, nil
+}
+
+// deploymentStatus 参照kubectl rollout status判断Deployment是否完成滚动更新
+func deploymentStatus(d appsv1.Deployment) model.DeploymentStatus {
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	ds := model.DeploymentStatus{
+		Name:      d.Name,
+		Replicas:  replicas,
+		Updated:   d.Status.UpdatedReplicas,
+		Ready:     d.Status.ReadyReplicas,
+		Available: d.Status.AvailableReplicas,
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			ds.Message = "超过progressDeadlineSeconds"
+			return ds
+		}
+	}
+	switch {
+	case d.Generation > d.Status.ObservedGeneration:
+		ds.Message = "等待新版本被观察到"
+	case ds.Updated < replicas:
+		ds.Message = fmt.Sprintf("已更新%d/%d个副本", ds.Updated, replicas)
+	case d.Status.Replicas > ds.Updated:
+		ds.Message = fmt.Sprintf("%d个旧副本等待终止", d.Status.Replicas-ds.Updated)
+	case ds.Available < ds.Updated:
+		ds.Message = fmt.Sprintf("可用%d/%d个副本", ds.Available, ds.Updated)
+	default:
+		ds.Complete = true
+	}
+	return ds
+}
+
+// statefulSetStatus 参照kubectl rollout status判断StatefulSet是否完成滚动更新
+func statefulSetStatus(s appsv1.StatefulSet) model.DeploymentStatus {
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+	ss := model.DeploymentStatus{
+		Kind:      "StatefulSet",
+		Name:      s.Name,
+		Replicas:  replicas,
+		Updated:   s.Status.UpdatedReplicas,
+		Ready:     s.Status.ReadyReplicas,
+		Available: s.Status.AvailableReplicas,
+	}
+	switch {
+	case s.Generation > s.Status.ObservedGeneration:
+		ss.Message = "等待新版本被观察到"
+	case ss.Ready < replicas:
+		ss.Message = fmt.Sprintf("就绪%d/%d个副本", ss.Ready, replicas)
+	case s.Spec.UpdateStrategy.Type == appsv1.RollingUpdateStatefulSetStrategyType && s.Status.UpdateRevision != s.Status.CurrentRevision:
+		ss.Message = fmt.Sprintf("已更新%d/%d个副本", ss.Updated, replicas)
+	default:
+		ss.Complete = true
+	}
+	return ss
+}
+
+// logTail 获取容器上次运行的最后几行日志, 失败时返回空
+func (kc *KubeClient) logTail(ctx context.Context, namespace, pod, container string) string {
+	tail := rolloutLogTailLines
+	data, err := kc.clientset.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container: container,
+		Previous:  true,
+		TailLines: &tail,
+	}).DoRaw(ctx)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func sliceSelector(sliceID string) string {
+	return "app=open5gs,slice=" + sliceID
+}
@@ -0,0 +1,66 @@
+package kubeclient
+
+import (
+	"slicer/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWaitForSlice(t *testing.T) {
+	labels := map[string]string{"app": "open5gs", "slice": "1-000001"}
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{Name: "open5gs-upf1-000001", Namespace: "open5gs", Labels: labels, Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1, ReadyReplicas: 1},
+	}
+	newClient := func(objs ...runtime.Object) *KubeClient {
+		config := util.Config{}
+		config.Namespace = "open5gs"
+		return &KubeClient{config: config, clientset: fakeclientset.NewSimpleClientset(objs...)}
+	}
+
+	// 已完成滚动更新
+	status, err := newClient(deployment).WaitForSlice("1-000001", time.Second)
+	require.NoError(t, err)
+	assert.True(t, status.Ready)
+	assert.True(t, status.Deployments[0].Complete)
+
+	// 容器反复崩溃时立即返回, 并带有上次退出原因和日志
+	pod := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "open5gs-upf1-000001-abc", Namespace: "open5gs", Labels: labels},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:                 "upf",
+			RestartCount:         3,
+			State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}},
+		}}},
+	}
+	progressing := deployment.DeepCopy()
+	progressing.Status.AvailableReplicas = 0
+	start := time.Now()
+	status, err = newClient(progressing, pod).WaitForSlice("1-000001", 10*time.Second)
+	var rolloutErr *RolloutError
+	require.ErrorAs(t, err, &rolloutErr)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	require.Len(t, status.Failures, 1)
+	assert.Equal(t, "Error", status.Failures[0].LastReason)
+	assert.Equal(t, int32(1), status.Failures[0].ExitCode)
+	assert.NotEmpty(t, status.Failures[0].LogTail)
+	assert.Contains(t, err.Error(), "CrashLoopBackOff")
+
+	// 超时
+	status, err = newClient(progressing).WaitForSlice("1-000001", 100*time.Millisecond)
+	require.ErrorAs(t, err, &rolloutErr)
+	assert.False(t, status.Ready)
+	assert.Contains(t, status.Reason, "可用0/1个副本")
+}
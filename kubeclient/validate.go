@@ -0,0 +1,304 @@
+package kubeclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	kyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// MultusNetworksAnnotation Multus附加网络的注解
+const MultusNetworksAnnotation = "k8s.v1.cni.cncf.io/networks"
+
+// SliceLabels 切片资源必须携带的标签
+var SliceLabels = []string{"app", "nf", "slice", "name"}
+
+// 严格模式, 未知字段和重复字段均视为错误, 可发现模板中的拼写错误
+var strictDecoder = serializer.NewCodecFactory(scheme.Scheme, serializer.EnableStrict).UniversalDeserializer()
+
+// multusNetwork Multus注解中的单个网络
+type multusNetwork struct {
+	Name      string   `json:"name"`
+	Interface string   `json:"interface"`
+	IPs       []string `json:"ips"`
+}
+
+// sliceBundle 解码后的切片资源
+type sliceBundle struct {
+	configMaps   []*corev1.ConfigMap
+	deployments  []*appsv1.Deployment
+	statefulSets []*appsv1.StatefulSet // 多副本的UPF
+	services     []*corev1.Service
+	policies     []*networkingv1.NetworkPolicy
+
+	sliceID string // 由validate填充
+}
+
+// ValidateSlice 在应用前对切片的全部渲染结果做预检
+// 每个文档被解码为ConfigMap/Deployment/Service/NetworkPolicy, 并检查标签, Multus注解, 内嵌的Open5GS配置以及资源间的引用
+// 返回的错误汇总了所有问题, 任何一个文档不合法时整个切片都不应被应用
+func ValidateSlice(docs [][]byte) error {
+	_, err := validateSlice(docs)
+	return err
+}
+
+// validateSlice 预检并返回资源所属的切片ID
+func validateSlice(docs [][]byte) (string, error) {
+	var (
+		bundle sliceBundle
+		errs   []error
+	)
+	for i, data := range docs {
+		objs, err := decodeDocuments(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("第%d个文件: %w", i+1, err))
+			continue
+		}
+		for _, obj := range objs {
+			switch o := obj.(type) {
+			case *corev1.ConfigMap:
+				bundle.configMaps = append(bundle.configMaps, o)
+			case *appsv1.Deployment:
+				bundle.deployments = append(bundle.deployments, o)
+			case *appsv1.StatefulSet:
+				bundle.statefulSets = append(bundle.statefulSets, o)
+			case *corev1.Service:
+				bundle.services = append(bundle.services, o)
+			case *networkingv1.NetworkPolicy:
+				bundle.policies = append(bundle.policies, o)
+			default:
+				errs = append(errs, fmt.Errorf("第%d个文件: 不支持的资源类型 %T", i+1, obj))
+			}
+		}
+	}
+	errs = append(errs, bundle.validate()...)
+	if len(errs) == 0 && bundle.sliceID == "" {
+		errs = append(errs, fmt.Errorf("未包含任何切片资源"))
+	}
+	if len(errs) > 0 {
+		return "", fmt.Errorf("切片资源预检失败: %w", kerrors.NewAggregate(errs))
+	}
+	return bundle.sliceID, nil
+}
+
+// decodeDocuments 将多文档YAML解码为typed对象, 空文档被忽略
+func decodeDocuments(data []byte) ([]runtime.Object, error) {
+	var objs []runtime.Object
+	reader := kyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取YAML失败: %w", err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		obj, gvk, err := strictDecoder.Decode(doc, nil, nil)
+		if err != nil {
+			if gvk != nil && gvk.Kind != "" {
+				return nil, fmt.Errorf("解码 %s 失败: %w", gvk.Kind, err)
+			}
+			return nil, fmt.Errorf("解码失败: %w", err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+func (b *sliceBundle) validate() (errs []error) {
+	// 同一bundle中的资源必须属于同一个切片
+	checkLabels := func(ref string, labels map[string]string) {
+		for _, key := range SliceLabels {
+			if labels[key] == "" {
+				errs = append(errs, fmt.Errorf("%s: 缺少标签 %s", ref, key))
+			}
+		}
+		if id := labels["slice"]; id != "" {
+			if b.sliceID == "" {
+				b.sliceID = id
+			} else if id != b.sliceID {
+				errs = append(errs, fmt.Errorf("%s: 标签slice=%s 与其他资源的 %s 不一致", ref, id, b.sliceID))
+			}
+		}
+	}
+
+	configMaps := make(map[string]*corev1.ConfigMap)
+	for _, cm := range b.configMaps {
+		ref := "ConfigMap/" + cm.Name
+		checkLabels(ref, cm.Labels)
+		configMaps[cm.Name] = cm
+		for key, content := range cm.Data {
+			if !strings.HasSuffix(key, ".yaml") && !strings.HasSuffix(key, ".yml") {
+				continue
+			}
+			var cfg map[string]any
+			if err := yaml.Unmarshal([]byte(content), &cfg); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s 不是合法的YAML: %w", ref, key, err))
+			} else if len(cfg) == 0 {
+				errs = append(errs, fmt.Errorf("%s: %s 为空", ref, key))
+			}
+		}
+	}
+
+	// Deployment与StatefulSet的检查相同
+	var templates []*corev1.PodTemplateSpec
+	checkWorkload := func(ref string, labels map[string]string, selector *metav1.LabelSelector, template *corev1.PodTemplateSpec) {
+		templates = append(templates, template)
+		checkLabels(ref, labels)
+		podLabels := template.Labels
+		checkLabels(ref+"(pod)", podLabels)
+		if selector == nil || len(selector.MatchLabels) == 0 {
+			errs = append(errs, fmt.Errorf("%s: 缺少selector", ref))
+		} else if !selects(selector.MatchLabels, podLabels) {
+			errs = append(errs, fmt.Errorf("%s: selector与pod标签不匹配", ref))
+		}
+		if raw, ok := template.Annotations[MultusNetworksAnnotation]; ok {
+			errs = append(errs, validateMultus(ref, raw)...)
+		}
+		for _, c := range append(template.Spec.InitContainers, template.Spec.Containers...) {
+			if c.Image == "" {
+				errs = append(errs, fmt.Errorf("%s: 容器 %s 缺少镜像", ref, c.Name))
+			}
+		}
+		errs = append(errs, validateVolumes(ref, template.Spec.Volumes, configMaps)...)
+	}
+	for _, d := range b.deployments {
+		checkWorkload("Deployment/"+d.Name, d.Labels, d.Spec.Selector, &d.Spec.Template)
+	}
+	for _, s := range b.statefulSets {
+		checkWorkload("StatefulSet/"+s.Name, s.Labels, s.Spec.Selector, &s.Spec.Template)
+	}
+
+	for _, svc := range b.services {
+		ref := "Service/" + svc.Name
+		checkLabels(ref, svc.Labels)
+		if len(svc.Spec.Selector) == 0 {
+			errs = append(errs, fmt.Errorf("%s: 缺少selector", ref))
+			continue
+		}
+		matched := false
+		for _, template := range templates {
+			if selects(svc.Spec.Selector, template.Labels) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Errorf("%s: selector未匹配到任何工作负载", ref))
+		}
+	}
+
+	// 网络策略只能选择切片中的工作负载, 空的podSelector会作用于命名空间中的所有Pod
+	for _, np := range b.policies {
+		ref := "NetworkPolicy/" + np.Name
+		checkLabels(ref, np.Labels)
+		selector := np.Spec.PodSelector.MatchLabels
+		if len(selector) == 0 {
+			errs = append(errs, fmt.Errorf("%s: 缺少podSelector", ref))
+			continue
+		}
+		if selector["slice"] != np.Labels["slice"] {
+			errs = append(errs, fmt.Errorf("%s: podSelector未限定本切片", ref))
+		}
+		matched := false
+		for _, template := range templates {
+			if selects(selector, template.Labels) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Errorf("%s: podSelector未匹配到任何工作负载", ref))
+		}
+	}
+	return errs
+}
+
+// validateMultus 检查Multus注解是否为合法的网络列表, 地址需为IP或CIDR
+func validateMultus(ref, raw string) (errs []error) {
+	var networks []multusNetwork
+	if err := json.Unmarshal([]byte(raw), &networks); err != nil {
+		return []error{fmt.Errorf("%s: Multus注解不是合法的JSON: %w", ref, err)}
+	}
+	if len(networks) == 0 {
+		return []error{fmt.Errorf("%s: Multus注解为空", ref)}
+	}
+	interfaces := make(map[string]bool)
+	for _, n := range networks {
+		if n.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: Multus网络缺少name", ref))
+		}
+		if n.Interface != "" {
+			if interfaces[n.Interface] {
+				errs = append(errs, fmt.Errorf("%s: Multus接口 %s 重复", ref, n.Interface))
+			}
+			interfaces[n.Interface] = true
+		}
+		for _, ip := range n.IPs {
+			if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+				errs = append(errs, fmt.Errorf("%s: Multus网络 %s 的地址 %q 不合法", ref, n.Name, ip))
+			}
+		}
+	}
+	return errs
+}
+
+// validateVolumes 检查挂载的ConfigMap存在于同一bundle中, 且引用的key存在
+func validateVolumes(ref string, volumes []corev1.Volume, configMaps map[string]*corev1.ConfigMap) (errs []error) {
+	check := func(src *corev1.ConfigMapVolumeSource) {
+		if src == nil {
+			return
+		}
+		cm, ok := configMaps[src.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: 引用的ConfigMap %s 不存在", ref, src.Name))
+			return
+		}
+		for _, item := range src.Items {
+			if _, ok := cm.Data[item.Key]; !ok {
+				errs = append(errs, fmt.Errorf("%s: ConfigMap %s 中不存在key %s", ref, src.Name, item.Key))
+			}
+		}
+	}
+	for _, v := range volumes {
+		check(v.ConfigMap)
+		if v.Projected != nil {
+			for _, s := range v.Projected.Sources {
+				if s.ConfigMap != nil {
+					check(&corev1.ConfigMapVolumeSource{
+						LocalObjectReference: s.ConfigMap.LocalObjectReference,
+						Items:                s.ConfigMap.Items,
+					})
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// selects 判断selector中的标签是否都包含在labels中
+func selects(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package kubeclient
+
+import (
+	"bytes"
+	"slicer/model"
+	"slicer/render"
+	"slicer/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSlice(t *testing.T) {
+	r, err := render.NewRender(util.Config{})
+	require.NoError(t, err)
+	contents, err := r.RenderSlice(model.SliceAndAddress{
+		Slice: model.Slice{
+			SST:      1,
+			SD:       "000001",
+			Sessions: []model.Session{{Name: "internet"}},
+		},
+		AddressValue: model.AddressValue{
+			SessionSubnets: []string{"10.40.0.0/16"},
+			UPFN3Addr:      "10.10.3.1/16",
+			UPFN4Addr:      "10.10.4.1/16",
+			SMFN3Addr:      "10.10.3.2/16",
+			SMFN4Addr:      "10.10.4.2/16",
+		},
+	})
+	require.NoError(t, err)
+
+	// 模板渲染的结果应通过预检
+	require.NoError(t, ValidateSlice(contents))
+
+	// 逐个破坏文档, 每种错误都应被发现
+	broken := func(i int, old, new string) [][]byte {
+		docs := make([][]byte, len(contents))
+		copy(docs, contents)
+		require.Contains(t, string(docs[i]), old)
+		docs[i] = bytes.Replace(docs[i], []byte(old), []byte(new), 1)
+		return docs
+	}
+	cases := map[string]struct {
+		docs [][]byte
+		want string
+	}{
+		"缺少标签":      {broken(0, "nf: smf", "role: smf"), "缺少标签 nf"},
+		"未知字段":      {broken(1, "containers:", "contianers:"), "contianers"},
+		"Multus注解":  {broken(1, `"interface": "n4",`, `"interface": "n4"`), "Multus注解不是合法的JSON"},
+		"Open5GS配置": {broken(0, "ue:", "ue: [1"), "smfcfg.yaml 不是合法的YAML"},
+		"切片不一致":     {broken(2, "slice: 1-000001", "slice: 1-000002"), "不一致"},
+	}
+	for name, c := range cases {
+		err := ValidateSlice(c.docs)
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), c.want, name)
+		}
+	}
+}
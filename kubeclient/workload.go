@@ -0,0 +1,86 @@
+package kubeclient
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// workload Play作用的工作负载, UPF多副本时为StatefulSet, 其余为Deployment
+// template指向可修改的副本, update提交修改, restore基于最新版本恢复获取时的spec
+type workload struct {
+	kind        string
+	name        string
+	template    *corev1.PodTemplateSpec
+	setReplicas func(int32)
+	update      func(ctx context.Context) error
+	restore     func(ctx context.Context) error
+}
+
+func (w *workload) ref() string {
+	return w.kind + "/" + w.name
+}
+
+// getWorkload 按名称获取Deployment, 不存在时获取同名的StatefulSet
+func (kc *KubeClient) getWorkload(ctx context.Context, namespace, name string) (*workload, error) {
+	deployments := kc.clientset.AppsV1().Deployments(namespace)
+	d, err := deployments.Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		snapshot := d.Spec.DeepCopy()
+		return &workload{
+			kind:        "Deployment",
+			name:        name,
+			template:    &d.Spec.Template,
+			setReplicas: func(n int32) { d.Spec.Replicas = &n },
+			update: func(ctx context.Context) error {
+				_, err := deployments.Update(ctx, d, metav1.UpdateOptions{})
+				return err
+			},
+			restore: func(ctx context.Context) error {
+				// 基于最新版本恢复spec, 避免resourceVersion冲突
+				current, err := deployments.Get(ctx, name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				current.Spec = *snapshot
+				_, err = deployments.Update(ctx, current, metav1.UpdateOptions{})
+				return err
+			},
+		}, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	statefulSets := kc.clientset.AppsV1().StatefulSets(namespace)
+	s, err := statefulSets.Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, fmt.Errorf("Deployment或StatefulSet %s 不存在", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot := s.Spec.DeepCopy()
+	return &workload{
+		kind:        "StatefulSet",
+		name:        name,
+		template:    &s.Spec.Template,
+		setReplicas: func(n int32) { s.Spec.Replicas = &n },
+		update: func(ctx context.Context) error {
+			_, err := statefulSets.Update(ctx, s, metav1.UpdateOptions{})
+			return err
+		},
+		restore: func(ctx context.Context) error {
+			current, err := statefulSets.Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			current.Spec = *snapshot
+			_, err = statefulSets.Update(ctx, current, metav1.UpdateOptions{})
+			return err
+		},
+	}, nil
+}
@@ -1,5 +1,7 @@
 package kubeclient
 
+import "context"
+
 // MDE
 func (kc *KubeClient) ApplyMDE(mde []byte) error {
 	return kc.Apply(mde, kc.config.Namespace)
@@ -19,10 +21,36 @@ func (kc *KubeClient) DeleteKpic(kpi []byte) error {
 }
 
 // slice
+// ApplySlice 先对全部资源做预检, 预检失败时不应用任何资源
+// 应用后删除该切片不再渲染的资源; 切片独立命名空间时先创建命名空间
 func (kc *KubeClient) ApplySlice(slice [][]byte) error {
-	return kc.ApplyMulti(slice, kc.config.Namespace)
+	sliceID, err := validateSlice(slice)
+	if err != nil {
+		return err
+	}
+	if err := kc.ensureSliceNamespace(context.TODO(), sliceID); err != nil {
+		return err
+	}
+	if err := kc.applyMulti(slice, kc.config.SliceNamespace(sliceID), map[string]string{SliceOwnerLabel: sliceID}); err != nil {
+		return err
+	}
+	kc.prune(sliceID, slice)
+	return nil
 }
 
+// DeleteSlice 删除渲染出的资源, 以及带有该切片归属标签的其他资源
+// 切片独立命名空间时最后删除命名空间
 func (kc *KubeClient) DeleteSlice(slice [][]byte) error {
-	return kc.DeleteMulti(slice, kc.config.Namespace)
+	_, sliceID, err := bundleObjects(slice)
+	if err != nil {
+		return err
+	}
+	if err := kc.DeleteMulti(slice, kc.config.SliceNamespace(sliceID)); err != nil {
+		return err
+	}
+	if sliceID != "" {
+		kc.prune(sliceID, nil)
+		return kc.deleteSliceNamespace(context.TODO(), sliceID)
+	}
+	return nil
 }
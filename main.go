@@ -1,11 +1,15 @@
 package main
 
 import (
+	"flag"
 	"log/slog"
 	"os"
 	"slicer/ai"
+	"slicer/archive"
+	"slicer/backup"
 	"slicer/controller"
 	"slicer/db"
+	"slicer/delivery"
 	"slicer/kubeclient"
 	"slicer/monitor"
 	"slicer/render"
@@ -26,6 +30,11 @@ import (
 // @host localhost:30001
 // @BasePath /
 func main() {
+	// 命令行参数, 用于从备份恢复
+	restoreKey := flag.String("restore", "", "从对象存储中的备份恢复后退出, 值为备份key或latest")
+	reapply := flag.Bool("reapply", false, "恢复后重新向集群应用所有切片资源, 需配合-restore使用")
+	flag.Parse()
+
 	// 采用slog作为日志库
 	// slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 	// 	Level: slog.LevelDebug,
@@ -50,14 +59,28 @@ func main() {
 	monitor := monitor.NewMonitor(config)
 
 	// 初始化渲染器
-	render := render.NewRender(config)
+	render, err := render.NewRender(config)
+	if err != nil {
+		slog.Error("初始化渲染器失败", "error", err)
+		os.Exit(1)
+	}
+	if err := render.Watch(); err != nil {
+		slog.Error("监听模板目录失败", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("模板加载完成", "version", render.Templates().Version, "override", config.TemplatePath)
 
-	// 初始化Kubernetes客户端
-	kubeclient, err := kubeclient.NewKubeClient(config)
+	// 初始化各集群的Kubernetes客户端
+	clusters, err := kubeclient.NewClusters(config)
 	if err != nil {
 		slog.Error("创建Kubernetes客户端失败", "error", err)
 		os.Exit(1)
 	}
+	defer clusters.Close()
+	slog.Info("已注册集群", "clusters", clusters.Names())
+
+	// 在各集群中创建并校验Multus网络
+	reconcileNetworks(config, clusters)
 
 	// 初始化IPAM
 	ipam, err := db.NewIPAM(config)
@@ -66,35 +89,123 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 初始化交付方式
+	deliverer, err := delivery.NewDeliverer(config, render, clusters)
+	if err != nil {
+		slog.Error("初始化交付方式失败", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("交付方式", "mode", deliverer.Mode())
+
+	// 初始化对象存储, 未配置时为nil, 备份和归档均不启用
+	oss := newOSS(config)
+
+	// 初始化备份
+	backup := newBackup(config, oss, store, ipam, render, clusters, deliverer)
+	if *restoreKey != "" {
+		if backup == nil {
+			slog.Error("未配置对象存储, 无法恢复")
+			os.Exit(1)
+		}
+		manifest, err := backup.Restore(*restoreKey, *reapply)
+		if err != nil {
+			slog.Error("恢复失败", "key", *restoreKey, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("恢复完成", "key", *restoreKey, "备份时间", manifest.CreatedAt)
+		return
+	}
+	if backup != nil {
+		backup.Start()
+	}
+
+	// 初始化归档
+	archive := newArchive(config, oss)
+
 	// 启动控制器
-	controller := runController(config, store, kubeclient)
+	controller := runController(config, store, clusters)
+	controller.SetDeliverer(deliverer)
+	if archive != nil {
+		archive.Start()
+		controller.SetRecorder(archive)
+	}
 
-	// 初始化Server
+	// 初始化Server, SLA或Play写入后立即控制切片
+	// 控制器使用未包装的存储, 其自身写入的Play和SLA不会再次触发控制
+	notifyStore := db.NewNotifyStore(store, reconcileOnChange(controller))
 	server := server.NewServer(server.NewSeverArg{
 		Config:     config,
-		Store:      store,
-		KubeClient: kubeclient,
+		Store:      notifyStore,
+		Clusters:   clusters,
 		Monitor:    monitor,
 		Render:     render,
 		IPAM:       ipam,
 		Controller: controller,
+		Deliverer:  deliverer,
+		Backup:     backup,
+		Archive:    archive,
 	})
 
+	// 控制器应用Play后同样归档切片版本
+	if archive != nil {
+		controller.SetArchiver(server)
+	}
+
 	// 启动HTTP服务器
 	slog.Info("启动HTTP服务器", "address", config.HTTPServerAddress)
-	server.Start()
+	if err := server.Start(); err != nil {
+		slog.Error("HTTP服务器退出", "error", err)
+	}
 }
 
 // 注册并启动controller
-func runController(config util.Config, store db.Store, kclient *kubeclient.KubeClient) controller.Controller {
+func runController(config util.Config, store db.Store, clusters *kubeclient.Clusters) controller.Controller {
 	basicStrategy := newBasicStrategy(config)
 	aiStrategy := newAIStrategy(config)
-	controller := controller.NewBasicController(config, store, kclient, basicStrategy, aiStrategy)
+	controller := controller.NewBasicController(config, store, clusters, basicStrategy, aiStrategy)
 	controller.Start()
 	slog.Info("控制器已启动", "频率", controller.GetFrequency(), "策略", controller.GetStrategy().Name())
 	return controller
 }
 
+// reconcileOnChange 存储中切片的SLA或Play变化后立即控制切片
+// 切片不在控制器中、未启用或控制器未运行时只记录日志
+func reconcileOnChange(controller controller.Controller) db.ChangeHook {
+	return func(sliceID string, reason string) {
+		if err := controller.Reconcile(sliceID, reason); err != nil {
+			slog.Debug("未触发切片控制", "sliceID", sliceID, "reason", reason, "error", err)
+		}
+	}
+}
+
+// 按IPAM配置在各集群中创建并校验Multus网络, 未配置时跳过
+// 与已有NAD不一致或集群无法连接时只记录错误, 不影响启动
+func reconcileNetworks(config util.Config, clusters *kubeclient.Clusters) {
+	specs, err := kubeclient.NetworkSpecs(config.IPAMConfig)
+	if err != nil {
+		slog.Error("Multus网络配置错误", "error", err)
+		os.Exit(1)
+	}
+	if len(specs) == 0 {
+		slog.Info("未配置NAD_MASTER和N6_NETWORKS, 不管理Multus网络")
+		return
+	}
+	for _, cluster := range clusters.Names() {
+		kclient, err := clusters.Get(cluster)
+		if err != nil {
+			slog.Error("跳过Multus网络", "cluster", cluster, "error", err)
+			continue
+		}
+		statuses, err := kclient.ReconcileNetworks(specs)
+		for _, status := range statuses {
+			slog.Info("Multus网络", "cluster", cluster, "name", status.Name, "action", status.Action, "detail", status.Detail)
+		}
+		if err != nil {
+			slog.Error("Multus网络与配置不一致", "cluster", cluster, "error", err)
+		}
+	}
+}
+
 // 测试用基本策略
 func newBasicStrategy(config util.Config) controller.Strategy {
 	// 初始化metrics源
@@ -117,3 +228,43 @@ func newAIStrategy(config util.Config) controller.Strategy {
 	}
 	return ai
 }
+
+// 初始化对象存储客户端, 未设置OSS_ENDPOINT时返回nil
+func newOSS(config util.Config) db.OSS {
+	if config.OSSEndpoint == "" {
+		slog.Info("未配置对象存储, 不启用备份与归档")
+		return nil
+	}
+	oss, err := db.NewMinioOSS(config.OSSEndpoint, config.OSSAccessKey, config.OSSSecretKey, config.OSSUseSSL)
+	if err != nil {
+		slog.Error("创建对象存储客户端失败", "error", err)
+		os.Exit(1)
+	}
+	return oss
+}
+
+// 初始化备份, 未配置对象存储时返回nil
+func newBackup(config util.Config, oss db.OSS, store db.Store, ipam *db.IPAM, render *render.Render, clusters *kubeclient.Clusters, deliverer delivery.Deliverer) *backup.Backup {
+	if oss == nil {
+		return nil
+	}
+	b, err := backup.NewBackup(config, store, ipam, oss, render, clusters, deliverer)
+	if err != nil {
+		slog.Error("初始化备份失败", "error", err)
+		os.Exit(1)
+	}
+	return b
+}
+
+// 初始化归档, 未配置对象存储时返回nil
+func newArchive(config util.Config, oss db.OSS) *archive.Archive {
+	if oss == nil {
+		return nil
+	}
+	a, err := archive.NewArchive(config, oss)
+	if err != nil {
+		slog.Error("初始化归档失败", "error", err)
+		os.Exit(1)
+	}
+	return a
+}
@@ -18,7 +18,7 @@ func TestSwagger(t *testing.T) {
 		Store:      nil,
 		IPAM:       nil,
 		Render:     nil,
-		KubeClient: nil,
+		Clusters:   nil,
 		Controller: nil,
 	})
 	server.Start()
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MinControlInterval 单个切片的最小控制间隔
+const MinControlInterval = 10 * time.Second
+
+// SliceControl 控制器对单个切片的调度设置
+type SliceControl struct {
+	ID      primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SliceID string             `json:"slice_id"`
+
+	// 控制间隔, 为0时使用控制器的全局频率
+	Interval time.Duration `json:"interval" swaggertype:"integer" format:"nanoseconds" example:"60000000000"`
+	// 使用的策略名称, 为空时使用控制器的全局策略
+	Strategy string `json:"strategy,omitempty"`
+	// 是否控制该切片
+	Enabled bool `json:"enabled"`
+}
+
+// DefaultSliceControl 新加入控制器的切片的设置: 启用, 使用全局频率和策略
+func DefaultSliceControl(sliceID string) SliceControl {
+	return SliceControl{SliceID: sliceID, Enabled: true}
+}
+
+func (c *SliceControl) Validate() error {
+	if c.SliceID == "" {
+		return fmt.Errorf("切片ID不能为空")
+	}
+	if c.Interval != 0 && c.Interval < MinControlInterval {
+		return fmt.Errorf("控制间隔不能小于%s", MinControlInterval)
+	}
+	return nil
+}
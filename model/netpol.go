@@ -0,0 +1,91 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 放行规则名称, 作为NetworkPolicy名称的一部分
+var allowRuleNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+// NetworkAllowRule 在切片默认隔离策略之外额外放行的入站流量
+// 每条规则生成一个NetworkPolicy, 与默认策略叠加生效
+type NetworkAllowRule struct {
+	Name  string                           `json:"name"`            // 规则名称, 同一Play中唯一
+	NF    string                           `json:"nf"`              // 放行流量的目标NF, 如 "upf"
+	From  []networkingv1.NetworkPolicyPeer `json:"from,omitempty"`  // 来源, 为空表示任意来源
+	Ports []networkingv1.NetworkPolicyPort `json:"ports,omitempty"` // 端口, 为空表示所有端口
+}
+
+// PolicyName 规则对应的NetworkPolicy名称
+func (r NetworkAllowRule) PolicyName(sliceID string) string {
+	return fmt.Sprintf("%s%s-allow-%s", r.NF, sliceID, r.Name)
+}
+
+// NetworkPolicy 生成规则对应的NetworkPolicy, 选择切片中该NF的Pod
+func (r NetworkAllowRule) NetworkPolicy(sliceID string) networkingv1.NetworkPolicy {
+	return networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   r.PolicyName(sliceID),
+			Labels: map[string]string{"app": "open5gs", "nf": r.NF, "slice": sliceID, "name": r.NF + sliceID},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "open5gs", "nf": r.NF, "slice": sliceID}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: r.From, Ports: r.Ports}},
+		},
+	}
+}
+
+func (r NetworkAllowRule) Validate() error {
+	if !allowRuleNamePattern.MatchString(r.Name) {
+		return fmt.Errorf("非法的规则名称 %q", r.Name)
+	}
+	if !nfNamePattern.MatchString(r.NF) {
+		return fmt.Errorf("%s: 非法的NF名称 %q", r.Name, r.NF)
+	}
+	for _, peer := range r.From {
+		if peer.IPBlock != nil {
+			if _, _, err := net.ParseCIDR(peer.IPBlock.CIDR); err != nil {
+				return fmt.Errorf("%s: 非法的CIDR %q", r.Name, peer.IPBlock.CIDR)
+			}
+			for _, except := range peer.IPBlock.Except {
+				if _, _, err := net.ParseCIDR(except); err != nil {
+					return fmt.Errorf("%s: 非法的CIDR %q", r.Name, except)
+				}
+			}
+			if peer.PodSelector != nil || peer.NamespaceSelector != nil {
+				return fmt.Errorf("%s: ipBlock不能与podSelector/namespaceSelector同时使用", r.Name)
+			}
+			continue
+		}
+		if peer.PodSelector == nil && peer.NamespaceSelector == nil {
+			return fmt.Errorf("%s: 来源需指定ipBlock、podSelector或namespaceSelector", r.Name)
+		}
+		if err := validateLabelSelector(peer.PodSelector); err != nil {
+			return fmt.Errorf("%s: %w", r.Name, err)
+		}
+		if err := validateLabelSelector(peer.NamespaceSelector); err != nil {
+			return fmt.Errorf("%s: %w", r.Name, err)
+		}
+	}
+	for _, port := range r.Ports {
+		if port.Protocol != nil {
+			switch *port.Protocol {
+			case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+			default:
+				return fmt.Errorf("%s: 不支持的协议 %q", r.Name, *port.Protocol)
+			}
+		}
+		if port.EndPort != nil && (port.Port == nil || port.Port.IntValue() == 0 || *port.EndPort < int32(port.Port.IntValue())) {
+			return fmt.Errorf("%s: endPort需配合数字端口使用且不小于port", r.Name)
+		}
+	}
+	return nil
+}
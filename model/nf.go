@@ -0,0 +1,111 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// NFParams 切片中网络功能(SMF/UPF)的可选参数, 未设置的字段使用模板默认值
+type NFParams struct {
+	SMF NFParam `json:"smf" yaml:"smf"`
+	UPF NFParam `json:"upf" yaml:"upf"`
+}
+
+// maxDNSServers Pod的dnsConfig.nameservers的上限
+const maxDNSServers = 3
+
+// NFParam 单个网络功能的参数, 均为可选
+type NFParam struct {
+	Image string `json:"image,omitempty" yaml:"image,omitempty"` // 容器镜像, 默认 ghcr.io/niloysh/open5gs:v2.7.0-upf-metrics-v2
+	// SMF: 通过PDU会话下发给UE的DNS服务器, 默认 8.8.8.8, 8.8.4.4
+	// UPF: 追加到Pod的DNS服务器
+	// 最多3个
+	DNS []string `json:"dns,omitempty" yaml:"dns,omitempty"`
+	// SMF: 下发给UE的MTU; UPF: ogstun设备的MTU; 默认1400
+	MTU          int               `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+	MaxUE        int               `json:"max_ue,omitempty" yaml:"max_ue,omitempty"`               // 最大UE数, 默认1024
+	LogLevel     string            `json:"log_level,omitempty" yaml:"log_level,omitempty"`         // fatal/error/warn/info/debug/trace, 默认info
+	LogFile      string            `json:"log_file,omitempty" yaml:"log_file,omitempty"`           // 日志文件路径, 默认 /open5gs/install/var/log/open5gs/<nf>.log
+	NodeSelector map[string]string `json:"node_selector,omitempty" yaml:"node_selector,omitempty"` // 节点标签选择器
+	Env          map[string]string `json:"env,omitempty" yaml:"env,omitempty"`                     // 额外的环境变量
+	Resources    *ResourceSpec     `json:"resources,omitempty" yaml:"resources,omitempty"`         // 资源请求与限制, 默认 200m/256Mi, 500m/512Mi
+}
+
+// Open5GS支持的日志级别
+var nfLogLevels = []string{"fatal", "error", "warn", "info", "debug", "trace"}
+
+// Validate 校验SMF和UPF参数
+func (p *NFParams) Validate() error {
+	var errs []error
+	if err := p.SMF.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("SMF参数错误：%w", err))
+	}
+	if err := p.UPF.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("UPF参数错误：%w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate 校验单个网络功能的参数, 空值表示使用默认值
+func (p *NFParam) Validate() error {
+	var errs []error
+
+	if p.Image != "" && strings.ContainsAny(p.Image, " \t\n") {
+		errs = append(errs, fmt.Errorf("镜像名称不能包含空白字符：%q", p.Image))
+	}
+
+	// UPF的DNS服务器写入Pod的dnsConfig.nameservers, Kubernetes最多允许3个
+	if len(p.DNS) > maxDNSServers {
+		errs = append(errs, fmt.Errorf("DNS服务器最多%d个", maxDNSServers))
+	}
+	for _, dns := range p.DNS {
+		if net.ParseIP(dns) == nil {
+			errs = append(errs, fmt.Errorf("DNS服务器地址非法：%q", dns))
+		}
+	}
+
+	if p.MTU != 0 && (p.MTU < 576 || p.MTU > 9000) {
+		errs = append(errs, fmt.Errorf("MTU超出范围（允许值：576-9000）：%d", p.MTU))
+	}
+
+	if p.MaxUE < 0 || p.MaxUE > 1000000 {
+		errs = append(errs, fmt.Errorf("最大UE数超出范围（允许值：1-1000000）：%d", p.MaxUE))
+	}
+
+	if p.LogLevel != "" && !slices.Contains(nfLogLevels, p.LogLevel) {
+		errs = append(errs, fmt.Errorf("日志级别非法（允许值：%s）：%q", strings.Join(nfLogLevels, "/"), p.LogLevel))
+	}
+
+	if p.LogFile != "" && (!path.IsAbs(p.LogFile) || strings.ContainsAny(p.LogFile, " \t\n")) {
+		errs = append(errs, fmt.Errorf("日志文件必须为不含空白字符的绝对路径：%q", p.LogFile))
+	}
+
+	for k, v := range p.NodeSelector {
+		if msgs := validation.IsQualifiedName(k); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("节点选择器键非法 %q：%s", k, strings.Join(msgs, "; ")))
+		}
+		if msgs := validation.IsValidLabelValue(v); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("节点选择器值非法 %q：%s", v, strings.Join(msgs, "; ")))
+		}
+	}
+
+	for k := range p.Env {
+		if msgs := validation.IsEnvVarName(k); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("环境变量名非法 %q：%s", k, strings.Join(msgs, "; ")))
+		}
+	}
+
+	if p.Resources != nil {
+		if err := p.Resources.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("资源参数错误：%w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
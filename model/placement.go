@@ -0,0 +1,48 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Placement 切片部署的集群, 为空时部署到默认集群
+// Cluster与SiteSelector只能设置一个, 创建切片时解析为具体集群并记录在SliceAndAddress.Cluster中
+type Placement struct {
+	Cluster      string            `json:"cluster,omitempty" yaml:"cluster,omitempty"`             // 集群名称
+	SiteSelector map[string]string `json:"site_selector,omitempty" yaml:"site_selector,omitempty"` // 按集群的站点标签选择, 需匹配全部标签
+}
+
+// Validate 校验放置规则
+func (p *Placement) Validate() error {
+	var errs []error
+	if p.Cluster != "" && len(p.SiteSelector) > 0 {
+		errs = append(errs, errors.New("cluster与site_selector不能同时设置"))
+	}
+	if p.Cluster != "" {
+		for _, msg := range validation.IsDNS1123Label(p.Cluster) {
+			errs = append(errs, fmt.Errorf("集群名称非法：%s", msg))
+		}
+	}
+	for k, v := range p.SiteSelector {
+		if msgs := validation.IsQualifiedName(k); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("站点标签 %q 非法：%s", k, strings.Join(msgs, "; ")))
+		}
+		if msgs := validation.IsValidLabelValue(v); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("站点标签 %s 的值 %q 非法：%s", k, v, strings.Join(msgs, "; ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Matches 判断集群标签是否满足SiteSelector
+func (p *Placement) Matches(labels map[string]string) bool {
+	for k, v := range p.SiteSelector {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
@@ -3,9 +3,14 @@ package model
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"slices"
+	"sort"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 )
 
@@ -20,18 +25,320 @@ type Play struct {
 	// 网络带宽限制（适用于部分 CNI）
 	Bandwidth BandwidthSpec `json:"bandwidth"`
 
+	// 带宽限制的执行方式, 为空时使用全局配置; tc只作用于UPF, 首次启用时UPF的Pod会重建一次
+	BandwidthEnforcer string `json:"bandwidth_enforcer,omitempty"`
+
 	// 优先级
 	Priority Priority `json:"priority"` // 数值越大优先级越高，例如 1000
 
-	// 保留字段, 目前不使用
 	// Pod 调度规则
 	Scheduling SchedulingSpec `json:"scheduling"`
 
 	// 网络策略（前端可传入完整策略结构）
 	NetworkPolicy networkingv1.NetworkPolicy `json:"network_policy"`
 
+	// 在切片默认隔离策略之外放行的流量, 设置后整体替换, 空列表表示清除
+	AllowRules []NetworkAllowRule `json:"allow_rules,omitempty"`
+
 	// 特定插件使用的注解（如限速、带宽隔离）
 	Annotations map[string]string `json:"annotations"`
+
+	// 按NF划分的参数, 键为NF名称(如 "smf"), 作用于Deployment open5gs-<nf><切片ID>
+	// 顶层的资源/带宽/优先级/调度/注解即UPF的参数, nfs中的upf只覆盖其中非空的部分
+	NFs map[string]NFPlay `json:"nfs,omitempty"`
+
+	// 由运维固定的参数, 控制器的策略不再调整, 可为 "resources" 和 "bandwidth", 作用于顶层及所有NF
+	// 设置后整体替换, 空列表表示交还给策略
+	OperatorOwned []string `json:"operator_owned,omitempty"`
+}
+
+// 可由运维固定的参数
+const (
+	OwnedResources = "resources"
+	OwnedBandwidth = "bandwidth"
+)
+
+// Owns 参数是否由运维固定
+func (p *Play) Owns(field string) bool {
+	return slices.Contains(p.OperatorOwned, field)
+}
+
+// NFPlay 单个NF的Play参数, 未设置的部分不做修改
+type NFPlay struct {
+	// 容器名称, 为空时依次匹配 <nf> 和 <nf><切片ID>
+	Container   string            `json:"container,omitempty"`
+	Resources   ResourceSpec      `json:"resources"`
+	Bandwidth   BandwidthSpec     `json:"bandwidth"`
+	Priority    Priority          `json:"priority"` // 0表示不设置
+	Scheduling  SchedulingSpec    `json:"scheduling"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// 副本数, 0表示不修改; 设置autoscaling时由HPA管理副本数
+	// 目前只有UPF支持多副本, 每个副本从IPAM分配独立的N3/N4地址
+	Replicas    int32            `json:"replicas,omitempty"`
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+}
+
+// 带宽限制的执行方式
+const (
+	EnforcerAnnotation = "annotation" // CNI bandwidth插件的Pod注解, 作用于eth0, 修改后Pod重建
+	EnforcerTC         = "tc"         // 由UPF Pod中的tc边车直接作用于N3/N6接口, 首次启用时添加边车会重建Pod, 之后修改无需重建; 其余NF改用注解
+)
+
+// MaxUPFReplicas UPF副本数上限, 每个副本都会占用N3/N4地址
+const MaxUPFReplicas = 16
+
+// AutoscalingSpec HPA参数, CPU利用率与自定义指标至少设置一个
+type AutoscalingSpec struct {
+	MinReplicas int32 `json:"min_replicas"`
+	MaxReplicas int32 `json:"max_replicas"`
+	// 目标CPU利用率(相对于请求值的百分比)
+	TargetCPUUtilization int32 `json:"target_cpu_utilization,omitempty"`
+	// 自定义的Pod指标, 如每个UPF的吞吐量
+	Metric *MetricTarget `json:"metric,omitempty"`
+}
+
+// MetricTarget Pod指标的目标平均值, 指标需由custom metrics API(如prometheus-adapter)提供
+type MetricTarget struct {
+	Name         string   `json:"name"`          // 如 "upf_throughput_mbps"
+	AverageValue Quantity `json:"average_value"` // 如 "800"
+}
+
+func (a *AutoscalingSpec) Validate() error {
+	if a.MinReplicas < 1 {
+		return fmt.Errorf("最小副本数不能小于1")
+	}
+	if a.MaxReplicas < a.MinReplicas {
+		return fmt.Errorf("最大副本数不能小于最小副本数")
+	}
+	if a.TargetCPUUtilization == 0 && a.Metric == nil {
+		return fmt.Errorf("需指定目标CPU利用率或自定义指标")
+	}
+	if a.TargetCPUUtilization < 0 || a.TargetCPUUtilization > 100 {
+		return fmt.Errorf("目标CPU利用率应在1-100之间")
+	}
+	if a.Metric != nil {
+		if a.Metric.Name == "" {
+			return fmt.Errorf("自定义指标缺少名称")
+		}
+		if _, err := a.Metric.AverageValue.Quantity(); err != nil {
+			return fmt.Errorf("自定义指标目标值错误: %w", err)
+		}
+	}
+	return nil
+}
+
+// HPASpec 生成以kind/name为目标的HPA spec
+func (a AutoscalingSpec) HPASpec(kind, name string) autoscalingv2.HorizontalPodAutoscalerSpec {
+	minReplicas := a.MinReplicas
+	spec := autoscalingv2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: kind, Name: name},
+		MinReplicas:    &minReplicas,
+		MaxReplicas:    a.MaxReplicas,
+	}
+	if a.TargetCPUUtilization > 0 {
+		utilization := a.TargetCPUUtilization
+		spec.Metrics = append(spec.Metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name:   corev1.ResourceCPU,
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &utilization},
+			},
+		})
+	}
+	if a.Metric != nil {
+		value, _ := a.Metric.AverageValue.Quantity() // 已在Validate中校验
+		spec.Metrics = append(spec.Metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.PodsMetricSourceType,
+			Pods: &autoscalingv2.PodsMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: a.Metric.Name},
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: &value},
+			},
+		})
+	}
+	return spec
+}
+
+// ReplicaSlots 需要预留地址的副本数, 即副本数或HPA的最大副本数, 至少为1
+func (n NFPlay) ReplicaSlots() int {
+	switch {
+	case n.Autoscaling != nil:
+		return int(n.Autoscaling.MaxReplicas)
+	case n.Replicas > 0:
+		return int(n.Replicas)
+	default:
+		return 1
+	}
+}
+
+// KeepFixed 策略只能调整资源和带宽, 其余参数沿用from中的设置
+// 策略(尤其是AI策略)可能返回不完整的Play, 未返回的放行规则、网络策略、副本数等不能因此被删除;
+// 资源或带宽为空或已由运维固定(OperatorOwned)时同样沿用from中的设置
+func (p *Play) KeepFixed(from Play) {
+	if from.Owns(OwnedResources) {
+		p.Resources = ResourceSpec{}
+	}
+	if from.Owns(OwnedBandwidth) {
+		p.Bandwidth = BandwidthSpec{}
+	}
+	next := from
+	next.Resources = keepResources(p.Resources, from.Resources)
+	next.Bandwidth = keepBandwidth(p.Bandwidth, from.Bandwidth)
+	next.NFs = nil
+	if len(from.NFs) > 0 || len(p.NFs) > 0 {
+		next.NFs = make(map[string]NFPlay, len(from.NFs)+len(p.NFs))
+	}
+	for nf, section := range from.NFs {
+		next.NFs[nf] = section
+	}
+	for nf, section := range p.NFs {
+		if from.Owns(OwnedResources) {
+			section.Resources = ResourceSpec{}
+		}
+		if from.Owns(OwnedBandwidth) {
+			section.Bandwidth = BandwidthSpec{}
+		}
+		prev := next.NFs[nf]
+		prev.Resources = keepResources(section.Resources, prev.Resources)
+		prev.Bandwidth = keepBandwidth(section.Bandwidth, prev.Bandwidth)
+		next.NFs[nf] = prev
+	}
+	*p = next
+}
+
+func keepResources(r, prev ResourceSpec) ResourceSpec {
+	if r == (ResourceSpec{}) {
+		return prev
+	}
+	return r
+}
+
+func keepBandwidth(b, prev BandwidthSpec) BandwidthSpec {
+	if b == (BandwidthSpec{}) {
+		return prev
+	}
+	return b
+}
+
+// UPFReplicaSlots UPF需要预留地址的副本数
+func (p *Play) UPFReplicaSlots() int {
+	return p.Sections()[UPFNF].ReplicaSlots()
+}
+
+// UPFNF 顶层参数作用的NF
+const UPFNF = "upf"
+
+// NF名称需能拼接为Deployment名称
+var nfNamePattern = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// ContainerNames 返回匹配容器时依次尝试的名称
+func (n NFPlay) ContainerNames(nf, sliceID string) []string {
+	if n.Container != "" {
+		return []string{n.Container}
+	}
+	return []string{nf, nf + sliceID}
+}
+
+// DeploymentName NF在切片中的Deployment名称
+func DeploymentName(nf, sliceID string) string {
+	return fmt.Sprintf("open5gs-%s%s", nf, sliceID)
+}
+
+// Sections 返回各NF的参数, UPF由顶层参数与nfs中的upf合并而来
+func (p *Play) Sections() map[string]NFPlay {
+	sections := make(map[string]NFPlay, len(p.NFs)+1)
+	for nf, section := range p.NFs {
+		sections[nf] = section
+	}
+	upf := NFPlay{
+		Resources:   p.Resources,
+		Bandwidth:   p.Bandwidth,
+		Priority:    p.Priority,
+		Scheduling:  p.Scheduling,
+		Annotations: p.Annotations,
+	}
+	if override, ok := p.NFs[UPFNF]; ok {
+		upf.Container = override.Container
+		if override.Resources != (ResourceSpec{}) {
+			upf.Resources = override.Resources
+		}
+		if override.Bandwidth != (BandwidthSpec{}) {
+			upf.Bandwidth = override.Bandwidth
+		}
+		if override.Priority != 0 {
+			upf.Priority = override.Priority
+		}
+		if !override.Scheduling.isEmpty() {
+			upf.Scheduling = override.Scheduling
+		}
+		upf.Replicas = override.Replicas
+		upf.Autoscaling = override.Autoscaling
+		if len(override.Annotations) > 0 {
+			merged := make(map[string]string, len(upf.Annotations)+len(override.Annotations))
+			for k, v := range upf.Annotations {
+				merged[k] = v
+			}
+			for k, v := range override.Annotations {
+				merged[k] = v
+			}
+			upf.Annotations = merged
+		}
+	}
+	sections[UPFNF] = upf
+	return sections
+}
+
+// SectionNames 按名称排序的NF列表, 保证应用顺序稳定
+func (p *Play) SectionNames() []string {
+	sections := p.Sections()
+	names := make([]string, 0, len(sections))
+	for nf := range sections {
+		names = append(names, nf)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate 校验已设置的部分
+func (n *NFPlay) Validate() error {
+	if n.Resources != (ResourceSpec{}) {
+		if err := n.Resources.Validate(); err != nil {
+			return fmt.Errorf("资源参数错误: %v", err)
+		}
+	}
+	if n.Bandwidth != (BandwidthSpec{}) {
+		if err := n.Bandwidth.Validate(); err != nil {
+			return fmt.Errorf("带宽参数错误: %v", err)
+		}
+	}
+	if err := n.Priority.Validate(); err != nil {
+		return fmt.Errorf("优先级参数错误: %v", err)
+	}
+	if err := n.Scheduling.validate(); err != nil {
+		return fmt.Errorf("调度参数错误: %v", err)
+	}
+	if n.Replicas < 0 {
+		return fmt.Errorf("副本数不能小于0")
+	}
+	if n.Autoscaling != nil {
+		if n.Replicas > 0 {
+			return fmt.Errorf("不能同时指定副本数和autoscaling")
+		}
+		if err := n.Autoscaling.Validate(); err != nil {
+			return fmt.Errorf("autoscaling参数错误: %v", err)
+		}
+	}
+	return nil
+}
+
+// PlayReport 一次Play应用的结果
+// Play的各步骤作为一个事务执行, 任一步骤失败时已执行的步骤会被恢复
+type PlayReport struct {
+	Applied      []string `json:"applied"`                 // 已执行的变更, 如 "更新 Deployment/open5gs-upf1-000001"
+	Failed       string   `json:"failed,omitempty"`        // 失败的步骤及原因
+	Reverted     []string `json:"reverted,omitempty"`      // 失败后已恢复的变更
+	RevertErrors []string `json:"revert_errors,omitempty"` // 恢复失败的变更, 需人工处理
+	Notes        []string `json:"notes,omitempty"`         // 需要注意的情况, 如带宽限制方式的回退、首次启用tc时Pod重建
 }
 
 // 用于更新play的参数
@@ -48,14 +355,32 @@ func (p *Play) Update(newPlay Play) error {
 	if newPlay.Bandwidth != (BandwidthSpec{}) {
 		p.Bandwidth = newPlay.Bandwidth
 	}
+	if newPlay.BandwidthEnforcer != "" {
+		p.BandwidthEnforcer = newPlay.BandwidthEnforcer
+	}
 	// 3. 调度规则
-	if newPlay.Scheduling.SchedulerName != "" || newPlay.Scheduling.NodeName != "" || len(newPlay.Scheduling.NodeSelector) > 0 {
+	if !newPlay.Scheduling.isEmpty() {
 		p.Scheduling = newPlay.Scheduling
 	}
 	// 4. 网络策略
 	if !isNetworkPolicyEmpty(newPlay.NetworkPolicy) {
 		p.NetworkPolicy = newPlay.NetworkPolicy
 	}
+	// 5. 放行规则, 整体替换
+	if newPlay.AllowRules != nil {
+		p.AllowRules = newPlay.AllowRules
+	}
+	// 6. 各NF的参数, 按NF整体替换
+	if len(newPlay.NFs) > 0 && p.NFs == nil {
+		p.NFs = make(map[string]NFPlay, len(newPlay.NFs))
+	}
+	for nf, section := range newPlay.NFs {
+		p.NFs[nf] = section
+	}
+	// 7. 运维固定的参数, 整体替换
+	if newPlay.OperatorOwned != nil {
+		p.OperatorOwned = newPlay.OperatorOwned
+	}
 
 	return nil
 }
@@ -85,12 +410,45 @@ func (p *Play) Validate() error {
 	if err := p.Bandwidth.Validate(); err != nil {
 		return fmt.Errorf("带宽参数错误: %v", err)
 	}
+	switch p.BandwidthEnforcer {
+	case "", EnforcerAnnotation, EnforcerTC:
+	default:
+		return fmt.Errorf("不支持的带宽限制方式 %q", p.BandwidthEnforcer)
+	}
 	if err := p.Priority.Validate(); err != nil {
 		return fmt.Errorf("优先级参数错误: %v", err)
 	}
 	if err := p.Scheduling.Validate(); err != nil {
 		return fmt.Errorf("调度参数错误: %v", err)
 	}
+	for nf, section := range p.NFs {
+		if !nfNamePattern.MatchString(nf) {
+			return fmt.Errorf("非法的NF名称 %q", nf)
+		}
+		if err := section.Validate(); err != nil {
+			return fmt.Errorf("%s: %v", nf, err)
+		}
+		if slots := section.ReplicaSlots(); nf != UPFNF && slots > 1 {
+			return fmt.Errorf("%s: 目前只有UPF支持多副本", nf)
+		} else if slots > MaxUPFReplicas {
+			return fmt.Errorf("%s: 副本数不能大于%d", nf, MaxUPFReplicas)
+		}
+	}
+	for _, field := range p.OperatorOwned {
+		if field != OwnedResources && field != OwnedBandwidth {
+			return fmt.Errorf("不支持固定的参数 %q", field)
+		}
+	}
+	names := make(map[string]bool, len(p.AllowRules))
+	for _, rule := range p.AllowRules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("放行规则错误: %v", err)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("放行规则 %s 重复", rule.Name)
+		}
+		names[rule.Name] = true
+	}
 	return nil
 }
 
@@ -113,12 +471,13 @@ func (p Priority) ClassName(sliceID string) string {
 
 // 资源定义（CPU / 内存）
 type ResourceSpec struct {
-	CPURequest    string `json:"cpu_request"`    // "500m"
-	CPULimit      string `json:"cpu_limit"`      // "1"
-	MemoryRequest string `json:"memory_request"` // "512Mi"
-	MemoryLimit   string `json:"memory_limit"`   // "1Gi"
+	CPURequest    Quantity `json:"cpu_request"`    // "500m"
+	CPULimit      Quantity `json:"cpu_limit"`      // "1"
+	MemoryRequest Quantity `json:"memory_request"` // "512Mi"
+	MemoryLimit   Quantity `json:"memory_limit"`   // "1Gi"
 }
 
+// Validate 校验数值格式, 且请求不大于限制
 func (r *ResourceSpec) Validate() error {
 	if r.CPURequest == "" || r.CPULimit == "" {
 		return fmt.Errorf("CPU请求和限制不能为空")
@@ -126,35 +485,44 @@ func (r *ResourceSpec) Validate() error {
 	if r.MemoryRequest == "" || r.MemoryLimit == "" {
 		return fmt.Errorf("内存请求和限制不能为空")
 	}
+	pairs := []struct {
+		name           string
+		request, limit Quantity
+	}{
+		{"CPU", r.CPURequest, r.CPULimit},
+		{"内存", r.MemoryRequest, r.MemoryLimit},
+	}
+	for _, pair := range pairs {
+		request, err := pair.request.Quantity()
+		if err != nil {
+			return fmt.Errorf("%s请求格式错误：%w", pair.name, err)
+		}
+		limit, err := pair.limit.Quantity()
+		if err != nil {
+			return fmt.Errorf("%s限制格式错误：%w", pair.name, err)
+		}
+		if request.Cmp(limit) > 0 {
+			return fmt.Errorf("%s请求不能大于限制", pair.name)
+		}
+	}
 	return nil
 }
 
 // 带宽配置
 type BandwidthSpec struct {
-	Ingress string `json:"ingress"` // 例如 "100Mbps"
-	Egress  string `json:"egress"`  // 例如 "200Mbps"
+	Ingress Bandwidth `json:"ingress"` // 例如 "100M", 也接受 "100Mbps"
+	Egress  Bandwidth `json:"egress"`  // 例如 "200M"
 }
 
 func (b *BandwidthSpec) Validate() error {
 	if b.Ingress == "" || b.Egress == "" {
 		return fmt.Errorf("带宽限制不能为空")
 	}
-	return nil
-}
-
-// 调度器配置
-type SchedulingSpec struct {
-	SchedulerName string            `json:"scheduler_name"` // 自定义调度器名称，默认 "default-scheduler"
-	NodeName      string            `json:"node_name"`      // 若指定，Pod 将直接运行在此节点
-	NodeSelector  map[string]string `json:"node_selector"`  // 节点标签选择器
-}
-
-func (s *SchedulingSpec) Validate() error {
-	if s.SchedulerName == "" {
-		s.SchedulerName = "default-scheduler"
+	if _, err := b.Ingress.Normalize(); err != nil {
+		return fmt.Errorf("ingress: %w", err)
 	}
-	if s.NodeName != "" && len(s.NodeSelector) > 0 {
-		return fmt.Errorf("不能同时指定 NodeName 和 NodeSelector")
+	if _, err := b.Egress.Normalize(); err != nil {
+		return fmt.Errorf("egress: %w", err)
 	}
 	return nil
 }
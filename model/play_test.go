@@ -0,0 +1,123 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPlaySections(t *testing.T) {
+	play := Play{
+		SliceID:   "1-000001",
+		Resources: ResourceSpec{CPURequest: "500m", CPULimit: "1", MemoryRequest: "512Mi", MemoryLimit: "1Gi"},
+		Bandwidth: BandwidthSpec{Ingress: "100M", Egress: "100M"},
+		Priority:  100,
+		NFs: map[string]NFPlay{
+			"upf": {Bandwidth: BandwidthSpec{Ingress: "1G", Egress: "1G"}, Autoscaling: &AutoscalingSpec{MinReplicas: 1, MaxReplicas: 4, TargetCPUUtilization: 70}},
+			"smf": {Priority: 200},
+		},
+	}
+	require.NoError(t, play.Validate())
+	assert.Equal(t, []string{"smf", "upf"}, play.SectionNames())
+
+	// nfs中的upf只覆盖非空的部分
+	upf := play.Sections()[UPFNF]
+	assert.Equal(t, play.Resources, upf.Resources)
+	assert.Equal(t, Bandwidth("1G"), upf.Bandwidth.Ingress)
+	assert.Equal(t, Priority(100), upf.Priority)
+	assert.Equal(t, 4, play.UPFReplicaSlots())
+
+	// 策略的输出只保留资源和带宽, 其余沿用当前设置
+	next := Play{NFs: map[string]NFPlay{"upf": {Replicas: 8, Bandwidth: BandwidthSpec{Ingress: "2G", Egress: "2G"}}}}
+	next.KeepFixed(play)
+	assert.Equal(t, 4, next.UPFReplicaSlots())
+	assert.Equal(t, "1-000001", next.SliceID)
+	assert.Equal(t, play.Resources, next.Resources)
+	assert.Equal(t, Bandwidth("2G"), next.Sections()[UPFNF].Bandwidth.Ingress)
+	assert.Equal(t, Priority(200), next.NFs["smf"].Priority)
+
+	// 运维固定的带宽不被策略修改, 资源仍由策略调整
+	play.OperatorOwned = []string{OwnedBandwidth}
+	require.NoError(t, play.Validate())
+	next = Play{
+		Resources: ResourceSpec{CPURequest: "1", CPULimit: "2", MemoryRequest: "1Gi", MemoryLimit: "2Gi"},
+		Bandwidth: BandwidthSpec{Ingress: "10M", Egress: "10M"},
+		NFs:       map[string]NFPlay{"upf": {Bandwidth: BandwidthSpec{Ingress: "2G", Egress: "2G"}}},
+	}
+	next.KeepFixed(play)
+	assert.Equal(t, Quantity("1"), next.Resources.CPURequest)
+	assert.Equal(t, play.Bandwidth, next.Bandwidth)
+	assert.Equal(t, Bandwidth("1G"), next.Sections()[UPFNF].Bandwidth.Ingress)
+	assert.Equal(t, []string{OwnedBandwidth}, next.OperatorOwned)
+	play.OperatorOwned = []string{"priority"}
+	assert.Error(t, play.Validate())
+	play.OperatorOwned = nil
+
+	// 只有UPF支持多副本
+	play.NFs["smf"] = NFPlay{Replicas: 2}
+	assert.Error(t, play.Validate())
+	play.NFs["smf"] = NFPlay{}
+	play.NFs["upf"] = NFPlay{Replicas: MaxUPFReplicas + 1}
+	assert.Error(t, play.Validate())
+	play.NFs["Bad_NF"] = NFPlay{}
+	delete(play.NFs, "upf")
+	assert.Error(t, play.Validate())
+}
+
+func TestSchedulingValidate(t *testing.T) {
+	scheduling := SchedulingSpec{
+		Affinity: &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "node-role/edge", Operator: corev1.NodeSelectorOpExists}},
+					}},
+				},
+			},
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+					Weight: 50,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						TopologyKey:   "kubernetes.io/hostname",
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"nf": "upf"}},
+					},
+				}},
+			},
+		},
+		Tolerations: []corev1.Toleration{{Key: "edge", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}},
+		TopologySpreadConstraints: []corev1.TopologySpreadConstraint{{
+			MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: corev1.DoNotSchedule,
+		}},
+		RuntimeClassName: "kata",
+	}
+	require.NoError(t, scheduling.Validate())
+	assert.Equal(t, "default-scheduler", scheduling.SchedulerName)
+
+	bad := scheduling
+	bad.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{{
+			Weight:     0,
+			Preference: corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "cpu", Operator: corev1.NodeSelectorOpGt, Values: []string{"many"}}}},
+		}},
+	}}
+	assert.Error(t, bad.Validate())
+
+	bad = scheduling
+	bad.Tolerations = []corev1.Toleration{{Key: "edge", Operator: corev1.TolerationOpExists, Value: "true"}}
+	assert.Error(t, bad.Validate())
+
+	bad = scheduling
+	bad.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{MaxSkew: 0, TopologyKey: "zone", WhenUnsatisfiable: corev1.ScheduleAnyway}}
+	assert.Error(t, bad.Validate())
+
+	bad = scheduling
+	bad.RuntimeClassName = "Kata_Runtime"
+	assert.Error(t, bad.Validate())
+
+	// nfs中的调度参数同样校验
+	play := Play{SliceID: "1-000001", NFs: map[string]NFPlay{"smf": {Scheduling: bad}}}
+	assert.Error(t, play.Validate())
+}
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Quantity CPU/内存数值, 格式同Kubernetes, 如 "500m", "1", "512Mi"
+// 从JSON解码时严格校验并规范化(如 "0.5" -> "500m", "1024Mi" -> "1Gi"), 空字符串表示未设置
+type Quantity string
+
+// ParseQuantity 解析并规范化数值
+func ParseQuantity(s string) (Quantity, error) {
+	q, err := resource.ParseQuantity(strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("非法的数值 %q", s)
+	}
+	if q.Sign() < 0 {
+		return "", fmt.Errorf("数值不能为负数: %q", s)
+	}
+	return Quantity(q.String()), nil
+}
+
+// Quantity 转换为resource.Quantity
+func (q Quantity) Quantity() (resource.Quantity, error) {
+	if q == "" {
+		return resource.Quantity{}, fmt.Errorf("数值为空")
+	}
+	v, err := resource.ParseQuantity(string(q))
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("非法的数值 %q", string(q))
+	}
+	return v, nil
+}
+
+func (q Quantity) String() string {
+	return string(q)
+}
+
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("数值应为字符串: %w", err)
+	}
+	if s == "" {
+		*q = ""
+		return nil
+	}
+	v, err := ParseQuantity(s)
+	if err != nil {
+		return err
+	}
+	*q = v
+	return nil
+}
+
+// Bandwidth 带宽, 规范化后的格式为CNI bandwidth插件使用的 "<整数><K|M|G|T>" (单位bit/s), 如 "100M"
+// 解码时接受 "100M", "100Mbps", "100 Mbit/s", "1.5G", "800Kbps" 等写法, 单位为十进制, 大小写不敏感
+type Bandwidth string
+
+// 带宽单位, 十进制
+var bandwidthUnits = []struct {
+	suffix string
+	bits   float64
+}{
+	{"T", 1e12},
+	{"G", 1e9},
+	{"M", 1e6},
+	{"K", 1e3},
+}
+
+// ParseBandwidth 解析并规范化带宽, 必须带单位
+func ParseBandwidth(s string) (Bandwidth, error) {
+	bps, err := parseBitsPerSecond(s)
+	if err != nil {
+		return "", err
+	}
+	return bandwidthFromBits(bps), nil
+}
+
+// BandwidthFromMbps 由Mbps数值生成带宽, 向上取整到整数Mbps
+func BandwidthFromMbps(mbps float64) Bandwidth {
+	return bandwidthFromBits(math.Max(math.Ceil(mbps), 1) * 1e6)
+}
+
+func parseBitsPerSecond(s string) (float64, error) {
+	raw := strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	// 大写B表示字节, 容易与bit混淆, 直接拒绝
+	for _, suffix := range []string{"B", "Bps", "B/s"} {
+		if strings.HasSuffix(raw, suffix) {
+			return 0, fmt.Errorf("非法的带宽 %q, 单位为bit/s, 不支持字节", s)
+		}
+	}
+	str := strings.ToUpper(raw)
+	// 去掉bit/s的各种写法, 只保留数量级
+	for _, suffix := range []string{"BIT/S", "BITS/S", "B/S", "BPS", "BIT", "B"} {
+		if strings.HasSuffix(str, suffix) {
+			str = strings.TrimSuffix(str, suffix)
+			break
+		}
+	}
+	for _, unit := range bandwidthUnits {
+		if !strings.HasSuffix(str, unit.suffix) {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSuffix(str, unit.suffix), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, fmt.Errorf("非法的带宽 %q", s)
+		}
+		if v <= 0 {
+			return 0, fmt.Errorf("带宽必须大于0: %q", s)
+		}
+		return math.Round(v * unit.bits), nil
+	}
+	return 0, fmt.Errorf("非法的带宽 %q, 需带单位K/M/G/T(bit/s), 如100M或100Mbps", s)
+}
+
+// bandwidthFromBits 选择能整除的最大单位
+func bandwidthFromBits(bps float64) Bandwidth {
+	for _, unit := range bandwidthUnits {
+		if v := bps / unit.bits; v >= 1 && v == math.Trunc(v) {
+			return Bandwidth(fmt.Sprintf("%.0f%s", v, unit.suffix))
+		}
+	}
+	return Bandwidth(fmt.Sprintf("%.0fK", math.Ceil(bps/1e3)))
+}
+
+// Mbps 带宽的Mbps数值, 未设置或非法时为0
+// 存储中的旧数据未经规范化, 因此这里同样按宽松格式解析
+func (b Bandwidth) Mbps() float64 {
+	bps, err := parseBitsPerSecond(string(b))
+	if err != nil {
+		return 0
+	}
+	return bps / 1e6
+}
+
+// Normalize 返回规范化的带宽, 用于写入CNI注解
+func (b Bandwidth) Normalize() (Bandwidth, error) {
+	return ParseBandwidth(string(b))
+}
+
+func (b Bandwidth) String() string {
+	return string(b)
+}
+
+func (b *Bandwidth) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("带宽应为字符串: %w", err)
+	}
+	if s == "" {
+		*b = ""
+		return nil
+	}
+	v, err := ParseBandwidth(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
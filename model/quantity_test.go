@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	valid := map[string]Bandwidth{
+		"100M":       "100M",
+		"120m":       "120M",
+		"100Mbps":    "100M",
+		"100 Mbit/s": "100M",
+		"1.5G":       "1500M",
+		"1000Mbps":   "1G",
+		"800Kbps":    "800K",
+		"100mb":      "100M",
+	}
+	for in, want := range valid {
+		got, err := ParseBandwidth(in)
+		if assert.NoError(t, err, in) {
+			assert.Equal(t, want, got, in)
+		}
+	}
+	for _, in := range []string{"", "100", "100MB", "100MBps", "fast", "-1M", "0M"} {
+		_, err := ParseBandwidth(in)
+		assert.Error(t, err, in)
+	}
+
+	assert.Equal(t, 1500.0, Bandwidth("1.5Gbps").Mbps())
+	assert.Equal(t, Bandwidth("121M"), BandwidthFromMbps(120.2))
+}
+
+func TestPlayDecodeNormalizes(t *testing.T) {
+	var play Play
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"slice_id": "1-000001",
+		"resources": {"cpu_request": "0.5", "cpu_limit": "1000m", "memory_request": "1024Mi", "memory_limit": "2Gi"},
+		"bandwidth": {"ingress": "100Mbps", "egress": "1Gbps"}
+	}`), &play))
+	assert.Equal(t, ResourceSpec{CPURequest: "500m", CPULimit: "1", MemoryRequest: "1Gi", MemoryLimit: "2Gi"}, play.Resources)
+	assert.Equal(t, BandwidthSpec{Ingress: "100M", Egress: "1G"}, play.Bandwidth)
+	require.NoError(t, play.Validate())
+
+	// 非法数值在解码时即被拒绝, 不会到达kubeclient
+	assert.Error(t, json.Unmarshal([]byte(`{"resources": {"cpu_request": "lots"}}`), &play))
+	assert.Error(t, json.Unmarshal([]byte(`{"bandwidth": {"ingress": "100"}}`), &play))
+
+	// 请求大于限制
+	play.Resources.CPURequest = "2"
+	assert.Error(t, play.Validate())
+}
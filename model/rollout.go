@@ -0,0 +1,33 @@
+package model
+
+// RolloutStatus 切片Deployment的滚动更新状态
+type RolloutStatus struct {
+	Ready       bool               `json:"ready" yaml:"ready"`
+	Reason      string             `json:"reason,omitempty" yaml:"reason,omitempty"` // 未就绪的原因
+	Deployments []DeploymentStatus `json:"deployments" yaml:"deployments"`
+	Failures    []ContainerFailure `json:"failures,omitempty" yaml:"failures,omitempty"`
+}
+
+// DeploymentStatus 单个Deployment(或多副本UPF的StatefulSet)的滚动更新进度
+type DeploymentStatus struct {
+	Kind      string `json:"kind,omitempty" yaml:"kind,omitempty"` // 为空表示Deployment
+	Name      string `json:"name" yaml:"name"`
+	Replicas  int32  `json:"replicas" yaml:"replicas"` // 期望的副本数
+	Updated   int32  `json:"updated" yaml:"updated"`
+	Ready     int32  `json:"ready" yaml:"ready"`
+	Available int32  `json:"available" yaml:"available"`
+	Complete  bool   `json:"complete" yaml:"complete"`
+	Message   string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// ContainerFailure 反复崩溃或无法启动的容器
+type ContainerFailure struct {
+	Pod          string `json:"pod" yaml:"pod"`
+	Container    string `json:"container" yaml:"container"`
+	Reason       string `json:"reason" yaml:"reason"`                               // 当前等待原因, 如CrashLoopBackOff
+	LastReason   string `json:"last_reason,omitempty" yaml:"last_reason,omitempty"` // 上次退出的原因, 如OOMKilled/Error
+	ExitCode     int32  `json:"exit_code,omitempty" yaml:"exit_code,omitempty"`
+	RestartCount int32  `json:"restart_count" yaml:"restart_count"`
+	Message      string `json:"message,omitempty" yaml:"message,omitempty"`
+	LogTail      string `json:"log_tail,omitempty" yaml:"log_tail,omitempty"` // 上次运行的日志末尾
+}
@@ -0,0 +1,215 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// 调度器配置
+// Affinity/Tolerations/TopologySpreadConstraints/RuntimeClassName设置后整体替换Pod中的对应字段, NodeSelector与原有标签合并
+type SchedulingSpec struct {
+	SchedulerName string            `json:"scheduler_name"` // 自定义调度器名称，默认 "default-scheduler"
+	NodeName      string            `json:"node_name"`      // 若指定，Pod 将直接运行在此节点
+	NodeSelector  map[string]string `json:"node_selector"`  // 节点标签选择器
+
+	// 节点亲和性及Pod亲和/反亲和性, 如将UPF固定到边缘节点, 或使副本分布在不同节点
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// 容忍的污点, 如边缘节点上的专用污点
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// 拓扑分布约束, 如跨可用区分布以满足可用性SLA
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topology_spread_constraints,omitempty"`
+	// 容器运行时, 如 "kata"
+	RuntimeClassName string `json:"runtime_class_name,omitempty"`
+}
+
+func (s SchedulingSpec) isEmpty() bool {
+	return s.SchedulerName == "" && s.NodeName == "" && len(s.NodeSelector) == 0 &&
+		s.Affinity == nil && len(s.Tolerations) == 0 && len(s.TopologySpreadConstraints) == 0 && s.RuntimeClassName == ""
+}
+
+func (s *SchedulingSpec) Validate() error {
+	if s.SchedulerName == "" {
+		s.SchedulerName = "default-scheduler"
+	}
+	return s.validate()
+}
+
+// validate 校验各字段, 不填充默认值
+func (s SchedulingSpec) validate() error {
+	if s.NodeName != "" && len(s.NodeSelector) > 0 {
+		return fmt.Errorf("不能同时指定 NodeName 和 NodeSelector")
+	}
+	var errs []error
+	if s.RuntimeClassName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(s.RuntimeClassName) {
+			errs = append(errs, fmt.Errorf("runtime_class_name: %s", msg))
+		}
+	}
+	if s.Affinity != nil {
+		errs = append(errs, validateAffinity(s.Affinity)...)
+	}
+	for i, t := range s.Tolerations {
+		if err := validateToleration(t); err != nil {
+			errs = append(errs, fmt.Errorf("tolerations[%d]: %w", i, err))
+		}
+	}
+	for i, c := range s.TopologySpreadConstraints {
+		if err := validateTopologySpread(c); err != nil {
+			errs = append(errs, fmt.Errorf("topology_spread_constraints[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateAffinity(a *corev1.Affinity) (errs []error) {
+	if na := a.NodeAffinity; na != nil {
+		if required := na.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+			if len(required.NodeSelectorTerms) == 0 {
+				errs = append(errs, fmt.Errorf("nodeAffinity: required中至少需要一个nodeSelectorTerm"))
+			}
+			for i, term := range required.NodeSelectorTerms {
+				if err := validateNodeSelectorTerm(term); err != nil {
+					errs = append(errs, fmt.Errorf("nodeAffinity.required[%d]: %w", i, err))
+				}
+			}
+		}
+		for i, term := range na.PreferredDuringSchedulingIgnoredDuringExecution {
+			if err := validateWeight(term.Weight); err != nil {
+				errs = append(errs, fmt.Errorf("nodeAffinity.preferred[%d]: %w", i, err))
+			}
+			if err := validateNodeSelectorTerm(term.Preference); err != nil {
+				errs = append(errs, fmt.Errorf("nodeAffinity.preferred[%d]: %w", i, err))
+			}
+		}
+	}
+
+	podTerms := func(name string, required []corev1.PodAffinityTerm, preferred []corev1.WeightedPodAffinityTerm) {
+		for i, term := range required {
+			if err := validatePodAffinityTerm(term); err != nil {
+				errs = append(errs, fmt.Errorf("%s.required[%d]: %w", name, i, err))
+			}
+		}
+		for i, term := range preferred {
+			if err := validateWeight(term.Weight); err != nil {
+				errs = append(errs, fmt.Errorf("%s.preferred[%d]: %w", name, i, err))
+			}
+			if err := validatePodAffinityTerm(term.PodAffinityTerm); err != nil {
+				errs = append(errs, fmt.Errorf("%s.preferred[%d]: %w", name, i, err))
+			}
+		}
+	}
+	if pa := a.PodAffinity; pa != nil {
+		podTerms("podAffinity", pa.RequiredDuringSchedulingIgnoredDuringExecution, pa.PreferredDuringSchedulingIgnoredDuringExecution)
+	}
+	if paa := a.PodAntiAffinity; paa != nil {
+		podTerms("podAntiAffinity", paa.RequiredDuringSchedulingIgnoredDuringExecution, paa.PreferredDuringSchedulingIgnoredDuringExecution)
+	}
+	return errs
+}
+
+func validateWeight(weight int32) error {
+	if weight < 1 || weight > 100 {
+		return fmt.Errorf("weight应在1-100之间")
+	}
+	return nil
+}
+
+func validateNodeSelectorTerm(term corev1.NodeSelectorTerm) error {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return fmt.Errorf("matchExpressions和matchFields不能都为空")
+	}
+	for _, req := range append(term.MatchExpressions, term.MatchFields...) {
+		if req.Key == "" {
+			return fmt.Errorf("缺少key")
+		}
+		switch req.Operator {
+		case corev1.NodeSelectorOpIn, corev1.NodeSelectorOpNotIn:
+			if len(req.Values) == 0 {
+				return fmt.Errorf("%s: 运算符%s需要values", req.Key, req.Operator)
+			}
+		case corev1.NodeSelectorOpExists, corev1.NodeSelectorOpDoesNotExist:
+			if len(req.Values) > 0 {
+				return fmt.Errorf("%s: 运算符%s不能指定values", req.Key, req.Operator)
+			}
+		case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+			if len(req.Values) != 1 {
+				return fmt.Errorf("%s: 运算符%s需要一个整数值", req.Key, req.Operator)
+			}
+			if _, err := strconv.ParseInt(req.Values[0], 10, 64); err != nil {
+				return fmt.Errorf("%s: 运算符%s需要一个整数值", req.Key, req.Operator)
+			}
+		default:
+			return fmt.Errorf("%s: 不支持的运算符 %q", req.Key, req.Operator)
+		}
+	}
+	return nil
+}
+
+func validatePodAffinityTerm(term corev1.PodAffinityTerm) error {
+	if term.TopologyKey == "" {
+		return fmt.Errorf("缺少topologyKey")
+	}
+	return validateLabelSelector(term.LabelSelector)
+}
+
+func validateLabelSelector(selector *metav1.LabelSelector) error {
+	if selector == nil {
+		return nil
+	}
+	if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+		return fmt.Errorf("labelSelector不合法: %w", err)
+	}
+	return nil
+}
+
+func validateToleration(t corev1.Toleration) error {
+	switch t.Operator {
+	case corev1.TolerationOpExists:
+		if t.Value != "" {
+			return fmt.Errorf("运算符Exists不能指定value")
+		}
+	case corev1.TolerationOpEqual, "":
+		if t.Key == "" {
+			return fmt.Errorf("key为空时运算符必须为Exists")
+		}
+	default:
+		return fmt.Errorf("不支持的运算符 %q", t.Operator)
+	}
+	switch t.Effect {
+	case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, "":
+		if t.TolerationSeconds != nil {
+			return fmt.Errorf("tolerationSeconds只能用于NoExecute")
+		}
+	case corev1.TaintEffectNoExecute:
+	default:
+		return fmt.Errorf("不支持的effect %q", t.Effect)
+	}
+	return nil
+}
+
+func validateTopologySpread(c corev1.TopologySpreadConstraint) error {
+	if c.MaxSkew < 1 {
+		return fmt.Errorf("maxSkew必须大于0")
+	}
+	if c.TopologyKey == "" {
+		return fmt.Errorf("缺少topologyKey")
+	}
+	switch c.WhenUnsatisfiable {
+	case corev1.DoNotSchedule:
+	case corev1.ScheduleAnyway:
+		if c.MinDomains != nil {
+			return fmt.Errorf("minDomains只能用于DoNotSchedule")
+		}
+	default:
+		return fmt.Errorf("whenUnsatisfiable应为DoNotSchedule或ScheduleAnyway")
+	}
+	if c.MinDomains != nil && *c.MinDomains < 1 {
+		return fmt.Errorf("minDomains必须大于0")
+	}
+	return validateLabelSelector(c.LabelSelector)
+}
@@ -17,6 +17,8 @@ type Slice struct {
 	SD               string             `json:"sd" yaml:"sd"`
 	DefaultIndicator bool               `json:"default_indicator" yaml:"default_indicator"`
 	Sessions         []Session          `json:"session" yaml:"session"`
+	NF               *NFParams          `json:"nf,omitempty" yaml:"nf,omitempty"`               // 可选, SMF/UPF参数
+	Placement        *Placement         `json:"placement,omitempty" yaml:"placement,omitempty"` // 可选, 部署的集群, 默认为默认集群
 }
 
 type Session struct {
@@ -109,6 +111,20 @@ func (s *Slice) Validate() error {
 		}
 	}
 
+	// 校验网络功能参数
+	if s.NF != nil {
+		if err := s.NF.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("网络功能参数校验失败：%w", err))
+		}
+	}
+
+	// 校验放置规则
+	if s.Placement != nil {
+		if err := s.Placement.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("放置规则校验失败：%w", err))
+		}
+	}
+
 	return errors.Join(errs...)
 }
 
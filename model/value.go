@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"gopkg.in/yaml.v3"
 )
@@ -9,6 +11,25 @@ type SliceAndAddress struct {
 	ID primitive.ObjectID `json:"id" yaml:"id" bson:"_id,omitempty"`
 	Slice
 	AddressValue
+
+	// 切片所在的集群, 创建时由Placement解析得到, 为空表示默认集群
+	Cluster string `json:"cluster,omitempty" yaml:"cluster,omitempty" bson:"cluster,omitempty"`
+
+	// 最近一次交付的状态
+	Status *DeliveryStatus `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// DeliveryStatus 切片资源的交付状态
+type DeliveryStatus struct {
+	Mode      string    `json:"mode" yaml:"mode"`                             // 交付方式: apply或git
+	Revision  string    `json:"revision,omitempty" yaml:"revision,omitempty"` // git模式下为提交SHA
+	Message   string    `json:"message,omitempty" yaml:"message,omitempty"`   // 交付说明, 如git提交信息
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+
+	// apply模式下等待就绪的结果, 未等待时为空
+	Rollout *RolloutStatus `json:"rollout,omitempty" yaml:"rollout,omitempty"`
+	// apply模式下最近一次Play的执行情况
+	Play *PlayReport `json:"play,omitempty" yaml:"play,omitempty"`
 }
 
 // 格式均为x.x.x.x/x
@@ -20,6 +41,21 @@ type AddressValue struct {
 	UPFN4Addr string
 	SMFN3Addr string
 	SMFN4Addr string
+
+	// UPF多副本时第1个及之后副本的地址, 第0个副本使用UPFN3Addr/UPFN4Addr
+	UPFReplicaAddrs []ReplicaAddress `json:",omitempty" yaml:",omitempty" bson:",omitempty"`
+}
+
+// ReplicaAddress 单个UPF副本的N3/N4地址, 格式为x.x.x.x/x
+type ReplicaAddress struct {
+	N3Addr string
+	N4Addr string
+}
+
+// UPFAddrs 按副本序号返回所有UPF副本的地址
+func (a AddressValue) UPFAddrs() []ReplicaAddress {
+	addrs := []ReplicaAddress{{N3Addr: a.UPFN3Addr, N4Addr: a.UPFN4Addr}}
+	return append(addrs, a.UPFReplicaAddrs...)
 }
 
 func (s *SliceAndAddress) ToYAML() ([]byte, error) {
@@ -0,0 +1,6 @@
+apiVersion: v2
+name: slice-{{ .ID }}
+description: Open5GS SMF/UPF for slice {{ .ID }}, generated by slicer
+type: application
+version: 0.1.0
+appVersion: "{{ .AppVersion }}"
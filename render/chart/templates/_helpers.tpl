@@ -0,0 +1,43 @@
+{{/*
+切片资源的公共标签, 参数为 (dict "root" $ "nf" "smf")
+*/}}
+{{- define "slice.labels" -}}
+app: open5gs
+nf: {{ .nf }}
+slice: {{ .root.Values.slice.id | quote }}
+name: {{ printf "%s%s" .nf .root.Values.slice.id | quote }}
+{{- end }}
+
+{{/*
+共享NF服务的地址, 切片独立命名空间时为跨命名空间的全名, 参数为 (dict "root" $ "service" "scp-nscp")
+*/}}
+{{- define "slice.sharedHost" -}}
+{{ .service }}{{ with .root.Values.namespaces.shared }}.{{ . }}.svc{{ end }}
+{{- end }}
+
+{{/*
+切片自身服务的地址, 供共享NF跨命名空间访问, 参数同slice.sharedHost
+*/}}
+{{- define "slice.host" -}}
+{{ .service }}{{ with .root.Values.namespaces.slice }}.{{ . }}.svc{{ end }}
+{{- end }}
+
+{{/*
+Multus注解中NAD所在的命名空间, NAD与共享NF在同一命名空间
+*/}}
+{{- define "slice.nadNamespace" -}}
+{{ with .Values.namespaces.shared }}"namespace": "{{ . }}", {{ end }}
+{{- end }}
+
+{{/*
+NF的nodeSelector, Play中的nodeSelector与NF参数合并, 参数为 (dict "nf" .Values.smf "play" .Values.play.nfs.smf)
+*/}}
+{{- define "slice.nodeSelector" -}}
+{{- $selector := dict }}
+{{- range $k, $v := .nf.nodeSelector }}{{ $_ := set $selector $k $v }}{{ end }}
+{{- range $k, $v := .play.nodeSelector }}{{ $_ := set $selector $k $v }}{{ end }}
+{{- with $selector }}
+nodeSelector:
+  {{- toYaml . | nindent 2 }}
+{{- end }}
+{{- end }}
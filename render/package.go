@@ -0,0 +1,403 @@
+package render
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slicer/model"
+	"slicer/util"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Helm chart模板, 与template目录中的切片模板一一对应, 修改切片模板时需同步修改
+// templates目录中的文件原样拷贝到chart中, 由helm渲染; Chart.yaml.tpl由slicer渲染
+//
+//go:embed all:chart
+var chartFS embed.FS
+
+const (
+	FormatHelm      = "helm"
+	FormatKustomize = "kustomize"
+)
+
+// HelmValues 切片chart的values.yaml, 由SliceAndAddress生成
+type HelmValues struct {
+	Slice        HelmSlice      `yaml:"slice"`
+	Sessions     []HelmSession  `yaml:"sessions"`
+	SMF          HelmNF         `yaml:"smf"`
+	UPF          HelmNF         `yaml:"upf"`
+	UPFReplicas  []HelmReplica  `yaml:"upfReplicas"` // 所有UPF副本的地址, 多于一个时UPF以StatefulSet部署
+	N6           []HelmSession  `yaml:"n6"`          // 配置了N6网络的会话, UPF为每个DNN接入一次
+	Namespaces   HelmNamespaces `yaml:"namespaces"`
+	ClusterCIDRs []string       `yaml:"clusterCIDRs"` // UPF的N6出站规则排除的集群网段
+	Play         HelmPlay       `yaml:"play"`         // Play的覆盖参数, 没有Play时为空
+}
+
+// HelmPlay Play在chart中的覆盖参数, 效果与kustomize overlay中的patch一致
+type HelmPlay struct {
+	NFs     map[string]HelmPlayNF `yaml:"nfs"`     // 按NF合并到工作负载中, smf和upf总是存在
+	Objects []any                 `yaml:"objects"` // 优先级类、HPA和网络策略等额外的资源
+}
+
+// HelmPlayNF 合并到NF工作负载中的参数, 为空的字段保留切片模板中的值
+type HelmPlayNF struct {
+	Replicas     int               `yaml:"replicas,omitempty"`
+	Annotations  map[string]string `yaml:"annotations,omitempty"`
+	Resources    map[string]any    `yaml:"resources,omitempty"`
+	NodeSelector map[string]string `yaml:"nodeSelector,omitempty"` // 与NF参数中的nodeSelector合并
+	Pod          map[string]any    `yaml:"pod,omitempty"`          // 优先级类和调度参数, 原样写入Pod spec
+}
+
+// HelmNamespaces 切片独立命名空间时设置, 为空时与共享NF在同一命名空间
+type HelmNamespaces struct {
+	Shared string `yaml:"shared"` // 共享NF及NAD所在的命名空间
+	Slice  string `yaml:"slice"`  // 切片资源所在的命名空间, 需与helm install -n一致
+}
+
+type HelmReplica struct {
+	N3Addr string `yaml:"n3Addr"`
+	N4Addr string `yaml:"n4Addr"`
+	N4IP   string `yaml:"n4IP"`
+}
+
+type HelmSlice struct {
+	ID  string `yaml:"id"`
+	SST string `yaml:"sst"`
+	SD  string `yaml:"sd"`
+}
+
+type HelmSession struct {
+	DNN         string `yaml:"dnn"`
+	Subnet      string `yaml:"subnet"`
+	Gateway     string `yaml:"gateway"`
+	GatewayCIDR string `yaml:"gatewayCIDR"`
+	N6Network   string `yaml:"n6Network,omitempty"` // 配置了N6网络时UPF接入的NAD, 以及对应的接口和策略路由表
+	N6Interface string `yaml:"n6Interface,omitempty"`
+	N6Table     int    `yaml:"n6Table,omitempty"`
+}
+
+type HelmNF struct {
+	N3Addr       string            `yaml:"n3Addr"`
+	N4Addr       string            `yaml:"n4Addr"`
+	N4IP         string            `yaml:"n4IP,omitempty"` // 不含前缀长度的N4地址, SMF通过它连接UPF
+	Image        string            `yaml:"image"`
+	DNS          []string          `yaml:"dns,omitempty"`
+	MTU          int               `yaml:"mtu"`
+	MaxUE        int               `yaml:"maxUE"`
+	LogLevel     string            `yaml:"logLevel"`
+	LogFile      string            `yaml:"logFile"`
+	NodeSelector map[string]string `yaml:"nodeSelector,omitempty"`
+	Env          map[string]string `yaml:"env,omitempty"`
+	Resources    HelmResources     `yaml:"resources"`
+}
+
+type HelmResources struct {
+	Requests map[string]string `yaml:"requests"`
+	Limits   map[string]string `yaml:"limits"`
+}
+
+// Kustomization kustomization.yaml的最小结构
+type Kustomization struct {
+	APIVersion string              `yaml:"apiVersion"`
+	Kind       string              `yaml:"kind"`
+	Namespace  string              `yaml:"namespace,omitempty"`
+	Resources  []string            `yaml:"resources"`
+	Patches    []KustomizePatchRef `yaml:"patches,omitempty"`
+}
+
+type KustomizePatchRef struct {
+	Path string `yaml:"path"`
+}
+
+// RenderPackage 按格式渲染切片, 返回文件路径到内容的映射, 根目录为slice-<id>
+// play可为nil, helm格式将其写入values, kustomize格式将其作为overlay输出
+func (r *Render) RenderPackage(slice model.SliceAndAddress, play *model.Play, format string) (map[string][]byte, error) {
+	switch format {
+	case FormatHelm:
+		return r.RenderHelmChart(slice, play)
+	case FormatKustomize:
+		return r.RenderKustomize(slice, play)
+	default:
+		return nil, fmt.Errorf("不支持的输出格式: %s", format)
+	}
+}
+
+// RenderHelmChart 生成切片的Helm chart, values.yaml由切片及地址填充, play不为nil时其参数写入values的play中
+// 切片模板被覆盖目录修改时, chart中对应的模板改为渲染出的资源清单(已合并Play), 保证与直接部署的内容一致
+func (r *Render) RenderHelmChart(slice model.SliceAndAddress, play *model.Play) (map[string][]byte, error) {
+	root := packageRoot(slice)
+	files := make(map[string][]byte)
+
+	// Chart.yaml
+	chartTpl, err := template.ParseFS(chartFS, "chart/Chart.yaml.tpl")
+	if err != nil {
+		return nil, fmt.Errorf("解析Chart模板失败: %w", err)
+	}
+	var chart bytes.Buffer
+	err = chartTpl.Execute(&chart, struct{ ID, AppVersion string }{slice.SliceID(), r.Templates().Version})
+	if err != nil {
+		return nil, fmt.Errorf("渲染Chart模板失败: %w", err)
+	}
+	files[path.Join(root, "Chart.yaml")] = chart.Bytes()
+
+	// values.yaml
+	manifests, err := r.RenderSliceFiles(slice)
+	if err != nil {
+		return nil, err
+	}
+	values := helmValues(slice, r.config)
+	if play != nil {
+		playFiles, resources, patches, err := renderPlayOverlay(*play, manifests)
+		if err != nil {
+			return nil, err
+		}
+		if values.Play, err = helmPlay(*play, playFiles, resources); err != nil {
+			return nil, err
+		}
+		if err := applyPlayPatches(manifests, playFiles, patches); err != nil {
+			return nil, err
+		}
+	}
+	content, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("序列化values失败: %w", err)
+	}
+	files[path.Join(root, "values.yaml")] = content
+
+	// templates
+	err = fs.WalkDir(chartFS, "chart/templates", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(chartFS, p)
+		if err != nil {
+			return err
+		}
+		files[path.Join(root, strings.TrimPrefix(p, "chart/"))] = content
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取chart模板失败: %w", err)
+	}
+	for tplFile := range r.modifiedTemplates() {
+		name := strings.TrimSuffix(tplFile, tplSuffix)
+		if content, ok := manifests[name]; ok {
+			files[path.Join(root, "templates", name)] = escapeHelm(content)
+		}
+	}
+	return files, nil
+}
+
+// escapeHelm 转义资源清单中的模板分隔符, 使helm原样输出
+func escapeHelm(content []byte) []byte {
+	return bytes.ReplaceAll(content, []byte("{{"), []byte(`{{ "{{" }}`))
+}
+
+// RenderKustomize 生成切片的Kustomize目录
+// base中为渲染出的资源清单, overlays/<namespace>设置部署的namespace, 并以patch的形式应用Play(可为nil)
+func (r *Render) RenderKustomize(slice model.SliceAndAddress, play *model.Play) (map[string][]byte, error) {
+	root := packageRoot(slice)
+
+	manifests, err := r.RenderSliceFiles(slice)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte, len(manifests)+2)
+	base := Kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+	}
+	for _, tplFile := range sliceTemplates {
+		name := strings.TrimSuffix(tplFile, ".tpl")
+		files[path.Join(root, "base", name)] = manifests[name]
+		base.Resources = append(base.Resources, name)
+	}
+	if files[path.Join(root, "base", "kustomization.yaml")], err = yaml.Marshal(base); err != nil {
+		return nil, fmt.Errorf("序列化kustomization失败: %w", err)
+	}
+
+	overlay := Kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Namespace:  r.config.SliceNamespace(slice.SliceID()),
+		Resources:  []string{"../../base"},
+	}
+	overlayDir := path.Join(root, "overlays", overlay.Namespace)
+	if play != nil {
+		playFiles, resources, patches, err := renderPlayOverlay(*play, manifests)
+		if err != nil {
+			return nil, err
+		}
+		for name, content := range playFiles {
+			files[path.Join(overlayDir, name)] = content
+		}
+		overlay.Resources = append(overlay.Resources, resources...)
+		for _, p := range patches {
+			overlay.Patches = append(overlay.Patches, KustomizePatchRef{Path: p})
+		}
+	}
+	if files[path.Join(overlayDir, "kustomization.yaml")], err = yaml.Marshal(overlay); err != nil {
+		return nil, fmt.Errorf("序列化kustomization失败: %w", err)
+	}
+	return files, nil
+}
+
+// helmValues 由切片生成chart的values, 默认值与切片模板一致
+func helmValues(slice model.SliceAndAddress, config util.Config) HelmValues {
+	sv, sevs, smfcv, smfdv, _, _, upfdv := sliceToValue(slice, config)
+
+	v := HelmValues{
+		Slice:        HelmSlice{ID: sv.ID, SST: sv.SST, SD: sv.SD},
+		SMF:          helmNF(sv.SMF, smfdv.N3Addr, smfdv.N4Addr),
+		UPF:          helmNF(sv.UPF, upfdv.N3Addr, upfdv.N4Addr),
+		Namespaces:   HelmNamespaces{Shared: sv.SharedNamespace, Slice: sv.Namespace},
+		ClusterCIDRs: sv.ClusterCIDRs,
+		Play:         HelmPlay{NFs: map[string]HelmPlayNF{"smf": {}, "upf": {}}, Objects: []any{}},
+	}
+	v.UPF.N4IP = smfcv.UPFN4AddrIP
+	for i, addr := range upfdv.Replicas {
+		v.UPFReplicas = append(v.UPFReplicas, HelmReplica{N3Addr: addr.N3Addr, N4Addr: addr.N4Addr, N4IP: smfcv.UPFN4AddrIPs[i]})
+	}
+	for _, sev := range sevs {
+		v.Sessions = append(v.Sessions, HelmSession{
+			DNN:         sev.DNN,
+			Subnet:      sev.Subnet,
+			Gateway:     sev.Gateway(),
+			GatewayCIDR: sev.GatewayWithCIDR(),
+			N6Network:   sev.N6Network,
+			N6Interface: sev.N6Interface,
+			N6Table:     sev.N6Table,
+		})
+	}
+	for _, sev := range upfdv.N6 {
+		v.N6 = append(v.N6, HelmSession{DNN: sev.DNN, Subnet: sev.Subnet, N6Network: sev.N6Network, N6Interface: sev.N6Interface, N6Table: sev.N6Table})
+	}
+	return v
+}
+
+func helmNF(nf NFValue, n3Addr, n4Addr string) HelmNF {
+	return HelmNF{
+		N3Addr:       n3Addr,
+		N4Addr:       n4Addr,
+		Image:        nf.Image,
+		DNS:          nf.DNS,
+		MTU:          nf.MTU,
+		MaxUE:        nf.MaxUE,
+		LogLevel:     nf.LogLevel,
+		LogFile:      nf.LogFile,
+		NodeSelector: nf.NodeSelector,
+		Env:          nf.Env,
+		Resources: HelmResources{
+			Requests: map[string]string{"cpu": nf.Resources.CPURequest.String(), "memory": nf.Resources.MemoryRequest.String()},
+			Limits:   map[string]string{"cpu": nf.Resources.CPULimit.String(), "memory": nf.Resources.MemoryLimit.String()},
+		},
+	}
+}
+
+// helmPlay 将renderPlayOverlay生成的patch和资源转化为chart的values
+// 容器的resources和nodeSelector单独列出, 其余Pod spec字段原样写入
+func helmPlay(play model.Play, playFiles map[string][]byte, resources []string) (HelmPlay, error) {
+	v := HelmPlay{NFs: map[string]HelmPlayNF{"smf": {}, "upf": {}}, Objects: []any{}}
+	for _, nf := range play.SectionNames() {
+		name := fmt.Sprintf("play-patch-%s.yaml", nf)
+		var patch struct {
+			Spec struct {
+				Replicas int
+				Template struct {
+					Metadata struct{ Annotations map[string]string }
+					Spec     map[string]any
+				}
+			}
+		}
+		if err := yaml.Unmarshal(playFiles[name], &patch); err != nil {
+			return HelmPlay{}, fmt.Errorf("解析%s失败: %w", name, err)
+		}
+		pod := patch.Spec.Template.Spec
+		nfPlay := HelmPlayNF{Replicas: patch.Spec.Replicas, Pod: pod}
+		if len(patch.Spec.Template.Metadata.Annotations) > 0 {
+			nfPlay.Annotations = patch.Spec.Template.Metadata.Annotations
+		}
+		if containers, ok := pod["containers"].([]any); ok && len(containers) > 0 {
+			if container, ok := containers[0].(map[string]any); ok {
+				nfPlay.Resources, _ = container["resources"].(map[string]any)
+			}
+		}
+		delete(pod, "containers")
+		if selector, ok := pod["nodeSelector"].(map[string]any); ok {
+			nfPlay.NodeSelector = make(map[string]string, len(selector))
+			for k, val := range selector {
+				nfPlay.NodeSelector[k] = fmt.Sprint(val)
+			}
+		}
+		delete(pod, "nodeSelector")
+		if len(pod) == 0 {
+			nfPlay.Pod = nil
+		}
+		v.NFs[nf] = nfPlay
+	}
+	for _, name := range resources {
+		dec := yaml.NewDecoder(bytes.NewReader(playFiles[name]))
+		for {
+			var obj map[string]any
+			if err := dec.Decode(&obj); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return HelmPlay{}, fmt.Errorf("解析%s失败: %w", name, err)
+			}
+			v.Objects = append(v.Objects, obj)
+		}
+	}
+	return v, nil
+}
+
+func packageRoot(slice model.SliceAndAddress) string {
+	return "slice-" + slice.SliceID()
+}
+
+// TarGz 将文件打包为tar.gz, 文件按路径排序
+func TarGz(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+	for _, name := range names {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(files[name])),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, fmt.Errorf("写入tar头失败[%s]: %w", name, err)
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return nil, fmt.Errorf("写入tar内容失败[%s]: %w", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("关闭tar失败: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return nil, fmt.Errorf("关闭gzip失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,438 @@
+package render
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+
+	"slicer/model"
+	"slicer/util"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// helmTemplate 模拟helm渲染chart, 仅实现chart中用到的函数
+func helmTemplate(t *testing.T, files map[string][]byte, root string) map[string][]byte {
+	var values map[string]any
+	require.NoError(t, yaml.Unmarshal(files[path.Join(root, "values.yaml")], &values))
+
+	tmpl := template.New("chart")
+	tmpl.Funcs(template.FuncMap{
+		"include": func(name string, data any) (string, error) {
+			var buf bytes.Buffer
+			err := tmpl.ExecuteTemplate(&buf, name, data)
+			return buf.String(), err
+		},
+		"dict": func(kv ...any) map[string]any {
+			m := make(map[string]any)
+			for i := 0; i+1 < len(kv); i += 2 {
+				m[kv[i].(string)] = kv[i+1]
+			}
+			return m
+		},
+		"nindent": func(n int, s string) string {
+			pad := strings.Repeat(" ", n)
+			return "\n" + pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+		},
+		"toYaml": func(v any) (string, error) {
+			out, err := yaml.Marshal(v)
+			return strings.TrimSuffix(string(out), "\n"), err
+		},
+		"quote": func(v any) string { return fmt.Sprintf("%q", fmt.Sprint(v)) },
+		"set": func(m map[string]any, k string, v any) map[string]any {
+			m[k] = v
+			return m
+		},
+		"default": func(d any, v any) any {
+			if v == nil || v == 0 || v == "" {
+				return d
+			}
+			return v
+		},
+	})
+
+	rendered := make(map[string][]byte)
+	for name, content := range files {
+		if !strings.HasPrefix(name, path.Join(root, "templates")+"/") {
+			continue
+		}
+		_, err := tmpl.New(path.Base(name)).Parse(string(content))
+		require.NoError(t, err, name)
+	}
+	for name := range files {
+		base := path.Base(name)
+		if !strings.HasPrefix(name, path.Join(root, "templates")+"/") || strings.HasPrefix(base, "_") {
+			continue
+		}
+		var buf bytes.Buffer
+		require.NoError(t, tmpl.ExecuteTemplate(&buf, base, map[string]any{"Values": values}), name)
+		rendered[base] = buf.Bytes()
+	}
+	return rendered
+}
+
+// normalize 解析YAML并去除嵌入配置中的行尾空白, 用于比较两种输出
+func normalize(t *testing.T, data []byte) any {
+	var doc any
+	require.NoError(t, yaml.Unmarshal(data, &doc))
+	var walk func(v any) any
+	trailing := regexp.MustCompile(`[ \t]+\n`)
+	walk = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, x := range v {
+				v[k] = walk(x)
+			}
+		case []any:
+			for i, x := range v {
+				v[i] = walk(x)
+			}
+		case string:
+			return strings.TrimSpace(trailing.ReplaceAllString(v, "\n"))
+		}
+		return v
+	}
+	return walk(doc)
+}
+
+// assertChartMatches helm渲染chart的结果应与直接渲染的资源清单一致, play.yaml与Play的额外资源一致
+func assertChartMatches(t *testing.T, chart, manifests map[string][]byte, objects []byte) {
+	for name, content := range manifests {
+		require.Contains(t, chart, name)
+		assert.Equal(t, normalize(t, content), normalize(t, chart[name]), name)
+	}
+	require.Len(t, chart, len(manifests)+1)
+	assert.Equal(t, normalizeDocs(t, objects), normalizeDocs(t, chart["play.yaml"]))
+}
+
+// normalizeDocs 解析多文档YAML, 忽略空文档
+func normalizeDocs(t *testing.T, data []byte) []any {
+	var docs []any
+	for _, doc := range bytes.Split(data, []byte("\n---\n")) {
+		if len(bytes.TrimSpace(bytes.TrimPrefix(doc, []byte("---")))) == 0 {
+			continue
+		}
+		docs = append(docs, normalize(t, doc))
+	}
+	return docs
+}
+
+func TestRenderHelmChart(t *testing.T) {
+	r := testRender
+
+	slice := testSlice
+	slice.NF = &model.NFParams{
+		SMF: model.NFParam{Env: map[string]string{"FOO": "true"}},
+		UPF: model.NFParam{DNS: []string{"1.1.1.1"}, NodeSelector: map[string]string{"edge": "true"}},
+	}
+
+	files, err := r.RenderHelmChart(slice, nil)
+	require.NoError(t, err)
+	require.Contains(t, string(files["slice-1-000001/Chart.yaml"]), "name: slice-1-000001")
+
+	// values.yaml由切片及地址生成
+	var values HelmValues
+	require.NoError(t, yaml.Unmarshal(files["slice-1-000001/values.yaml"], &values))
+	assert.Equal(t, HelmSlice{ID: "1-000001", SST: "1", SD: "000001"}, values.Slice)
+	require.Len(t, values.Sessions, 2)
+	assert.Equal(t, HelmSession{DNN: "internet", Subnet: "10.40.0.0/16", Gateway: "10.40.0.1", GatewayCIDR: "10.40.0.1/16"}, values.Sessions[0])
+	assert.Equal(t, "10.10.3.1/16", values.UPF.N3Addr)
+	assert.Equal(t, "10.10.4.1/16", values.UPF.N4Addr)
+	assert.Equal(t, "10.10.4.1", values.UPF.N4IP)
+	assert.Equal(t, "10.10.3.2/16", values.SMF.N3Addr)
+	assert.Equal(t, "10.10.4.2/16", values.SMF.N4Addr)
+	assert.Equal(t, map[string]string{"FOO": "true"}, values.SMF.Env)
+	assert.Equal(t, map[string]string{"edge": "true"}, values.UPF.NodeSelector)
+	assert.Empty(t, values.Play.Objects)
+
+	// 模板引用values, 而不是渲染后的值
+	var templates strings.Builder
+	for name, content := range files {
+		if strings.HasPrefix(name, "slice-1-000001/templates/") {
+			templates.Write(content)
+		}
+	}
+	for _, ref := range []string{
+		".Values.slice.id", ".Values.slice.sst", ".Values.slice.sd", ".Values.sessions",
+		".Values.smf.n3Addr", ".Values.smf.n4Addr", ".Values.upf.n3Addr", ".Values.upf.n4Addr", ".Values.upfReplicas",
+		".Values.smf.image", ".Values.upf.env", ".Values.upf.resources", ".Values.play.nfs.smf", ".Values.play.objects",
+	} {
+		assert.Contains(t, templates.String(), ref, ref)
+	}
+	assert.NotContains(t, templates.String(), "10.10.3.1")
+
+	// helm渲染结果应与直接渲染一致
+	manifests, err := r.RenderSliceFiles(slice)
+	require.NoError(t, err)
+	assertChartMatches(t, helmTemplate(t, files, "slice-1-000001"), manifests, nil)
+
+	// Play写入values, 渲染结果与kustomize overlay合并后一致
+	play := &model.Play{
+		SliceID:   "1-000001",
+		Bandwidth: model.BandwidthSpec{Ingress: "100M"},
+		Scheduling: model.SchedulingSpec{
+			NodeSelector: map[string]string{"zone": "a"},
+			Tolerations:  []corev1.Toleration{{Key: "edge", Operator: corev1.TolerationOpExists}},
+		},
+		NFs: map[string]model.NFPlay{
+			"smf": {Resources: model.ResourceSpec{CPURequest: "200m", CPULimit: "500m", MemoryRequest: "256Mi", MemoryLimit: "512Mi"}, Priority: 500},
+			"upf": {Replicas: 2},
+		},
+	}
+	files, err = r.RenderHelmChart(slice, play)
+	require.NoError(t, err)
+	require.NoError(t, yaml.Unmarshal(files["slice-1-000001/values.yaml"], &values))
+	assert.Equal(t, "100M", values.Play.NFs["upf"].Annotations["kubernetes.io/ingress-bandwidth"])
+	assert.Equal(t, 2, values.Play.NFs["upf"].Replicas)
+	assert.Equal(t, "priority-1-000001-500", values.Play.NFs["smf"].Pod["priorityClassName"])
+	require.Len(t, values.Play.Objects, 1)
+
+	manifests, err = r.RenderSliceFiles(slice)
+	require.NoError(t, err)
+	playFiles, _, patches, err := renderPlayOverlay(*play, manifests)
+	require.NoError(t, err)
+	require.NoError(t, applyPlayPatches(manifests, playFiles, patches))
+	chart := helmTemplate(t, files, "slice-1-000001")
+	assertChartMatches(t, chart, manifests, playFiles["priorityclass.yaml"])
+
+	var upf struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					NodeSelector map[string]string `yaml:"nodeSelector"`
+				}
+			}
+		}
+	}
+	require.NoError(t, yaml.Unmarshal(chart["upf-deployment.yaml"], &upf))
+	// Play的nodeSelector与NF参数合并
+	assert.Equal(t, map[string]string{"edge": "true", "zone": "a"}, upf.Spec.Template.Spec.NodeSelector)
+}
+
+func TestRenderScaledUPF(t *testing.T) {
+	slice := testSlice
+	slice.UPFReplicaAddrs = []model.ReplicaAddress{
+		{N3Addr: "10.10.3.3/16", N4Addr: "10.10.4.3/16"},
+		{N3Addr: "10.10.3.4/16", N4Addr: "10.10.4.4/16"},
+	}
+	manifests, err := testRender.RenderSliceFiles(slice)
+	require.NoError(t, err)
+
+	// UPF以StatefulSet部署, 使用不带IPAM的网络, 副本数由Play或HPA管理
+	var upf struct {
+		Kind string
+		Spec struct {
+			Replicas    *int
+			ServiceName string `yaml:"serviceName"`
+			Template    struct {
+				Metadata struct{ Annotations map[string]string }
+			}
+		}
+	}
+	require.NoError(t, yaml.Unmarshal(manifests["upf-deployment.yaml"], &upf))
+	assert.Equal(t, "StatefulSet", upf.Kind)
+	assert.Nil(t, upf.Spec.Replicas)
+	assert.Equal(t, "upf1-000001", upf.Spec.ServiceName)
+	assert.NotContains(t, upf.Spec.Template.Metadata.Annotations["k8s.v1.cni.cncf.io/networks"], "ips")
+	assert.Contains(t, upf.Spec.Template.Metadata.Annotations["k8s.v1.cni.cncf.io/networks"], `"name": "n3network-noipam"`)
+
+	// wrapper.sh按序号选择地址, SMF连接所有副本
+	assert.Contains(t, string(manifests["upf-configmap.yaml"]), "N4_ADDRS=(10.10.4.1/16 10.10.4.3/16 10.10.4.4/16);")
+	for _, ip := range []string{"10.10.4.1", "10.10.4.3", "10.10.4.4"} {
+		assert.Contains(t, string(manifests["smf-configmap.yaml"]), "- address: "+ip)
+	}
+
+	// helm渲染结果一致
+	files, err := testRender.RenderHelmChart(slice, nil)
+	require.NoError(t, err)
+	assertChartMatches(t, helmTemplate(t, files, "slice-1-000001"), manifests, nil)
+}
+
+func TestRenderNamespacePerSlice(t *testing.T) {
+	r, err := NewRender(util.Config{KubeConfig: util.KubeConfig{Namespace: "open5gs", NamespacePerSlice: true}})
+	require.NoError(t, err)
+
+	manifests, err := r.RenderSliceFiles(testSlice)
+	require.NoError(t, err)
+
+	// 共享NF及NAD通过共享命名空间访问, SMF向SCP通告跨命名空间的地址
+	smf := string(manifests["smf-configmap.yaml"])
+	assert.Contains(t, smf, "uri: http://scp-nscp.open5gs.svc:80")
+	assert.Contains(t, smf, "advertise: smf1-000001-nsmf.slice-1-000001.svc")
+	assert.Contains(t, string(manifests["smf-deployment.yaml"]), "value: ausf-nausf.open5gs.svc:80")
+	assert.Contains(t, string(manifests["upf-deployment.yaml"]), `{ "name": "n3network", "namespace": "open5gs", "interface": "n3"`)
+	assert.Contains(t, string(manifests["smf-networkpolicy.yaml"]), "kubernetes.io/metadata.name: open5gs")
+
+	// helm渲染结果一致
+	files, err := r.RenderHelmChart(testSlice, nil)
+	require.NoError(t, err)
+	assertChartMatches(t, helmTemplate(t, files, "slice-1-000001"), manifests, nil)
+
+	// overlay设置切片的命名空间
+	files, err = r.RenderKustomize(testSlice, nil)
+	require.NoError(t, err)
+	require.Contains(t, files, "slice-1-000001/overlays/slice-1-000001/kustomization.yaml")
+}
+
+func TestRenderKustomizeTarGz(t *testing.T) {
+	r, err := NewRender(util.Config{KubeConfig: util.KubeConfig{Namespace: "open5gs"}})
+	require.NoError(t, err)
+
+	play := &model.Play{
+		SliceID:   "1-000001",
+		Resources: model.ResourceSpec{CPURequest: "500m", CPULimit: "1", MemoryRequest: "512Mi", MemoryLimit: "1Gi"},
+		Bandwidth: model.BandwidthSpec{Ingress: "100M", Egress: "100M"},
+		Priority:  1000,
+		NFs: map[string]model.NFPlay{
+			"smf": {Resources: model.ResourceSpec{CPURequest: "200m", CPULimit: "500m", MemoryRequest: "256Mi", MemoryLimit: "512Mi"}, Priority: 500},
+		},
+	}
+	files, err := r.RenderPackage(testSlice, play, FormatKustomize)
+	require.NoError(t, err)
+	require.Contains(t, files, "slice-1-000001/base/upf-deployment.yaml")
+	require.Contains(t, files, "slice-1-000001/overlays/open5gs/priorityclass.yaml")
+
+	var overlay Kustomization
+	require.NoError(t, yaml.Unmarshal(files["slice-1-000001/overlays/open5gs/kustomization.yaml"], &overlay))
+	assert.Equal(t, "open5gs", overlay.Namespace)
+	assert.Equal(t, []string{"../../base", "priorityclass.yaml"}, overlay.Resources)
+	assert.Equal(t, []KustomizePatchRef{{Path: "play-patch-smf.yaml"}, {Path: "play-patch-upf.yaml"}}, overlay.Patches)
+
+	var patch struct {
+		Metadata struct{ Name string }
+		Spec     struct {
+			Template struct {
+				Metadata struct{ Annotations map[string]string }
+				Spec     struct {
+					PriorityClassName string `yaml:"priorityClassName"`
+					Containers        []struct{ Name string }
+				}
+			}
+		}
+	}
+	require.NoError(t, yaml.Unmarshal(files["slice-1-000001/overlays/open5gs/play-patch-upf.yaml"], &patch))
+	assert.Equal(t, "open5gs-upf1-000001", patch.Metadata.Name)
+	assert.Equal(t, "100M", patch.Spec.Template.Metadata.Annotations["kubernetes.io/ingress-bandwidth"])
+	assert.Equal(t, "priority-1-000001-1000", patch.Spec.Template.Spec.PriorityClassName)
+	assert.Equal(t, "upf", patch.Spec.Template.Spec.Containers[0].Name)
+
+	// SMF的容器名称带切片ID
+	require.NoError(t, yaml.Unmarshal(files["slice-1-000001/overlays/open5gs/play-patch-smf.yaml"], &patch))
+	assert.Equal(t, "open5gs-smf1-000001", patch.Metadata.Name)
+	assert.Equal(t, "priority-1-000001-500", patch.Spec.Template.Spec.PriorityClassName)
+	assert.Equal(t, "smf1-000001", patch.Spec.Template.Spec.Containers[0].Name)
+	assert.Equal(t, 2, bytes.Count(files["slice-1-000001/overlays/open5gs/priorityclass.yaml"], []byte("kind: PriorityClass")))
+
+	_, err = r.RenderPackage(testSlice, nil, "unknown")
+	require.Error(t, err)
+
+	data, err := TarGz(files)
+	require.NoError(t, err)
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	require.Len(t, names, len(files))
+}
+
+func TestRenderN6Networks(t *testing.T) {
+	r, err := NewRender(util.Config{IPAMConfig: util.IPAMConfig{N6Networks: map[string]string{"internet": "eth2"}}})
+	require.NoError(t, err)
+
+	manifests, err := r.RenderSliceFiles(testSlice)
+	require.NoError(t, err)
+
+	// 只有配置了N6网络的DNN接入对应的NAD
+	var upf struct {
+		Spec struct {
+			Template struct {
+				Metadata struct{ Annotations map[string]string }
+			}
+		}
+	}
+	require.NoError(t, yaml.Unmarshal(manifests["upf-deployment.yaml"], &upf))
+	var networks []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(upf.Spec.Template.Metadata.Annotations["k8s.v1.cni.cncf.io/networks"]), &networks))
+	require.Len(t, networks, 3)
+	assert.Equal(t, map[string]any{"name": "n6network-internet", "interface": "n6-internet"}, networks[2])
+
+	// internet的流量经N6接口转发, streaming仍经eth0 NAT
+	upfConfig := string(manifests["upf-configmap.yaml"])
+	assert.Contains(t, upfConfig, "ip rule add from 10.40.0.0/16 lookup 100;")
+	assert.Contains(t, upfConfig, "iptables -t nat -A POSTROUTING -s 10.40.0.0/16 -o n6-internet -j MASQUERADE;")
+	assert.Contains(t, upfConfig, "iptables -t nat -A POSTROUTING -s 10.41.0.0/16 ! -o ogstun -j MASQUERADE;")
+
+	// helm渲染结果一致
+	files, err := r.RenderHelmChart(testSlice, nil)
+	require.NoError(t, err)
+	assertChartMatches(t, helmTemplate(t, files, "slice-1-000001"), manifests, nil)
+}
+
+func TestRenderNetworkPolicyClusterCIDRs(t *testing.T) {
+	cidrs := []string{"10.244.0.0/16", "10.96.0.0/12"}
+	r, err := NewRender(util.Config{KubeConfig: util.KubeConfig{ClusterCIDRs: cidrs}})
+	require.NoError(t, err)
+
+	manifests, err := r.RenderSliceFiles(testSlice)
+	require.NoError(t, err)
+
+	type rule struct {
+		To []struct {
+			IPBlock *struct {
+				CIDR   string   `yaml:"cidr"`
+				Except []string `yaml:"except"`
+			} `yaml:"ipBlock"`
+		} `yaml:"to"`
+		Ports []struct {
+			Port     int    `yaml:"port"`
+			Protocol string `yaml:"protocol"`
+		} `yaml:"ports"`
+	}
+	var policy struct {
+		Spec struct {
+			PolicyTypes []string `yaml:"policyTypes"`
+			Egress      []rule   `yaml:"egress"`
+		} `yaml:"spec"`
+	}
+
+	// UPF的N6出站规则排除集群网段
+	require.NoError(t, yaml.Unmarshal(manifests["upf-networkpolicy.yaml"], &policy))
+	n6 := policy.Spec.Egress[len(policy.Spec.Egress)-1]
+	require.NotNil(t, n6.To[0].IPBlock)
+	assert.Equal(t, "0.0.0.0/0", n6.To[0].IPBlock.CIDR)
+	assert.Equal(t, cidrs, n6.To[0].IPBlock.Except)
+
+	// SMF只能访问共享NF、本切片的UPF及DNS
+	policy.Spec.Egress = nil
+	require.NoError(t, yaml.Unmarshal(manifests["smf-networkpolicy.yaml"], &policy))
+	assert.Equal(t, []string{"Ingress", "Egress"}, policy.Spec.PolicyTypes)
+	require.Len(t, policy.Spec.Egress, 3)
+	assert.Equal(t, 8805, policy.Spec.Egress[1].Ports[0].Port)
+	assert.Equal(t, 53, policy.Spec.Egress[2].Ports[0].Port)
+	assert.Empty(t, policy.Spec.Egress[2].To)
+
+	// helm渲染结果一致
+	files, err := r.RenderHelmChart(testSlice, nil)
+	require.NoError(t, err)
+	assertChartMatches(t, helmTemplate(t, files, "slice-1-000001"), manifests, nil)
+}
@@ -0,0 +1,322 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slicer/model"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// renderPlayOverlay 将Play转化为kustomize overlay中的文件, 效果与kubeclient.Play一致
+// manifests为base中渲染出的资源清单, 用于确定各NF的工作负载类型并按名称匹配容器
+// 返回文件名到内容的映射, 以及需要加入kustomization的resources和patches
+func renderPlayOverlay(play model.Play, manifests map[string][]byte) (files map[string][]byte, resources, patches []string, err error) {
+	files = make(map[string][]byte)
+	workloads, err := manifestWorkloads(manifests)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 每个NF一个Deployment的strategic merge patch, 优先级类去重后写入同一文件
+	sections := play.Sections()
+	var priorityClasses []any
+	seenClasses := make(map[string]bool)
+	for _, nf := range play.SectionNames() {
+		section := sections[nf]
+		deploymentName := model.DeploymentName(nf, play.SliceID)
+		workload, ok := workloads[deploymentName]
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("切片中没有 Deployment/%s", deploymentName)
+		}
+		containerName := ""
+		for _, candidate := range section.ContainerNames(nf, play.SliceID) {
+			if workload.containers[candidate] {
+				containerName = candidate
+				break
+			}
+		}
+		if containerName == "" {
+			return nil, nil, nil, fmt.Errorf("%s/%s 中没有容器 %s", workload.kind, deploymentName,
+				strings.Join(section.ContainerNames(nf, play.SliceID), " 或 "))
+		}
+
+		// 补丁由GitOps工具同步, 无法在Pod中执行tc, 因此总是通过注解限速
+		annotations := map[string]string{}
+		for key, bw := range map[string]model.Bandwidth{
+			"kubernetes.io/ingress-bandwidth": section.Bandwidth.Ingress,
+			"kubernetes.io/egress-bandwidth":  section.Bandwidth.Egress,
+		} {
+			if bw == "" {
+				continue
+			}
+			normalized, err := bw.Normalize()
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("%s带宽参数错误: %w", nf, err)
+			}
+			annotations[key] = normalized.String()
+		}
+		for k, v := range section.Annotations {
+			annotations[k] = v
+		}
+
+		podSpec := map[string]any{}
+		if section.Resources != (model.ResourceSpec{}) {
+			podSpec["containers"] = []map[string]any{{
+				"name": containerName,
+				"resources": map[string]any{
+					"requests": map[string]model.Quantity{"cpu": section.Resources.CPURequest, "memory": section.Resources.MemoryRequest},
+					"limits":   map[string]model.Quantity{"cpu": section.Resources.CPULimit, "memory": section.Resources.MemoryLimit},
+				},
+			}}
+		}
+		if section.Scheduling.SchedulerName != "" {
+			podSpec["schedulerName"] = section.Scheduling.SchedulerName
+		}
+		if section.Scheduling.NodeName != "" {
+			podSpec["nodeName"] = section.Scheduling.NodeName
+		}
+		if len(section.Scheduling.NodeSelector) > 0 {
+			podSpec["nodeSelector"] = section.Scheduling.NodeSelector
+		}
+		if section.Scheduling.Affinity != nil {
+			podSpec["affinity"] = section.Scheduling.Affinity
+		}
+		if len(section.Scheduling.Tolerations) > 0 {
+			podSpec["tolerations"] = section.Scheduling.Tolerations
+		}
+		if len(section.Scheduling.TopologySpreadConstraints) > 0 {
+			podSpec["topologySpreadConstraints"] = section.Scheduling.TopologySpreadConstraints
+		}
+		if section.Scheduling.RuntimeClassName != "" {
+			podSpec["runtimeClassName"] = section.Scheduling.RuntimeClassName
+		}
+
+		// 优先级, 0表示不设置
+		if section.Priority != 0 {
+			className := section.Priority.ClassName(play.SliceID)
+			podSpec["priorityClassName"] = className
+			if !seenClasses[className] {
+				seenClasses[className] = true
+				priorityClasses = append(priorityClasses, map[string]any{
+					"apiVersion":       "scheduling.k8s.io/v1",
+					"kind":             "PriorityClass",
+					"metadata":         map[string]any{"name": className},
+					"value":            int(section.Priority),
+					"globalDefault":    false,
+					"description":      fmt.Sprintf("Priority class for slice %s", play.SliceID),
+					"preemptionPolicy": "PreemptLowerPriority",
+				})
+			}
+		}
+
+		spec := map[string]any{
+			"template": map[string]any{
+				"metadata": map[string]any{"annotations": annotations},
+				"spec":     podSpec,
+			},
+		}
+		// 副本数, 设置autoscaling时由HPA管理
+		if section.Autoscaling != nil {
+			hpa := autoscalingv2.HorizontalPodAutoscaler{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler"},
+				ObjectMeta: metav1.ObjectMeta{Name: deploymentName},
+				Spec:       section.Autoscaling.HPASpec(workload.kind, deploymentName),
+			}
+			name := fmt.Sprintf("hpa-%s.yaml", nf)
+			if files[name], err = marshalObject(hpa); err != nil {
+				return nil, nil, nil, fmt.Errorf("序列化HPA失败: %w", err)
+			}
+			resources = append(resources, name)
+		} else if section.Replicas > 0 {
+			spec["replicas"] = section.Replicas
+		}
+
+		patch := map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       workload.kind,
+			"metadata":   map[string]any{"name": deploymentName},
+			"spec":       spec,
+		}
+		name := fmt.Sprintf("play-patch-%s.yaml", nf)
+		// 调度参数中的Kubernetes类型按json标签序列化
+		if files[name], err = marshalObject(patch); err != nil {
+			return nil, nil, nil, fmt.Errorf("序列化Play patch失败: %w", err)
+		}
+		patches = append(patches, name)
+	}
+
+	if len(priorityClasses) > 0 {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		for _, pc := range priorityClasses {
+			if err := enc.Encode(pc); err != nil {
+				return nil, nil, nil, fmt.Errorf("序列化PriorityClass失败: %w", err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			return nil, nil, nil, fmt.Errorf("序列化PriorityClass失败: %w", err)
+		}
+		files["priorityclass.yaml"] = buf.Bytes()
+		resources = append(resources, "priorityclass.yaml")
+	}
+
+	// 网络策略
+	np := play.NetworkPolicy
+	if np.Name != "" {
+		np.APIVersion, np.Kind = "networking.k8s.io/v1", "NetworkPolicy"
+		np.Namespace = "" // 由overlay统一设置
+		if files["networkpolicy.yaml"], err = marshalObject(np); err != nil {
+			return nil, nil, nil, fmt.Errorf("序列化NetworkPolicy失败: %w", err)
+		}
+		resources = append(resources, "networkpolicy.yaml")
+	}
+	// 放行规则, 每条规则一个NetworkPolicy
+	for _, rule := range play.AllowRules {
+		name := fmt.Sprintf("networkpolicy-allow-%s.yaml", rule.Name)
+		if files[name], err = marshalObject(rule.NetworkPolicy(play.SliceID)); err != nil {
+			return nil, nil, nil, fmt.Errorf("序列化放行规则%s失败: %w", rule.Name, err)
+		}
+		resources = append(resources, name)
+	}
+	return files, resources, patches, nil
+}
+
+// applyPlayPatches 将renderPlayOverlay生成的patch以strategic merge的方式合并到资源清单的工作负载中
+// 效果与kustomize应用overlay相同, 只重新序列化被修改的文档
+func applyPlayPatches(manifests map[string][]byte, playFiles map[string][]byte, patches []string) error {
+	targets := make(map[string][]byte, len(patches))
+	for _, name := range patches {
+		var patch struct {
+			Kind     string
+			Metadata struct{ Name string }
+		}
+		if err := yaml.Unmarshal(playFiles[name], &patch); err != nil {
+			return fmt.Errorf("解析%s失败: %w", name, err)
+		}
+		patchJSON, err := sigsyaml.YAMLToJSON(playFiles[name])
+		if err != nil {
+			return fmt.Errorf("解析%s失败: %w", name, err)
+		}
+		targets[patch.Kind+"/"+patch.Metadata.Name] = patchJSON
+	}
+
+	for file, data := range manifests {
+		var docs [][]byte
+		changed := false
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var node yaml.Node
+			if err := dec.Decode(&node); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return fmt.Errorf("解析%s失败: %w", file, err)
+			}
+			var doc struct {
+				Kind     string
+				Metadata struct{ Name string }
+			}
+			if err := node.Decode(&doc); err != nil {
+				return fmt.Errorf("解析%s失败: %w", file, err)
+			}
+			content, err := yaml.Marshal(&node)
+			if err != nil {
+				return fmt.Errorf("序列化%s失败: %w", file, err)
+			}
+			if patch, ok := targets[doc.Kind+"/"+doc.Metadata.Name]; ok {
+				if content, err = strategicMerge(doc.Kind, content, patch); err != nil {
+					return fmt.Errorf("合并Play到%s/%s失败: %w", doc.Kind, doc.Metadata.Name, err)
+				}
+				changed = true
+			}
+			docs = append(docs, content)
+		}
+		if changed {
+			manifests[file] = bytes.Join(docs, []byte("---\n"))
+		}
+	}
+	return nil
+}
+
+// strategicMerge 将JSON格式的patch合并到YAML格式的工作负载
+func strategicMerge(kind string, original, patch []byte) ([]byte, error) {
+	var schema any
+	switch kind {
+	case "Deployment":
+		schema = appsv1.Deployment{}
+	case "StatefulSet":
+		schema = appsv1.StatefulSet{}
+	default:
+		return nil, fmt.Errorf("不支持的工作负载类型: %s", kind)
+	}
+	originalJSON, err := sigsyaml.YAMLToJSON(original)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := strategicpatch.StrategicMergePatch(originalJSON, patch, schema)
+	if err != nil {
+		return nil, err
+	}
+	return sigsyaml.JSONToYAML(merged)
+}
+
+// marshalObject 将Kubernetes对象序列化为YAML, 字段名与API一致
+func marshalObject(obj any) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return sigsyaml.JSONToYAML(data)
+}
+
+// manifestWorkload 资源清单中的Deployment或StatefulSet
+type manifestWorkload struct {
+	kind       string
+	containers map[string]bool
+}
+
+// manifestWorkloads 返回资源清单中各工作负载的类型和容器名称
+func manifestWorkloads(manifests map[string][]byte) (map[string]manifestWorkload, error) {
+	result := make(map[string]manifestWorkload)
+	for file, data := range manifests {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var doc struct {
+				Kind     string
+				Metadata struct{ Name string }
+				Spec     struct {
+					Template struct {
+						Spec struct {
+							Containers []struct{ Name string }
+						}
+					}
+				}
+			}
+			if err := dec.Decode(&doc); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("解析%s失败: %w", file, err)
+			}
+			if doc.Kind != "Deployment" && doc.Kind != "StatefulSet" {
+				continue
+			}
+			names := make(map[string]bool)
+			for _, c := range doc.Spec.Template.Spec.Containers {
+				names[c.Name] = true
+			}
+			result[doc.Metadata.Name] = manifestWorkload{kind: doc.Kind, containers: names}
+		}
+	}
+	return result, nil
+}
@@ -2,26 +2,38 @@ package render
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"log/slog"
-	"os"
-	"path/filepath"
 	"slicer/model"
 	"slicer/util"
+	"slices"
 	"strconv"
 	"strings"
-	"text/template"
+	"sync"
 )
 
 type Render struct {
 	// slice转化为kubernetes配置文件
 	config util.Config
+
+	mu        sync.RWMutex // 保护以下字段
+	set       *templateSet // 当前生效的模板
+	reloads   int          // 热加载成功次数
+	lastError string       // 最近一次加载失败的原因
+	cancel    context.CancelFunc
 }
 
-func NewRender(config util.Config) *Render {
+// NewRender 加载内嵌模板与覆盖目录(TemplatePath)中的模板, 所有模板均需解析及试渲染成功
+func NewRender(config util.Config) (*Render, error) {
+	set, err := loadTemplates(config.TemplatePath)
+	if err != nil {
+		return nil, fmt.Errorf("加载模板失败: %w", err)
+	}
 	return &Render{
 		config: config,
-	}
+		set:    set,
+	}, nil
 }
 
 func (r *Render) RenderKpiCalc(sliceID string) (content []byte, err error) {
@@ -38,61 +50,76 @@ func (r *Render) RenderMde(sliceID string) (content []byte, err error) {
 		SliceID:  sliceID,
 		Interval: r.config.MonarchMonitoringInterval,
 	}
+	if r.config.NamespacePerSlice && sliceID != "" {
+		v.Namespace = r.config.SliceNamespace(sliceID)
+		v.SharedNamespace = r.config.Namespace
+	}
 
 	// return r.render("metrics-servicemonitor.yaml.tpl", v)
 	return r.render("metrics-service.yaml.tpl", v)
 }
 
+// 切片的资源模板, 顺序即为部署顺序
+var sliceTemplates = []string{
+	"smf-configmap.yaml.tpl",
+	"smf-deployment.yaml.tpl",
+	"smf-service.yaml.tpl",
+	"upf-configmap.yaml.tpl",
+	"upf-deployment.yaml.tpl",
+	"smf-networkpolicy.yaml.tpl",
+	"upf-networkpolicy.yaml.tpl",
+}
+
 func (r *Render) RenderSlice(slice model.SliceAndAddress) (contents [][]byte, err error) {
-	_, _, smfcv, smfdv, smfsv, upfcv, upfdv := sliceToValue(slice)
+	files, err := r.RenderSliceFiles(slice)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tplFile := range sliceTemplates {
+		contents = append(contents, files[strings.TrimSuffix(tplFile, ".tpl")])
+	}
+	return
+}
+
+// RenderSliceFiles 渲染切片资源, key为输出文件名(如smf-configmap.yaml)
+func (r *Render) RenderSliceFiles(slice model.SliceAndAddress) (files map[string][]byte, err error) {
+	sv, _, smfcv, smfdv, smfsv, upfcv, upfdv := sliceToValue(slice, r.config)
 
 	//从value中生成kubernetes配置文件
 
-	// 定义各资源对应的模板和输出文件
-	resources := []struct {
-		templateFile string
-		// outputFile   string
-		data interface{}
-	}{
-		// {"smf-configmap.yaml.tpl", "smf-configmap.yaml", smfcv},
-		// {"smf-deployment.yaml.tpl", "smf-deployment.yaml", smfdv},
-		// {"smf-service.yaml.tpl", "smf-service.yaml", smfsv},
-		// {"upf-configmap.yaml.tpl", "upf-configmap.yaml", upfcv},
-		// {"upf-deployment.yaml.tpl", "upf-deployment.yaml", upfdv},
-		{"smf-configmap.yaml.tpl", smfcv},
-		{"smf-deployment.yaml.tpl", smfdv},
-		{"smf-service.yaml.tpl", smfsv},
-		{"upf-configmap.yaml.tpl", upfcv},
-		{"upf-deployment.yaml.tpl", upfdv},
+	// 各资源模板对应的值
+	values := map[string]any{
+		"smf-configmap.yaml.tpl":  smfcv,
+		"smf-deployment.yaml.tpl": smfdv,
+		"smf-service.yaml.tpl":    smfsv,
+		"upf-configmap.yaml.tpl":  upfcv,
+		"upf-deployment.yaml.tpl": upfdv,
+		// 切片的默认隔离策略
+		"smf-networkpolicy.yaml.tpl": sv,
+		"upf-networkpolicy.yaml.tpl": sv,
 	}
 
-	for _, res := range resources {
-		content, err := r.render(res.templateFile, res.data)
+	files = make(map[string][]byte, len(sliceTemplates))
+	for _, tplFile := range sliceTemplates {
+		content, err := r.render(tplFile, values[tplFile])
 		if err != nil {
-			return nil, fmt.Errorf("渲染失败[%s]: %w", res.templateFile, err)
+			return nil, fmt.Errorf("渲染失败[%s]: %w", tplFile, err)
 		}
 
-		contents = append(contents, content)
+		files[strings.TrimSuffix(tplFile, ".tpl")] = content
 	}
 
 	return
 }
 
 func (r *Render) render(tplFile string, value any) ([]byte, error) {
-	// 构造模板文件路径
-	tplPath := filepath.Join(r.config.TemplatePath, tplFile)
-
-	// 读取模板内容
-	tplContent, err := os.ReadFile(tplPath)
-	if err != nil {
-		return nil, fmt.Errorf("读取模板文件 %s 失败: %v", tplPath, err)
-
-	}
-
-	// 解析模板
-	tmpl, err := template.New(tplFile).Parse(string(tplContent))
-	if err != nil {
-		return nil, fmt.Errorf("解析模板失败[%s]: %w", tplFile, err)
+	// 获取已加载的模板
+	r.mu.RLock()
+	tmpl, ok := r.set.templates[tplFile]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("模板不存在: %s", tplFile)
 	}
 
 	// 渲染到内存缓冲区
@@ -104,7 +131,8 @@ func (r *Render) render(tplFile string, value any) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func sliceToValue(ws model.SliceAndAddress) (
+// sliceToValue 由切片生成模板的值, 配置用于确定切片及共享NF所在的命名空间, 以及各DNN的N6网络
+func sliceToValue(ws model.SliceAndAddress, config util.Config) (
 	sv SliceValue,
 	sevs SessionValues,
 	smfcv SmfConfigmapValue,
@@ -116,6 +144,19 @@ func sliceToValue(ws model.SliceAndAddress) (
 	sv.ID = ws.SliceID()
 	sv.SST = strconv.Itoa(ws.SST)
 	sv.SD = ws.SD
+	kube := config.KubeConfig
+	if kube.NamespacePerSlice {
+		sv.Namespace = kube.SliceNamespace(sv.ID)
+		sv.SharedNamespace = kube.Namespace
+	}
+	sv.ClusterCIDRs = kube.ClusterCIDRs
+
+	var nf model.NFParams
+	if ws.NF != nil {
+		nf = *ws.NF
+	}
+	sv.SMF = newNFValue("smf", nf.SMF)
+	sv.UPF = newNFValue("upf", nf.UPF)
 
 	if len(ws.Sessions) != len(ws.SessionSubnets) {
 		slog.Warn("会话数和会话子网数不一致",
@@ -130,11 +171,24 @@ func sliceToValue(ws model.SliceAndAddress) (
 			DNN:    session.Name,
 			Subnet: ws.SessionSubnets[idx],
 		}
+		if n6 := config.N6Interface(session.Name); n6 != "" {
+			sev.N6Network = util.N6NetworkName(session.Name)
+			sev.N6Interface = n6
+			sev.N6Table = n6RouteTableBase + idx
+			// 多个会话使用同一DNN时只接入一次
+			if !slices.ContainsFunc(upfdv.N6, func(v SessionValue) bool { return v.DNN == sev.DNN }) {
+				upfdv.N6 = append(upfdv.N6, sev)
+			}
+		}
 		sevs = append(sevs, sev)
 	}
 
 	smfcv.SliceValue = sv
+	replicas := UPFReplicas(ws.UPFAddrs())
 	smfcv.UPFN4AddrIP = ws.UPFN4Addr[:strings.Index(ws.UPFN4Addr, "/")]
+	for _, addr := range replicas {
+		smfcv.UPFN4AddrIPs = append(smfcv.UPFN4AddrIPs, strings.SplitN(addr.N4Addr, "/", 2)[0])
+	}
 	smfcv.SessionValues = sevs
 
 	smfdv.SliceValue = sv
@@ -145,10 +199,12 @@ func sliceToValue(ws model.SliceAndAddress) (
 
 	upfcv.SliceValue = sv
 	upfcv.SessionValues = sevs
+	upfcv.Replicas = replicas
 
 	upfdv.SliceValue = sv
 	upfdv.N4Addr = ws.UPFN4Addr
 	upfdv.N3Addr = ws.UPFN3Addr
+	upfdv.Replicas = replicas
 
 	return
 }
@@ -37,7 +37,8 @@ func TestRenderSlice(t *testing.T) {
 		TemplatePath: "./template",
 		// 假设模板文件已放在测试目录
 	}
-	r := NewRender(config)
+	r, err := NewRender(config)
+	require.NoError(t, err, "加载模板失败")
 
 	// 生成配置内容
 	contents, err := r.RenderSlice(testSlice)
@@ -156,7 +157,13 @@ var testConfig = util.Config{
 	},
 }
 
-var testRender = NewRender(testConfig)
+var testRender = func() *Render {
+	r, err := NewRender(testConfig)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}()
 
 // 测试函数完整实现
 func TestRenderMde(t *testing.T) {
@@ -262,3 +269,75 @@ func TestRenderKpiCalc(t *testing.T) {
 
 	// TODO
 }
+
+func TestRenderSliceNFParams(t *testing.T) {
+	r := testRender
+
+	slice := testSlice
+	slice.NF = &model.NFParams{
+		SMF: model.NFParam{
+			DNS:      []string{"1.1.1.1"},
+			MTU:      1300,
+			MaxUE:    64,
+			LogLevel: "debug",
+			Env:      map[string]string{"FOO": "true"},
+		},
+		UPF: model.NFParam{
+			Image:        "example.com/open5gs:test",
+			NodeSelector: map[string]string{"edge": "true"},
+			Resources:    &model.ResourceSpec{CPURequest: "1", CPULimit: "2", MemoryRequest: "1Gi", MemoryLimit: "2Gi"},
+		},
+	}
+	files, err := r.RenderSliceFiles(slice)
+	require.NoError(t, err)
+
+	var smfcm struct {
+		Data struct {
+			SMFCfgYAML string `yaml:"smfcfg.yaml"`
+		}
+	}
+	require.NoError(t, yaml.Unmarshal(files["smf-configmap.yaml"], &smfcm))
+	var smfcfg struct {
+		Logger struct{ Level string }
+		Global struct{ Max struct{ UE int } }
+		SMF    struct {
+			DNS []string
+			MTU int
+		}
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(smfcm.Data.SMFCfgYAML), &smfcfg))
+	assert.Equal(t, "debug", smfcfg.Logger.Level)
+	assert.Equal(t, 64, smfcfg.Global.Max.UE)
+	assert.Equal(t, []string{"1.1.1.1"}, smfcfg.SMF.DNS)
+	assert.Equal(t, 1300, smfcfg.SMF.MTU)
+
+	type deployment struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					NodeSelector map[string]string `yaml:"nodeSelector"`
+					Containers   []struct {
+						Image     string
+						Env       []struct{ Name, Value string }
+						Resources struct {
+							Requests map[string]string
+						}
+					}
+				}
+			}
+		}
+	}
+	var smfdep, upfdep deployment
+	require.NoError(t, yaml.Unmarshal(files["smf-deployment.yaml"], &smfdep))
+	require.NoError(t, yaml.Unmarshal(files["upf-deployment.yaml"], &upfdep))
+
+	smfc := smfdep.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "ghcr.io/niloysh/open5gs:v2.7.0-upf-metrics-v2", smfc.Image)
+	assert.Contains(t, smfc.Env, struct{ Name, Value string }{"FOO", "true"})
+	assert.Empty(t, smfdep.Spec.Template.Spec.NodeSelector)
+
+	upfc := upfdep.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "example.com/open5gs:test", upfc.Image)
+	assert.Equal(t, map[string]string{"cpu": "1", "memory": "1Gi"}, upfc.Resources.Requests)
+	assert.Equal(t, map[string]string{"edge": "true"}, upfdep.Spec.Template.Spec.NodeSelector)
+}
@@ -0,0 +1,353 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slicer/model"
+	"slicer/util"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// 内嵌的默认模板
+//
+//go:embed template/*.tpl
+var embeddedTemplates embed.FS
+
+const (
+	embeddedDir = "template"
+	tplSuffix   = ".tpl"
+
+	SourceEmbedded = "embedded" // 模板来自内嵌文件
+	SourceOverride = "override" // 模板来自覆盖目录
+
+	// 覆盖目录变化后等待的时间, 合并编辑器保存时产生的多个事件
+	reloadDebounce = 500 * time.Millisecond
+)
+
+// TemplateInfo 模板的版本信息
+type TemplateInfo struct {
+	Name    string    `json:"name"`
+	Source  string    `json:"source"`            // embedded或override
+	Path    string    `json:"path,omitempty"`    // 覆盖目录中的文件路径
+	SHA256  string    `json:"sha256"`            // 模板内容的sha256
+	Size    int       `json:"size"`              // 模板大小(字节)
+	ModTime time.Time `json:"mod_time,omitzero"` // 覆盖文件的修改时间
+	DryRun  bool      `json:"dry_run"`           // 是否经过试渲染校验
+}
+
+// TemplateStatus 当前生效的模板集合
+type TemplateStatus struct {
+	Version   string         `json:"version"`              // 所有模板内容的摘要
+	LoadedAt  time.Time      `json:"loaded_at"`            // 加载时间
+	Override  string         `json:"override,omitempty"`   // 覆盖目录
+	Reloads   int            `json:"reloads"`              // 热加载成功次数
+	LastError string         `json:"last_error,omitempty"` // 最近一次加载失败的原因, 此时仍使用之前的模板
+	Templates []TemplateInfo `json:"templates"`
+}
+
+// templateSet 一次加载得到的模板集合, 加载后不再修改
+type templateSet struct {
+	templates map[string]*template.Template
+	infos     []TemplateInfo
+	version   string
+	loadedAt  time.Time
+}
+
+// loadTemplates 读取内嵌模板及覆盖目录中的模板, 解析并试渲染
+// 覆盖目录中的同名文件替换内嵌模板, 任一模板有误则整体失败
+func loadTemplates(overrideDir string) (*templateSet, error) {
+	sources := make(map[string]TemplateInfo)
+	contents := make(map[string][]byte)
+
+	// 内嵌模板
+	entries, err := fs.ReadDir(embeddedTemplates, embeddedDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取内嵌模板失败: %w", err)
+	}
+	for _, entry := range entries {
+		content, err := fs.ReadFile(embeddedTemplates, embeddedDir+"/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("读取内嵌模板 %s 失败: %w", entry.Name(), err)
+		}
+		contents[entry.Name()] = content
+		sources[entry.Name()] = TemplateInfo{Name: entry.Name(), Source: SourceEmbedded}
+	}
+
+	// 覆盖目录
+	if overrideDir != "" {
+		entries, err := os.ReadDir(overrideDir)
+		if err != nil {
+			return nil, fmt.Errorf("读取模板覆盖目录 %s 失败: %w", overrideDir, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), tplSuffix) {
+				continue
+			}
+			path := filepath.Join(overrideDir, entry.Name())
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("读取模板文件 %s 失败: %w", path, err)
+			}
+			// ConfigMap挂载时文件是指向..data的符号链接, 取目标文件的修改时间
+			fi, err := os.Stat(path)
+			if err != nil {
+				return nil, fmt.Errorf("读取模板文件 %s 失败: %w", path, err)
+			}
+			contents[entry.Name()] = content
+			sources[entry.Name()] = TemplateInfo{Name: entry.Name(), Source: SourceOverride, Path: path, ModTime: fi.ModTime()}
+		}
+	}
+
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	set := &templateSet{
+		templates: make(map[string]*template.Template, len(names)),
+		loadedAt:  time.Now(),
+	}
+	digest := sha256.New()
+	for _, name := range names {
+		content := contents[name]
+
+		tmpl, err := template.New(name).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("解析模板失败[%s]: %w", name, err)
+		}
+
+		info := sources[name]
+		sum := sha256.Sum256(content)
+		info.SHA256 = hex.EncodeToString(sum[:])
+		info.Size = len(content)
+		if value, ok := sampleValues[name]; ok {
+			if err := dryRun(tmpl, value); err != nil {
+				return nil, fmt.Errorf("试渲染模板失败[%s]: %w", name, err)
+			}
+			info.DryRun = true
+		}
+
+		set.templates[name] = tmpl
+		set.infos = append(set.infos, info)
+		fmt.Fprintf(digest, "%s:%s\n", name, info.SHA256)
+	}
+	set.version = hex.EncodeToString(digest.Sum(nil))[:12]
+
+	// 检查必需的模板都存在
+	for name := range sampleValues {
+		if _, ok := set.templates[name]; !ok {
+			return nil, fmt.Errorf("缺少模板: %s", name)
+		}
+	}
+	return set, nil
+}
+
+// dryRun 使用示例值渲染模板, 并检查输出是合法的YAML
+func dryRun(tmpl *template.Template, value any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, value); err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(&buf)
+	for {
+		var doc any
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("输出不是合法的YAML: %w", err)
+		}
+	}
+}
+
+// Templates 返回当前生效模板的版本信息
+func (r *Render) Templates() TemplateStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return TemplateStatus{
+		Version:   r.set.version,
+		LoadedAt:  r.set.loadedAt,
+		Override:  r.config.TemplatePath,
+		Reloads:   r.reloads,
+		LastError: r.lastError,
+		Templates: r.set.infos,
+	}
+}
+
+// modifiedTemplates 返回内容与内嵌模板不同的覆盖模板, 键为模板文件名
+func (r *Render) modifiedTemplates() map[string]bool {
+	modified := make(map[string]bool)
+	for _, info := range r.Templates().Templates {
+		if info.Source != SourceOverride {
+			continue
+		}
+		content, err := fs.ReadFile(embeddedTemplates, embeddedDir+"/"+info.Name)
+		sum := sha256.Sum256(content)
+		if err != nil || hex.EncodeToString(sum[:]) != info.SHA256 {
+			modified[info.Name] = true
+		}
+	}
+	return modified
+}
+
+// Reload 重新加载模板, 失败时保留之前的模板
+func (r *Render) Reload() error {
+	_, err := r.reload()
+	return err
+}
+
+// reload 重新加载模板, 内容与当前模板相同时不替换, 返回模板是否变化
+func (r *Render) reload() (bool, error) {
+	set, err := loadTemplates(r.config.TemplatePath)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.lastError = err.Error()
+		return false, err
+	}
+	r.lastError = ""
+	if set.version == r.set.version {
+		return false, nil
+	}
+	r.set = set
+	r.reloads++
+	return true, nil
+}
+
+// Watch 监听覆盖目录, 文件变化时自动重新加载模板
+// 未配置覆盖目录时不做任何事
+func (r *Render) Watch() error {
+	if r.config.TemplatePath == "" {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("创建模板目录监听失败: %w", err)
+	}
+	if err := watcher.Add(r.config.TemplatePath); err != nil {
+		watcher.Close()
+		return fmt.Errorf("监听模板目录 %s 失败: %w", r.config.TemplatePath, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+
+	go r.watch(ctx, watcher)
+	return nil
+}
+
+func (r *Render) watch(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	// 合并短时间内的多个事件
+	timer := time.NewTimer(reloadDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// ConfigMap挂载的目录通过替换..data符号链接原子更新, 事件不在.tpl文件上,
+			// 因此目录中的任何变化都触发重新加载, 由reload比较内容
+			slog.Debug("模板目录变化", "file", event.Name, "op", event.Op.String())
+			timer.Reset(reloadDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("监听模板目录出错", "error", err)
+		case <-timer.C:
+			changed, err := r.reload()
+			if err != nil {
+				slog.Error("重新加载模板失败, 继续使用之前的模板", "error", err)
+				continue
+			}
+			if !changed {
+				slog.Debug("模板内容无变化")
+				continue
+			}
+			slog.Info("重新加载模板成功", "version", r.Templates().Version)
+		}
+	}
+}
+
+// Stop 停止监听覆盖目录
+func (r *Render) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+}
+
+// sampleSlice 试渲染使用的示例切片
+var sampleSlice = model.SliceAndAddress{
+	Slice: model.Slice{
+		SST:      1,
+		SD:       "000001",
+		Sessions: []model.Session{{Name: "internet"}},
+		// 覆盖所有可选分支
+		NF: &model.NFParams{
+			SMF: model.NFParam{NodeSelector: map[string]string{"kubernetes.io/hostname": "node1"}, Env: map[string]string{"K": "v"}},
+			UPF: model.NFParam{DNS: []string{"8.8.8.8"}, NodeSelector: map[string]string{"kubernetes.io/hostname": "node1"}, Env: map[string]string{"K": "v"}},
+		},
+	},
+	AddressValue: model.AddressValue{
+		SessionSubnets: []string{"10.40.0.0/16"},
+		UPFN3Addr:      "10.10.3.1/24",
+		UPFN4Addr:      "10.10.4.1/24",
+		SMFN3Addr:      "10.10.3.2/24",
+		SMFN4Addr:      "10.10.4.2/24",
+	},
+}
+
+// sampleConfig 试渲染使用的配置, 会话配置N6网络、设置集群网段以覆盖相应分支
+var sampleConfig = util.Config{
+	IPAMConfig: util.IPAMConfig{N6Networks: map[string]string{"internet": "eth2"}},
+	KubeConfig: util.KubeConfig{ClusterCIDRs: []string{"10.244.0.0/16"}},
+}
+
+// sampleValues 必需模板及其试渲染使用的值
+var sampleValues = func() map[string]any {
+	sv, _, smfcv, smfdv, smfsv, upfcv, upfdv := sliceToValue(sampleSlice, sampleConfig)
+	return map[string]any{
+		"smf-configmap.yaml.tpl":          smfcv,
+		"smf-deployment.yaml.tpl":         smfdv,
+		"smf-service.yaml.tpl":            smfsv,
+		"upf-configmap.yaml.tpl":          upfcv,
+		"upf-deployment.yaml.tpl":         upfdv,
+		"smf-networkpolicy.yaml.tpl":      sv,
+		"upf-networkpolicy.yaml.tpl":      sv,
+		"kpi_calculator.yaml.tpl":         KpiCalc{SliceID: "1-000001", ThanosURL: "http://thanos:9090"},
+		"metrics-service.yaml.tpl":        MdeValue{SliceID: "1-000001", Interval: 1},
+		"metrics-servicemonitor.yaml.tpl": MdeValue{SliceID: "1-000001", Interval: 1},
+	}
+}()
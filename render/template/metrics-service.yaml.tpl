@@ -2,7 +2,7 @@ apiVersion: v1
 kind: Service
 metadata:
   name: amf{{ .SliceID }}-metrics-service
-  namespace: open5gs
+  namespace: {{ or .SharedNamespace "open5gs" }}
   labels:
     nf: amf
     {{- if ne .SliceID "" }}
@@ -25,7 +25,7 @@ apiVersion: v1
 kind: Service
 metadata:
   name: smf{{ .SliceID }}-metrics-service
-  namespace: open5gs
+  namespace: {{ or .Namespace "open5gs" }}
   labels:
     nf: smf
     {{- if ne .SliceID "" }}
@@ -52,7 +52,7 @@ apiVersion: v1
 kind: Service
 metadata:
   name: upf{{ .SliceID }}-metrics-service
-  namespace: open5gs
+  namespace: {{ or .Namespace "open5gs" }}
   labels:
     nf: upf
     {{- if ne .SliceID "" }}
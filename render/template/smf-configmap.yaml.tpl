@@ -10,31 +10,34 @@ metadata:
 data:
   smfcfg.yaml: |
     logger:
-      file: /open5gs/install/var/log/open5gs/smf.log
+      file: {{.SMF.LogFile}}
+      level: {{.SMF.LogLevel}}
 
     global:
       max:
-        ue: 1024
+        ue: {{.SMF.MaxUE}}
 
     smf:
       sbi:
         server:
           - dev: eth0
-            advertise: smf{{.ID}}-nsmf
+            advertise: {{.Host (printf "smf%s-nsmf" .ID)}}
             port: 80
         client:
           scp:
-            - uri: http://scp-nscp:80
+            - uri: http://{{.SharedHost "scp-nscp"}}:80
       pfcp:
         server:
           - dev: n4
         client:
           upf:
-            - address: {{.UPFN4AddrIP}}
+          {{- range $addr := .UPFN4AddrIPs }}
+            - address: {{ $addr }}
               dnn:
-              {{- range .SessionValues }}
+              {{- range $.SessionValues }}
                 - {{.DNN}}
               {{- end}}
+          {{- end }}
       gtpc:
         server:
           - dev: eth0
@@ -51,9 +54,10 @@ data:
           gateway: {{.Gateway}}
       {{- end }}
       dns:
-        - 8.8.8.8
-        - 8.8.4.4
-      mtu: 1400
+      {{- range .SMF.DNS }}
+        - {{.}}
+      {{- end }}
+      mtu: {{.SMF.MTU}}
       ctf:
         enabled: auto
       freeDiameter: /open5gs/install/etc/freeDiameter/smf.conf
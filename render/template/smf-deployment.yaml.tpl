@@ -24,18 +24,24 @@ spec:
         name: smf{{.ID}}
       annotations:
         k8s.v1.cni.cncf.io/networks: '[
-          { "name": "n4network", "interface": "n4", "ips": [ "{{.N4Addr}}" ] },
-          { "name": "n3network", "interface": "n3", "ips": [ "{{.N3Addr}}" ] }
+          { "name": "n4network", {{with .SharedNamespace}}"namespace": "{{.}}", {{end}}"interface": "n4", "ips": [ "{{.N4Addr}}" ] },
+          { "name": "n3network", {{with .SharedNamespace}}"namespace": "{{.}}", {{end}}"interface": "n3", "ips": [ "{{.N3Addr}}" ] }
           ]'
     spec:
       # nodeSelector:
       #   kubernetes.io/hostname: cn231
+      {{- with .SMF.NodeSelector }}
+      nodeSelector:
+      {{- range $k, $v := . }}
+        {{ printf "%q" $k }}: {{ printf "%q" $v }}
+      {{- end }}
+      {{- end }}
       initContainers:
         - name: wait-ausf
           image: busybox:1.32.0
           env:
             - name: DEPENDENCIES
-              value: ausf-nausf:80
+              value: {{.SharedHost "ausf-nausf"}}:80
           command:
             [
               "sh",
@@ -43,7 +49,7 @@ spec:
               "until nc -z $DEPENDENCIES; do echo waiting for the AUSF; sleep 2; done;",
             ]
       containers:
-        - image: ghcr.io/niloysh/open5gs:v2.7.0-upf-metrics-v2
+        - image: {{.SMF.Image}}
           name: smf{{.ID}}
           imagePullPolicy: IfNotPresent
           ports:
@@ -57,6 +63,10 @@ spec:
           env:
             - name: GIN_MODE
               value: release
+            {{- range $k, $v := .SMF.Env }}
+            - name: {{ $k }}
+              value: {{ printf "%q" $v }}
+            {{- end }}
           volumeMounts:
             - mountPath: /open5gs/config/
               name: smf{{.ID}}-volume
@@ -65,11 +75,11 @@ spec:
               add: ["NET_ADMIN"]
           resources:
             requests:
-              memory: "256Mi"
-              cpu: "200m"
+              memory: {{ printf "%q" .SMF.Resources.MemoryRequest }}
+              cpu: {{ printf "%q" .SMF.Resources.CPURequest }}
             limits:
-              memory: "512Mi"
-              cpu: "500m"
+              memory: {{ printf "%q" .SMF.Resources.MemoryLimit }}
+              cpu: {{ printf "%q" .SMF.Resources.CPULimit }}
       dnsPolicy: ClusterFirst
       restartPolicy: Always
       volumes:
@@ -0,0 +1,94 @@
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: smf{{.ID}}-netpol
+  labels:
+    app: open5gs
+    nf: smf
+    slice: {{.ID}}
+    name: smf{{.ID}}
+spec:
+  podSelector:
+    matchLabels:
+      app: open5gs
+      nf: smf
+      slice: {{.ID}}
+  policyTypes:
+    - Ingress
+    - Egress
+  ingress:
+    # SBI/GTP-C/Diameter, 只允许共享NF(不属于任何切片的Open5GS组件)访问
+    - from:
+        - podSelector:
+            matchLabels:
+              app: open5gs
+            matchExpressions:
+              - key: slice
+                operator: DoesNotExist
+          {{- with .SharedNamespace }}
+          namespaceSelector:
+            matchLabels:
+              kubernetes.io/metadata.name: {{.}}
+          {{- end }}
+      ports:
+        - port: 80
+          protocol: TCP
+        - port: 2123
+          protocol: UDP
+        - port: 3868
+          protocol: TCP
+        - port: 5868
+          protocol: TCP
+    # N4(PFCP), 只允许本切片的UPF
+    - from:
+        - podSelector:
+            matchLabels:
+              app: open5gs
+              nf: upf
+              slice: {{.ID}}
+      ports:
+        - port: 8805
+          protocol: UDP
+    # 监控指标
+    - ports:
+        - port: 9090
+          protocol: TCP
+  egress:
+    # SBI/GTP-C/Diameter, 访问共享NF(NRF、SCP、PCF等)
+    - to:
+        - podSelector:
+            matchLabels:
+              app: open5gs
+            matchExpressions:
+              - key: slice
+                operator: DoesNotExist
+          {{- with .SharedNamespace }}
+          namespaceSelector:
+            matchLabels:
+              kubernetes.io/metadata.name: {{.}}
+          {{- end }}
+      ports:
+        - port: 80
+          protocol: TCP
+        - port: 2123
+          protocol: UDP
+        - port: 3868
+          protocol: TCP
+        - port: 5868
+          protocol: TCP
+    # N4(PFCP), 只允许本切片的UPF
+    - to:
+        - podSelector:
+            matchLabels:
+              app: open5gs
+              nf: upf
+              slice: {{.ID}}
+      ports:
+        - port: 8805
+          protocol: UDP
+    # DNS, 解析共享NF的服务名
+    - ports:
+        - port: 53
+          protocol: UDP
+        - port: 53
+          protocol: TCP
@@ -10,12 +10,12 @@ metadata:
 data:
   upfcfg.yaml: |
     logger:
-        file: /open5gs/install/var/log/open5gs/upf.log
-        level: info
+        file: {{.UPF.LogFile}}
+        level: {{.UPF.LogLevel}}
 
     global:
       max:
-        ue: 1024
+        ue: {{.UPF.MaxUE}}
 
     upf:
       pfcp:
@@ -39,12 +39,29 @@ data:
     #!/bin/bash   
 
     sysctl -w net.ipv6.conf.all.disable_ipv6=1;
+    {{- if .Replicas.Scaled }}
+    # 多副本时按StatefulSet的Pod序号配置N3/N4地址
+    ORDINAL=${HOSTNAME##*-};
+    N3_ADDRS=({{ .Replicas.N3Addrs }});
+    N4_ADDRS=({{ .Replicas.N4Addrs }});
+    ip addr add ${N3_ADDRS[$ORDINAL]} dev n3;
+    ip addr add ${N4_ADDRS[$ORDINAL]} dev n4;
+    {{- end }}
     sh -c "echo 1 > /proc/sys/net/ipv4/ip_forward";
     ip tuntap add name ogstun mode tun;
     {{- range .SessionValues }}
     ip addr add {{.GatewayWithCIDR}} dev ogstun;
+    {{- if .N6Interface }}
+    # 会话流量经N6网络离开UPF, 网关为数据网络DHCP分配的默认网关
+    N6_GW=$(ip -4 route show dev {{.N6Interface}} | awk '/^default/ {print $3; exit}');
+    ip rule add from {{.Subnet}} lookup {{.N6Table}};
+    ip route add default ${N6_GW:+via $N6_GW} dev {{.N6Interface}} table {{.N6Table}};
+    iptables -t nat -A POSTROUTING -s {{.Subnet}} -o {{.N6Interface}} -j MASQUERADE;
+    {{- else }}
     iptables -t nat -A POSTROUTING -s {{.Subnet}} ! -o ogstun -j MASQUERADE;
+    {{- end }}
     {{- end}}
+    ip link set ogstun mtu {{.UPF.MTU}};
     ip link set ogstun up;
 
     /open5gs/install/bin/open5gs-upfd -c /open5gs/config/upfcfg.yaml
\ No newline at end of file
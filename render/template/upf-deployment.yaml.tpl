@@ -1,5 +1,5 @@
 apiVersion: apps/v1
-kind: Deployment
+kind: {{ if .Replicas.Scaled }}StatefulSet{{ else }}Deployment{{ end }}
 metadata:
   name: open5gs-upf{{.ID}}
   labels:
@@ -14,7 +14,14 @@ spec:
       nf: upf
       slice: {{.ID}}
       name: upf{{.ID}}
+  {{- if .Replicas.Scaled }}
+  # 每个副本的N3/N4地址由wrapper.sh按Pod序号配置
+  # 副本数由Play设置或由HPA管理, 模板中不指定, 避免重新交付切片时覆盖
+  serviceName: upf{{.ID}}
+  podManagementPolicy: Parallel
+  {{- else }}
   replicas: 1
+  {{- end }}
   template:
     metadata:
       labels:
@@ -23,13 +30,29 @@ spec:
         slice: {{.ID}}
         name: upf{{.ID}}
       annotations:
+        {{- if .Replicas.Scaled }}
+        # 不带IPAM的网络, Multus只创建接口
         k8s.v1.cni.cncf.io/networks: '[
-          { "name": "n3network", "interface": "n3", "ips": [ "{{.N3Addr}}" ] },
-          { "name": "n4network", "interface": "n4", "ips": [ "{{.N4Addr}}" ] }
+          { "name": "n3network-noipam", {{with .SharedNamespace}}"namespace": "{{.}}", {{end}}"interface": "n3" },
+          { "name": "n4network-noipam", {{with .SharedNamespace}}"namespace": "{{.}}", {{end}}"interface": "n4" }{{ range .N6 }},
+          { "name": "{{.N6Network}}", {{with $.SharedNamespace}}"namespace": "{{.}}", {{end}}"interface": "{{.N6Interface}}" }{{ end }}
           ]'
+        {{- else }}
+        k8s.v1.cni.cncf.io/networks: '[
+          { "name": "n3network", {{with .SharedNamespace}}"namespace": "{{.}}", {{end}}"interface": "n3", "ips": [ "{{.N3Addr}}" ] },
+          { "name": "n4network", {{with .SharedNamespace}}"namespace": "{{.}}", {{end}}"interface": "n4", "ips": [ "{{.N4Addr}}" ] }{{ range .N6 }},
+          { "name": "{{.N6Network}}", {{with $.SharedNamespace}}"namespace": "{{.}}", {{end}}"interface": "{{.N6Interface}}" }{{ end }}
+          ]'
+        {{- end }}
     spec:
       # nodeSelector:
       #   kubernetes.io/hostname: cn231
+      {{- with .UPF.NodeSelector }}
+      nodeSelector:
+      {{- range $k, $v := . }}
+        {{ printf "%q" $k }}: {{ printf "%q" $v }}
+      {{- end }}
+      {{- end }}
       initContainers:
         - name: wait-smf
           image: busybox:1.32.0
@@ -44,9 +67,16 @@ spec:
             ]
       containers:
         - name: upf
-          image: ghcr.io/niloysh/open5gs:v2.7.0-upf-metrics-v2
+          image: {{.UPF.Image}}
           imagePullPolicy: IfNotPresent
           command: ["/open5gs/config/wrapper.sh"]
+          {{- with .UPF.Env }}
+          env:
+          {{- range $k, $v := . }}
+            - name: {{ $k }}
+              value: {{ printf "%q" $v }}
+          {{- end }}
+          {{- end }}
           volumeMounts:
             - mountPath: /open5gs/config/
               name: upf{{.ID}}-volume
@@ -56,13 +86,20 @@ spec:
               protocol: UDP
           resources:
             requests:
-              memory: "256Mi"
-              cpu: "200m"
+              memory: {{ printf "%q" .UPF.Resources.MemoryRequest }}
+              cpu: {{ printf "%q" .UPF.Resources.CPURequest }}
             limits:
-              memory: "512Mi"
-              cpu: "500m"
+              memory: {{ printf "%q" .UPF.Resources.MemoryLimit }}
+              cpu: {{ printf "%q" .UPF.Resources.CPULimit }}
           securityContext:
             privileged: true
+      {{- with .UPF.DNS }}
+      dnsConfig:
+        nameservers:
+        {{- range . }}
+          - {{ . }}
+        {{- end }}
+      {{- end }}
       restartPolicy: Always
       volumes:
         - name: upf{{.ID}}-volume
@@ -0,0 +1,62 @@
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: upf{{.ID}}-netpol
+  labels:
+    app: open5gs
+    nf: upf
+    slice: {{.ID}}
+    name: upf{{.ID}}
+spec:
+  podSelector:
+    matchLabels:
+      app: open5gs
+      nf: upf
+      slice: {{.ID}}
+  policyTypes:
+    - Ingress
+    - Egress
+  ingress:
+    # N4(PFCP), 只允许本切片的SMF
+    - from:
+        - podSelector:
+            matchLabels:
+              app: open5gs
+              nf: smf
+              slice: {{.ID}}
+      ports:
+        - port: 8805
+          protocol: UDP
+    # N3(GTP-U), 来自基站
+    - ports:
+        - port: 2152
+          protocol: UDP
+    # 监控指标
+    - ports:
+        - port: 9090
+          protocol: TCP
+  egress:
+    # N4(PFCP), 只允许本切片的SMF
+    - to:
+        - podSelector:
+            matchLabels:
+              app: open5gs
+              nf: smf
+              slice: {{.ID}}
+      ports:
+        - port: 8805
+          protocol: UDP
+    # N3(GTP-U), 发往基站的下行数据
+    - ports:
+        - port: 2152
+          protocol: UDP
+    # N6, 访问外部数据网络, 排除集群的Pod及Service网段
+    - to:
+        - ipBlock:
+            cidr: 0.0.0.0/0
+            {{- with .ClusterCIDRs }}
+            except:
+              {{- range . }}
+              - {{ . }}
+              {{- end }}
+            {{- end }}
@@ -0,0 +1,79 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"slicer/util"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplatesOverrideAndReload(t *testing.T) {
+	// 仅使用内嵌模板
+	r, err := NewRender(util.Config{})
+	require.NoError(t, err)
+	status := r.Templates()
+	require.Len(t, status.Templates, len(sampleValues))
+	for _, info := range status.Templates {
+		require.Equal(t, SourceEmbedded, info.Source)
+		require.True(t, info.DryRun)
+	}
+
+	// 覆盖目录中的同名模板替换内嵌模板
+	dir := t.TempDir()
+	svc := "apiVersion: v1\nkind: Service\nmetadata:\n  name: smf{{ .ID }}-nsmf\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "smf-service.yaml.tpl"), []byte(svc), 0o644))
+	r, err = NewRender(util.Config{TemplatePath: dir})
+	require.NoError(t, err)
+	files, err := r.RenderSliceFiles(sampleSlice)
+	require.NoError(t, err)
+	require.Contains(t, string(files["smf-service.yaml"]), "name: smf1-000001-nsmf")
+	version := r.Templates().Version
+	require.NotEqual(t, status.Version, version)
+
+	// 有误的模板不生效, 保留之前的模板
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "smf-service.yaml.tpl"), []byte("{{ .NoSuchField }}"), 0o644))
+	require.Error(t, r.Reload())
+	require.Equal(t, version, r.Templates().Version)
+	require.NotEmpty(t, r.Templates().LastError)
+
+	// 覆盖目录有误时启动失败
+	_, err = NewRender(util.Config{TemplatePath: dir})
+	require.Error(t, err)
+}
+
+func TestWatchConfigMapMount(t *testing.T) {
+	// 与kubelet挂载ConfigMap相同的结构: 文件指向..data, ..data指向带时间戳的目录, 更新时替换..data
+	dir := t.TempDir()
+	svc := "apiVersion: v1\nkind: Service\nmetadata:\n  name: smf{{ .ID }}-%s\n"
+	writeVersion := func(version string) {
+		data := filepath.Join(dir, "..v"+version)
+		require.NoError(t, os.Mkdir(data, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(data, "smf-service.yaml.tpl"), []byte(fmt.Sprintf(svc, version)), 0o644))
+		require.NoError(t, os.Symlink("..v"+version, filepath.Join(dir, "..data_tmp")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	}
+	writeVersion("1")
+	require.NoError(t, os.Symlink("..data/smf-service.yaml.tpl", filepath.Join(dir, "smf-service.yaml.tpl")))
+
+	r, err := NewRender(util.Config{TemplatePath: dir})
+	require.NoError(t, err)
+	require.NoError(t, r.Watch())
+	defer r.Stop()
+
+	writeVersion("2")
+	require.Eventually(t, func() bool {
+		files, err := r.RenderSliceFiles(sampleSlice)
+		return err == nil && strings.Contains(string(files["smf-service.yaml"]), "smf1-000001-2")
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Equal(t, 1, r.Templates().Reloads)
+
+	// 内容未变化时不重新加载
+	require.NoError(t, r.Reload())
+	require.Equal(t, 1, r.Templates().Reloads)
+}
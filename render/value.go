@@ -3,6 +3,8 @@ package render
 import (
 	"fmt"
 	"net"
+	"slicer/model"
+	"strings"
 )
 
 type KpiCalc struct {
@@ -13,19 +15,115 @@ type KpiCalc struct {
 type MdeValue struct {
 	SliceID  string // 切片ID
 	Interval uint8  // 采集间隔
+
+	// 切片独立命名空间时设置, AMF的服务在共享命名空间, SMF和UPF的服务在切片命名空间
+	Namespace       string
+	SharedNamespace string
 }
 
 type SliceValue struct {
 	ID  string // 切片ID= SST-SD
 	SST string
 	SD  string
+
+	SMF NFValue // SMF参数
+	UPF NFValue // UPF参数
+
+	// 切片独立命名空间时设置, 为空时所有资源与共享NF同在一个命名空间, 模板输出不变
+	Namespace       string // 切片资源所在的命名空间
+	SharedNamespace string // 共享NF(AMF、SCP等)及NAD所在的命名空间
+
+	ClusterCIDRs []string // 集群的Pod及Service网段, UPF的N6出站规则排除这些网段
+}
+
+// SharedHost 共享NF服务的地址, 切片独立命名空间时使用跨命名空间的全名
+func (v SliceValue) SharedHost(service string) string {
+	if v.SharedNamespace == "" {
+		return service
+	}
+	return service + "." + v.SharedNamespace + ".svc"
+}
+
+// Host 切片自身服务的地址, 供共享NF跨命名空间访问
+func (v SliceValue) Host(service string) string {
+	if v.Namespace == "" {
+		return service
+	}
+	return service + "." + v.Namespace + ".svc"
+}
+
+// NFValue 网络功能参数, 已填充默认值
+type NFValue struct {
+	Image        string
+	DNS          []string
+	MTU          int
+	MaxUE        int
+	LogLevel     string
+	LogFile      string
+	NodeSelector map[string]string // 模板中range按key排序
+	Env          map[string]string // 模板中range按key排序
+	Resources    model.ResourceSpec
+}
+
+// newNFValue 使用切片参数覆盖默认值, nf为smf或upf
+func newNFValue(nf string, param model.NFParam) NFValue {
+	v := NFValue{
+		Image:    "ghcr.io/niloysh/open5gs:v2.7.0-upf-metrics-v2",
+		MTU:      1400,
+		MaxUE:    1024,
+		LogLevel: "info",
+		LogFile:  fmt.Sprintf("/open5gs/install/var/log/open5gs/%s.log", nf),
+		Resources: model.ResourceSpec{
+			CPURequest:    "200m",
+			CPULimit:      "500m",
+			MemoryRequest: "256Mi",
+			MemoryLimit:   "512Mi",
+		},
+		NodeSelector: param.NodeSelector,
+		Env:          param.Env,
+	}
+	// UE的DNS默认值只用于SMF
+	if nf == "smf" {
+		v.DNS = []string{"8.8.8.8", "8.8.4.4"}
+	}
+
+	if param.Image != "" {
+		v.Image = param.Image
+	}
+	if len(param.DNS) > 0 {
+		v.DNS = param.DNS
+	}
+	if param.MTU != 0 {
+		v.MTU = param.MTU
+	}
+	if param.MaxUE != 0 {
+		v.MaxUE = param.MaxUE
+	}
+	if param.LogLevel != "" {
+		v.LogLevel = param.LogLevel
+	}
+	if param.LogFile != "" {
+		v.LogFile = param.LogFile
+	}
+	if param.Resources != nil {
+		v.Resources = *param.Resources
+	}
+	return v
 }
 
 type SessionValue struct {
 	Subnet string // 10.41.0.0/16
 	DNN    string
+
+	// DNN配置了N6网络时UPF经该网络的接口转发会话流量, 否则为空, 流量经eth0 NAT
+	N6Network   string // NAD名称 n6network-<dnn>
+	N6Interface string // UPF中的接口名称 n6-<dnn>
+	N6Table     int    // 会话子网策略路由使用的路由表
 }
 
+// n6RouteTableBase N6策略路由表的起始编号, 按会话序号递增
+const n6RouteTableBase = 100
+
 func (s *SessionValue) Gateway() string { // 10.41.0.1
 	_, ipnet, _ := net.ParseCIDR(s.Subnet)
 	ip := ipnet.IP.To4()
@@ -45,7 +143,8 @@ type SessionValues = []SessionValue
 
 type SmfConfigmapValue struct {
 	SliceValue
-	UPFN4AddrIP string
+	UPFN4AddrIP  string   // 第0个UPF副本的N4地址, 兼容旧模板
+	UPFN4AddrIPs []string // 所有UPF副本的N4地址
 	SessionValues
 }
 
@@ -62,10 +161,39 @@ type SmfServiceValue struct {
 type UpfConfigmapValue struct {
 	SliceValue
 	SessionValues
+	Replicas UPFReplicas
 }
 
 type UpfDeploymentValue struct {
 	SliceValue
-	N4Addr string
-	N3Addr string
+	N4Addr   string
+	N3Addr   string
+	Replicas UPFReplicas
+	N6       SessionValues // 配置了N6网络的会话, UPF为每个会话接入对应的N6网络
+}
+
+// UPFReplicas 所有UPF副本的地址
+// 多于一个副本时UPF以StatefulSet部署, Multus只创建接口, 由wrapper.sh按Pod序号配置地址
+type UPFReplicas []model.ReplicaAddress
+
+func (r UPFReplicas) Scaled() bool {
+	return len(r) > 1
+}
+
+// N3Addrs 按序号排列的N3地址, 以空格分隔, 用于wrapper.sh中的数组
+func (r UPFReplicas) N3Addrs() string {
+	addrs := make([]string, len(r))
+	for i, a := range r {
+		addrs[i] = a.N3Addr
+	}
+	return strings.Join(addrs, " ")
+}
+
+// N4Addrs 按序号排列的N4地址, 以空格分隔
+func (r UPFReplicas) N4Addrs() string {
+	addrs := make([]string, len(r))
+	for i, a := range r {
+		addrs[i] = a.N4Addr
+	}
+	return strings.Join(addrs, " ")
 }
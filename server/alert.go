@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// alertSliceLabel 告警中标识切片的标签
+const alertSliceLabel = "slice_id"
+
+// AlertmanagerWebhook Alertmanager webhook发送的告警组, 只列出用到的字段
+// 参见 https://prometheus.io/docs/alerting/latest/configuration/#webhook_config
+type AlertmanagerWebhook struct {
+	Version      string            `json:"version"`
+	GroupKey     string            `json:"groupKey"`
+	Status       string            `json:"status"` // firing或resolved
+	Receiver     string            `json:"receiver"`
+	CommonLabels map[string]string `json:"commonLabels"`
+	Alerts       []Alert           `json:"alerts"`
+}
+
+// Alert 告警组中的单个告警
+type Alert struct {
+	Status      string            `json:"status"` // firing或resolved
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+	Fingerprint string            `json:"fingerprint"`
+}
+
+// AlertResponse 处理告警的结果
+type AlertResponse struct {
+	// 已加入控制队列的切片
+	Triggered []string `json:"triggered"`
+	// 未触发控制的切片及原因(不在控制器中、未启用或控制器未运行)
+	Skipped map[string]string `json:"skipped,omitempty"`
+}
+
+// receiveAlerts godoc
+// @Summary      接收Alertmanager告警
+// @Description  兼容Alertmanager webhook, 按告警标签slice_id立即控制对应切片, 已恢复(resolved)的告警忽略
+// @Description  在Alertmanager中配置 webhook_configs: [{url: http://<slicer>/controller/alerts}]
+// @Description  无法处理的切片只在响应中说明, 仍返回200, 避免Alertmanager重复发送
+// @Tags         Controller
+// @Accept       json
+// @Produce      json
+// @Param        webhook body AlertmanagerWebhook true "Alertmanager告警组"
+// @Success      200 {object} AlertResponse "已触发控制的切片"
+// @Failure      400 {string} string "请求解码失败"
+// @Router       /controller/alerts [post]
+func (s *Server) receiveAlerts(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("接收告警请求", "method", r.Method, "url", r.URL.String())
+	var webhook AlertmanagerWebhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		slog.Error("解析告警失败", "error", err)
+		http.Error(w, "解析告警失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := AlertResponse{Triggered: []string{}, Skipped: map[string]string{}}
+	for _, alert := range webhook.Alerts {
+		if alert.Status != "firing" {
+			continue
+		}
+		sliceID := alert.Labels[alertSliceLabel]
+		if sliceID == "" {
+			sliceID = webhook.CommonLabels[alertSliceLabel]
+		}
+		if sliceID == "" || slices.Contains(response.Triggered, sliceID) {
+			continue
+		}
+		reason := fmt.Sprintf("alert %s", alert.Labels["alertname"])
+		if err := s.controller.Reconcile(sliceID, reason); err != nil {
+			response.Skipped[sliceID] = err.Error()
+			continue
+		}
+		response.Triggered = append(response.Triggered, sliceID)
+	}
+	slog.Info("处理告警完成", "receiver", webhook.Receiver, "groupKey", webhook.GroupKey, "alerts", len(webhook.Alerts), "triggered", response.Triggered)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "响应编码失败", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"slicer/model"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// archiveSlice 归档切片当前版本: 渲染出的清单, 集群中实际运行的资源, 切片与Play对象
+// 归档失败不影响请求结果, 仅记录日志
+func (s *Server) archiveSlice(slice model.SliceAndAddress) {
+	if s.archive == nil {
+		return
+	}
+	sliceID := slice.SliceID()
+
+	files, err := s.render.RenderSliceFiles(slice)
+	if err != nil {
+		slog.Error("归档时渲染切片失败", "sliceID", sliceID, "error", err)
+		return
+	}
+	revision := make(map[string][]byte, len(files)+3)
+	for name, content := range files {
+		revision[path.Join("manifests", name)] = content
+	}
+
+	var live []byte
+	kclient, err := s.clusters.ForSlice(slice)
+	if err == nil {
+		live, err = kclient.ExportSlice(sliceID, s.config.SliceNamespace(sliceID))
+	}
+	if err != nil {
+		slog.Warn("导出集群资源失败, 跳过live.yaml", "sliceID", sliceID, "error", err)
+	} else {
+		revision["live.yaml"] = live
+	}
+
+	if revision["slice.json"], err = json.MarshalIndent(slice, "", "  "); err != nil {
+		slog.Error("序列化切片失败", "sliceID", sliceID, "error", err)
+		return
+	}
+
+	// 切片可能尚未配置Play
+	if play, err := s.store.GetPlayBySliceID(sliceID); err == nil {
+		if revision["play.json"], err = json.MarshalIndent(play, "", "  "); err != nil {
+			slog.Error("序列化Play失败", "sliceID", sliceID, "error", err)
+			return
+		}
+	}
+
+	rev, err := s.archive.SaveSliceRevision(sliceID, revision)
+	if err != nil {
+		slog.Error("归档切片失败", "sliceID", sliceID, "error", err)
+		return
+	}
+	slog.Debug("归档切片成功", "sliceID", sliceID, "rev", rev)
+}
+
+// ArchiveSlice 实现controller.SliceArchiver, 控制器应用Play后归档切片版本
+func (s *Server) ArchiveSlice(sliceID string) {
+	s.archiveSliceByID(sliceID)
+}
+
+// archiveSliceByID 根据切片ID获取切片后归档
+func (s *Server) archiveSliceByID(sliceID string) {
+	if s.archive == nil {
+		return
+	}
+	slice, err := s.store.GetSliceBySliceID(sliceID)
+	if err != nil {
+		slog.Error("归档时获取切片失败", "sliceID", sliceID, "error", err)
+		return
+	}
+	s.archiveSlice(slice)
+}
+
+// listArchive godoc
+// @Summary      列出归档
+// @Description  列出指定前缀下的归档对象
+// @Description  前缀必须以slices/或decisions/开头, 如slices/1-000001/或decisions/1-000001/
+// @Tags         Archive
+// @Produce      json
+// @Param        prefix query string true "归档前缀"
+// @Success      200 {array} db.ObjectInfo "归档对象列表"
+// @Failure      400 {string} string "前缀非法"
+// @Failure      503 {string} string "未启用归档"
+// @Failure      500 {string} string "获取失败"
+// @Router       /archive [get]
+func (s *Server) listArchive(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("列出归档请求", "method", r.Method, "url", r.URL.String())
+	if s.archive == nil {
+		http.Error(w, "未启用归档", http.StatusServiceUnavailable)
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	if !strings.HasPrefix(prefix, "slices/") && !strings.HasPrefix(prefix, "decisions/") {
+		http.Error(w, fmt.Sprintf("前缀非法: %s", prefix), http.StatusBadRequest)
+		return
+	}
+
+	objects, err := s.archive.List(prefix)
+	if err != nil {
+		slog.Error("列出归档失败", "prefix", prefix, "error", err)
+		http.Error(w, fmt.Sprintf("列出归档失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeResponse(w, objects)
+}
+
+// downloadArchive godoc
+// @Summary      下载归档
+// @Description  下载指定key的归档对象, 如slices/1-000001/3/manifests/upf-deployment.yaml
+// @Tags         Archive
+// @Produce      octet-stream
+// @Param        key path string true "归档key"
+// @Success      200 {file} file "归档内容"
+// @Failure      400 {string} string "key非法"
+// @Failure      503 {string} string "未启用归档"
+// @Failure      500 {string} string "下载失败"
+// @Router       /archive/object/{key} [get]
+func (s *Server) downloadArchive(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("下载归档请求", "method", r.Method, "url", r.URL.String())
+	if s.archive == nil {
+		http.Error(w, "未启用归档", http.StatusServiceUnavailable)
+		return
+	}
+
+	key := chi.URLParam(r, "*")
+	if !strings.HasPrefix(key, "slices/") && !strings.HasPrefix(key, "decisions/") {
+		http.Error(w, fmt.Sprintf("key非法: %s", key), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(key)))
+	if err := s.archive.Download(key, w); err != nil {
+		slog.Error("下载归档失败", "key", key, "error", err)
+		http.Error(w, fmt.Sprintf("下载归档失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+type createBackupResponse struct {
+	Key string `json:"key"`
+}
+
+// createBackup godoc
+// @Summary      创建备份
+// @Description  立即将所有集合及IPAM前缀备份到对象存储
+// @Tags         Backup
+// @Produce      json
+// @Success      200 {object} createBackupResponse "备份成功, 返回备份key"
+// @Failure      503 {string} string "未启用备份"
+// @Failure      500 {string} string "备份失败"
+// @Router       /backup [post]
+func (s *Server) createBackup(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("创建备份请求", "method", r.Method, "url", r.URL.String())
+	if s.backup == nil {
+		http.Error(w, "未启用备份", http.StatusServiceUnavailable)
+		return
+	}
+
+	key, err := s.backup.Create()
+	if err != nil {
+		slog.Error("创建备份失败", "error", err)
+		http.Error(w, fmt.Sprintf("创建备份失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeResponse(w, createBackupResponse{Key: key})
+	slog.Info("创建备份成功", "key", key)
+}
+
+// listBackup godoc
+// @Summary      列出备份
+// @Description  列出对象存储中的所有备份, 按时间从旧到新排序
+// @Tags         Backup
+// @Produce      json
+// @Success      200 {array} db.ObjectInfo "备份列表"
+// @Failure      503 {string} string "未启用备份"
+// @Failure      500 {string} string "获取失败"
+// @Router       /backup [get]
+func (s *Server) listBackup(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("列出备份请求", "method", r.Method, "url", r.URL.String())
+	if s.backup == nil {
+		http.Error(w, "未启用备份", http.StatusServiceUnavailable)
+		return
+	}
+
+	backups, err := s.backup.List()
+	if err != nil {
+		slog.Error("列出备份失败", "error", err)
+		http.Error(w, fmt.Sprintf("列出备份失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeResponse(w, backups)
+}
+
+type restoreBackupRequest struct {
+	// 备份key, 为"latest"时使用最新备份
+	Key string `json:"key"`
+	// 恢复后是否通过交付方式重新交付切片及其Play, 并重新部署监控组件
+	Reapply bool `json:"reapply"`
+}
+
+// restoreBackup godoc
+// @Summary      从备份恢复
+// @Description  使用指定备份重建数据库与IPAM状态, 可选地重新交付切片、Play及监控组件
+// @Tags         Backup
+// @Accept       json
+// @Produce      json
+// @Param        body body restoreBackupRequest true "恢复参数"
+// @Success      200 {object} backup.Manifest "恢复成功, 返回备份描述"
+// @Failure      400 {string} string "请求解码失败"
+// @Failure      503 {string} string "未启用备份"
+// @Failure      500 {string} string "恢复失败"
+// @Router       /backup/restore [post]
+func (s *Server) restoreBackup(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("恢复备份请求", "method", r.Method, "url", r.URL.String())
+	if s.backup == nil {
+		http.Error(w, "未启用备份", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req restoreBackupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Key == "" {
+		slog.Warn("请求解码失败", "error", err)
+		http.Error(w, "请求解码失败", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := s.backup.Restore(req.Key, req.Reapply)
+	if err != nil {
+		slog.Error("恢复备份失败", "key", req.Key, "error", err)
+		http.Error(w, fmt.Sprintf("恢复备份失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// 控制器中的切片设置以恢复后的存储为准, 并按恢复的SLA立即控制
+	if err := s.controller.Reload(); err != nil {
+		slog.Error("重新加载控制器切片失败", "error", err)
+	}
+	for _, sliceID := range s.controller.ListSlices() {
+		s.triggerReconcile(sliceID, "backup restored")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeResponse(w, manifest)
+	slog.Info("恢复备份成功", "key", req.Key, "reapply", req.Reapply)
+}
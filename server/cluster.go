@@ -0,0 +1,57 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slicer/kubeclient"
+)
+
+// clusterResponse 集群状态及部署在其中的切片
+type clusterResponse struct {
+	kubeclient.ClusterStatus
+	Slices []string `json:"slices"` // 部署在该集群的切片ID
+}
+
+// listClusters godoc
+// @Summary      列出集群
+// @Description  返回所有已注册集群的连接状态、站点标签、节点容量及部署在其中的切片
+// @Tags         Cluster
+// @Produce      json
+// @Success      200 {array} clusterResponse "集群列表, 默认集群在前"
+// @Failure      500 {string} string "获取切片列表失败"
+// @Router       /clusters [get]
+func (s *Server) listClusters(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("列出集群请求", "method", r.Method, "url", r.URL.String())
+
+	slices, err := s.store.ListSlice()
+	if err != nil {
+		slog.Error("获取切片列表失败", "error", err)
+		http.Error(w, fmt.Sprintf("获取切片列表失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	sliceIDs := make(map[string][]string)
+	for _, slice := range slices {
+		cluster := slice.Cluster
+		if cluster == "" {
+			cluster = kubeclient.DefaultCluster
+		}
+		sliceIDs[cluster] = append(sliceIDs[cluster], slice.SliceID())
+	}
+
+	statuses := s.clusters.Status()
+	response := make([]clusterResponse, 0, len(statuses))
+	for _, status := range statuses {
+		if !status.Connected {
+			slog.Warn("集群不可用", "cluster", status.Name, "error", status.Error)
+		}
+		ids := sliceIDs[status.Name]
+		if ids == nil {
+			ids = []string{}
+		}
+		response = append(response, clusterResponse{ClusterStatus: status, Slices: ids})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeResponse(w, response)
+}
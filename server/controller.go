@@ -2,9 +2,12 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/go-chi/chi"
+
 	"golang.org/x/exp/slog"
 )
 
@@ -138,3 +141,153 @@ func (s *Server) updateController(w http.ResponseWriter, r *http.Request) {
 	// 返回成功响应
 	w.WriteHeader(http.StatusOK)
 }
+
+// listControlledSlices godoc
+// @Summary      列出控制器中的切片设置
+// @Description  返回每个切片的控制间隔、策略、是否启用及下次控制时间
+// @Tags         Controller
+// @Produce      json
+// @Success      200 {array} controller.SliceSchedule "切片设置列表"
+// @Failure      500 {string} string "响应编码失败"
+// @Router       /controller/slices [get]
+func (s *Server) listControlledSlices(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("列出控制器切片设置请求", "method", r.Method, "url", r.URL.String())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.controller.ListSliceSchedules()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// getControlledSlice godoc
+// @Summary      获取切片的控制设置
+// @Tags         Controller
+// @Produce      json
+// @Param        slice_id path string true "切片ID"
+// @Success      200 {object} controller.SliceSchedule "切片设置"
+// @Failure      404 {string} string "切片不在控制器中"
+// @Failure      500 {string} string "响应编码失败"
+// @Router       /controller/slices/{slice_id} [get]
+func (s *Server) getControlledSlice(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("获取切片控制设置请求", "method", r.Method, "url", r.URL.String())
+	sliceID := chi.URLParam(r, "slice_id")
+	schedule, ok := s.controller.GetSliceSchedule(sliceID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("切片不在控制器中: %v", sliceID), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// 更新切片的控制设置, 未提供的字段保持不变
+type UpdateSliceControlRequest struct {
+	// 控制间隔, 为0时使用控制器的全局频率
+	Interval *time.Duration `json:"interval" swaggertype:"integer" format:"nanoseconds" example:"60000000000"`
+	// 使用的策略名称, 为空时使用控制器的全局策略
+	Strategy *string `json:"strategy"`
+	// 是否控制该切片
+	Enabled *bool `json:"enabled"`
+}
+
+// updateControlledSlice godoc
+// @Summary      更新切片的控制设置
+// @Description  修改单个切片的控制间隔、策略或是否启用, 设置会被保存, 并从当前时间起按新的间隔重新安排
+// @Tags         Controller
+// @Accept       json
+// @Produce      json
+// @Param        slice_id path string true "切片ID"
+// @Param        control body UpdateSliceControlRequest true "切片设置"
+// @Success      200 {object} controller.SliceSchedule "更新后的切片设置"
+// @Failure      400 {string} string "请求解析失败/参数非法/策略不存在"
+// @Failure      404 {string} string "切片不在控制器中"
+// @Router       /controller/slices/{slice_id} [post]
+func (s *Server) updateControlledSlice(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("更新切片控制设置请求", "method", r.Method, "url", r.URL.String())
+	sliceID := chi.URLParam(r, "slice_id")
+	schedule, ok := s.controller.GetSliceSchedule(sliceID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("切片不在控制器中: %v", sliceID), http.StatusNotFound)
+		return
+	}
+
+	var req UpdateSliceControlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("解析请求失败", "error", err)
+		http.Error(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	control := schedule.SliceControl
+	if req.Interval != nil {
+		control.Interval = *req.Interval
+	}
+	if req.Strategy != nil {
+		control.Strategy = *req.Strategy
+	}
+	if req.Enabled != nil {
+		control.Enabled = *req.Enabled
+	}
+
+	schedule, err := s.controller.UpdateSliceControl(control)
+	if err != nil {
+		slog.Warn("更新切片控制设置失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("更新切片控制设置失败: %v", err), http.StatusBadRequest)
+		return
+	}
+	slog.Info("切片控制设置更新", "sliceID", sliceID, "interval", control.Interval, "strategy", control.Strategy, "enabled", control.Enabled)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// reconcileSlice godoc
+// @Summary      立即控制切片
+// @Description  将切片加入控制队列, 不等待下一次调度, 已在队列中时不重复加入; 下一次定时控制从当前时间起重新计算
+// @Tags         Controller
+// @Produce      json
+// @Param        slice_id path string true "切片ID"
+// @Success      202 {object} controller.SliceSchedule "已加入控制队列"
+// @Failure      404 {string} string "切片不在控制器中"
+// @Failure      409 {string} string "切片未启用控制"
+// @Failure      503 {string} string "控制器未运行"
+// @Router       /controller/slices/{slice_id}/reconcile [post]
+func (s *Server) reconcileSlice(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("立即控制切片请求", "method", r.Method, "url", r.URL.String())
+	sliceID := chi.URLParam(r, "slice_id")
+	schedule, ok := s.controller.GetSliceSchedule(sliceID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("切片不在控制器中: %v", sliceID), http.StatusNotFound)
+		return
+	}
+	if !schedule.Enabled {
+		http.Error(w, fmt.Sprintf("切片未启用控制: %v", sliceID), http.StatusConflict)
+		return
+	}
+	if err := s.controller.Reconcile(sliceID, "manual"); err != nil {
+		slog.Warn("立即控制切片失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("立即控制切片失败: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	schedule, _ = s.controller.GetSliceSchedule(sliceID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(schedule); err != nil {
+		slog.Error("响应编码失败", "sliceID", sliceID, "error", err)
+	}
+}
+
+// triggerReconcile 立即控制切片, 用于不经过单条SLA/Play写入的变化(如恢复备份)
+// SLA和Play的写入由存储的变化通知触发, 见db.NotifyStore
+// 切片不在控制器中、未启用或控制器未运行时只记录日志
+func (s *Server) triggerReconcile(sliceID string, reason string) {
+	if err := s.controller.Reconcile(sliceID, reason); err != nil {
+		slog.Debug("未触发切片控制", "sliceID", sliceID, "reason", reason, "error", err)
+	}
+}
@@ -66,7 +66,7 @@ func (s *Server) soGetSliceComponents(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查slice是否存在
-	_, err := s.store.GetSliceBySliceID(sliceID)
+	slice, err := s.store.GetSliceBySliceID(sliceID)
 	if err != nil {
 		if isNotFoundError(err) { // MongoDB为空文档
 			slog.Warn("SO: slice不存在", "sliceID", sliceID)
@@ -79,9 +79,16 @@ func (s *Server) soGetSliceComponents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pods, err := s.kubeclient.GetPods(s.config.Namespace)
+	kclient, err := s.clusters.ForSlice(slice)
 	if err != nil {
-		slog.Error("SO: 获取Pods失败", "namespace", s.config.Namespace, "error", err)
+		slog.Error("SO: 获取切片所在集群失败", "sliceID", sliceID, "cluster", slice.Cluster, "error", err)
+		http.Error(w, fmt.Sprintf("获取切片所在集群失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	namespace := s.config.SliceNamespace(sliceID)
+	pods, err := kclient.GetPods(namespace, "app=open5gs", "slice="+sliceID) // 由informer缓存提供
+	if err != nil {
+		slog.Error("SO: 获取Pods失败", "namespace", namespace, "error", err)
 		http.Error(w, fmt.Sprintf("获取Pods失败: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -201,7 +208,7 @@ func (s *Server) noMdeInstall(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查slice是否存在
-	_, err := s.store.GetSliceBySliceID(req.SliceID)
+	slice, err := s.store.GetSliceBySliceID(req.SliceID)
 	if err != nil {
 		if isNotFoundError(err) { // MongoDB为空文档
 			slog.Warn("NO: slice不存在", "sliceID", req.SliceID)
@@ -222,8 +229,14 @@ func (s *Server) noMdeInstall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 部署mde
-	if err := s.kubeclient.ApplyMDE(yaml); err != nil {
+	// 部署mde, 与切片位于同一集群
+	kclient, err := s.clusters.ForSlice(slice)
+	if err != nil {
+		slog.Error("NO: 连接切片所在集群失败", "sliceID", req.SliceID, "error", err)
+		http.Error(w, fmt.Sprintf("连接切片所在集群失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := kclient.ApplyMDE(yaml); err != nil {
 		slog.Error("NO: 部署MDE失败", "sliceID", req.SliceID, "namespace", s.config.MonitorNamespace, "error", err)
 		http.Error(w, fmt.Sprintf("部署mde失败: %v", err), http.StatusInternalServerError)
 		return
@@ -380,7 +393,7 @@ func (s *Server) noKpiComputationInstall(w http.ResponseWriter, r *http.Request)
 	}
 
 	// 检查slice是否存在
-	_, err := s.store.GetSliceBySliceID(req.SliceID)
+	slice, err := s.store.GetSliceBySliceID(req.SliceID)
 	if err != nil {
 		slog.Error("NO: 获取slice失败", "sliceID", req.SliceID, "error", err)
 		http.Error(w, "获取slice失败", http.StatusInternalServerError)
@@ -395,8 +408,14 @@ func (s *Server) noKpiComputationInstall(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// 部署kpic
-	if err := s.kubeclient.ApplyKpic(yaml); err != nil {
+	// 部署kpic, 与切片位于同一集群
+	kclient, err := s.clusters.ForSlice(slice)
+	if err != nil {
+		slog.Error("NO: 连接切片所在集群失败", "sliceID", req.SliceID, "error", err)
+		http.Error(w, fmt.Sprintf("连接切片所在集群失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := kclient.ApplyKpic(yaml); err != nil {
 		slog.Error("NO: 部署KPI计算组件失败", "sliceID", req.SliceID, "namespace", s.config.MonitorNamespace, "error", err)
 		http.Error(w, fmt.Sprintf("部署mde失败: %v", err), http.StatusInternalServerError)
 		return
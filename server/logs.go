@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slicer/kubeclient"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// flushWriter 每次写入后立即发送给客户端, 用于分块传输日志
+type flushWriter struct {
+	w     http.ResponseWriter
+	wrote bool
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	f.wrote = true
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// getSliceLogs godoc
+// @Summary      获取切片NF的日志
+// @Description  按切片标签找到NF的Pod, 以分块传输的纯文本返回容器日志或Open5GS日志文件(通过exec tail读取)
+// @Description  follow=true时持续输出直到客户端断开; 多个Pod时每行前带有 [Pod名称]
+// @Tags         Slice
+// @Produce      plain
+// @Param        sliceID path string true "切片ID"
+// @Param        nf query string true "smf或upf"
+// @Param        pod query string false "只读取该Pod, 默认NF的所有Pod"
+// @Param        source query string false "container(默认)或file"
+// @Param        follow query bool false "持续输出新日志"
+// @Param        since query string false "只返回该时间段内的日志, 如10m, 仅支持容器日志"
+// @Param        tail query int false "只返回末尾的行数"
+// @Param        level query string false "最低日志级别: fatal/error/warn/info/debug/trace"
+// @Success      200 {string} string "日志"
+// @Failure      400 {string} string "参数非法"
+// @Failure      404 {string} string "切片不存在"
+// @Failure      500 {string} string "服务器内部错误（获取切片、Pod或日志失败）"
+// @Router       /slice/{slice_id}/logs [get]
+func (s *Server) getSliceLogs(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("获取slice日志请求", "method", r.Method, "url", r.URL.String())
+
+	sliceID := chi.URLParam(r, "slice_id")
+	if sliceID == "" {
+		slog.Warn("缺少sliceID参数")
+		http.Error(w, "缺少sliceID参数", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	opts := kubeclient.LogOptions{
+		NF:     query.Get("nf"),
+		Pod:    query.Get("pod"),
+		Source: query.Get("source"),
+		Follow: query.Get("follow") == "true",
+		Level:  query.Get("level"),
+	}
+	var err error
+	if since := query.Get("since"); since != "" {
+		if opts.Since, err = time.ParseDuration(since); err != nil {
+			http.Error(w, fmt.Sprintf("since格式错误: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if tail := query.Get("tail"); tail != "" {
+		if opts.TailLines, err = strconv.ParseInt(tail, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("tail格式错误: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("参数非法: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	slice, err := s.store.GetSliceBySliceID(sliceID)
+	if err != nil {
+		if isNotFoundError(err) {
+			slog.Warn("slice不存在", "sliceID", sliceID)
+			http.Error(w, fmt.Sprintf("slice不存在: %v", sliceID), http.StatusNotFound)
+			return
+		}
+		slog.Error("获取slice失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("获取slice失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// 切片参数中自定义了日志文件时读取该文件
+	if opts.Source == kubeclient.LogSourceFile && slice.NF != nil {
+		param := slice.NF.SMF
+		if opts.NF == "upf" {
+			param = slice.NF.UPF
+		}
+		if param.LogFile != "" {
+			opts.File = param.LogFile
+		}
+	}
+
+	kclient, err := s.clusters.ForSlice(slice)
+	if err != nil {
+		slog.Error("获取切片所在集群失败", "sliceID", sliceID, "cluster", slice.Cluster, "error", err)
+		http.Error(w, fmt.Sprintf("获取切片所在集群失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	fw := &flushWriter{w: w}
+	err = kclient.StreamSliceLogs(r.Context(), sliceID, opts, fw)
+	if err != nil && !fw.wrote {
+		slog.Warn("获取slice日志失败", "sliceID", sliceID, "nf", opts.NF, "error", err)
+		http.Error(w, fmt.Sprintf("获取slice日志失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		// 已开始输出, 只能记录日志
+		slog.Error("slice日志中断", "sliceID", sliceID, "nf", opts.NF, "error", err)
+		return
+	}
+	slog.Debug("获取slice日志完成", "sliceID", sliceID, "nf", opts.NF, "follow", opts.Follow)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slicer/kubeclient"
 	"slicer/model"
 
 	"github.com/go-chi/chi"
@@ -65,12 +66,14 @@ func (s *Server) createMonitor(w http.ResponseWriter, r *http.Request) {
 
 	// 获取sliceID
 	sliceID := monitor.KPI.SubCounter.SubCounterIDs[0]
+	kclient := s.kubeclient // 全部监控时部署在默认集群
 	if sliceID == "" {
 		slog.Debug("无sliceID参数, 默认进行全部监控")
 	} else {
 		slog.Debug("获取sliceID参数", "sliceID", sliceID)
 		// 检查sliceID是否存在
-		if _, err := s.store.GetSliceBySliceID(sliceID); err != nil {
+		slice, err := s.store.GetSliceBySliceID(sliceID)
+		if err != nil {
 			if isNotFoundError(err) { // MongoDB为空文档
 				slog.Warn("要求监控的sliceID不存在", "sliceID", sliceID)
 				http.Error(w, fmt.Sprintf("要求监控的sliceID不存在: %v", sliceID), http.StatusBadRequest)
@@ -81,6 +84,12 @@ func (s *Server) createMonitor(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "获取sliceID失败: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		// MDE和KPI计算组件与切片位于同一集群
+		if kclient, err = s.clusters.ForSlice(slice); err != nil {
+			slog.Error("连接切片所在集群失败", "sliceID", sliceID, "error", err)
+			http.Error(w, "连接切片所在集群失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// 渲染mde yaml
@@ -102,14 +111,14 @@ func (s *Server) createMonitor(w http.ResponseWriter, r *http.Request) {
 	// 部署MDE
 	// 注意这里使用了s.config.Namespace, 使用metrics+annotations的方式使prometheus进行抓取
 	// 如果使用了crd: service monitor, 需要使用s.config.MonitorNamespace(service中没有定义metrics, 直接使用service monitor似乎不工作因为port: metrics没有定义)
-	if err := s.kubeclient.ApplyMDE(yamlMde); err != nil {
+	if err := kclient.ApplyMDE(yamlMde); err != nil {
 		slog.Error("部署MDE失败", "sliceID", sliceID, "error", err)
 		http.Error(w, "部署MDE失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 部署KPI
-	if err := s.kubeclient.ApplyKpic(yamlKpi); err != nil {
+	if err := kclient.ApplyKpic(yamlKpi); err != nil {
 		slog.Error("部署KPI失败", "sliceID", sliceID, "error", err)
 		http.Error(w, "部署KPI失败: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -223,6 +232,12 @@ func (s *Server) deleteMonitor(w http.ResponseWriter, r *http.Request) {
 
 	// 获取sliceID
 	sliceID := monitor.KPI.SubCounter.SubCounterIDs[0]
+	kclient, err := s.monitorClient(sliceID)
+	if err != nil {
+		slog.Error("连接切片所在集群失败", "sliceID", sliceID, "error", err)
+		http.Error(w, "连接切片所在集群失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	// 删除MDE
 	yaml, err := s.render.RenderMde(sliceID)
@@ -231,7 +246,7 @@ func (s *Server) deleteMonitor(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "渲染yaml失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = s.kubeclient.DeleteMDE(yaml) // 注意这里使用了s.config.Namespace, 和上面创建时必须一致
+	err = kclient.DeleteMDE(yaml) // 注意这里使用了s.config.Namespace, 和上面创建时必须一致
 	if err != nil {
 		slog.Error("删除MDE失败", "sliceID", sliceID, "error", err)
 		http.Error(w, "删除MDE失败: "+err.Error(), http.StatusInternalServerError)
@@ -245,7 +260,7 @@ func (s *Server) deleteMonitor(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "渲染yaml失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = s.kubeclient.DeleteKpic(yaml)
+	err = kclient.DeleteKpic(yaml)
 	if err != nil {
 		slog.Error("删除KPI失败", "sliceID", sliceID, "error", err)
 		http.Error(w, "删除KPI失败: "+err.Error(), http.StatusInternalServerError)
@@ -402,3 +417,19 @@ func (s *Server) listMonitor(w http.ResponseWriter, r *http.Request) {
 	slog.Debug("获取监控请求列表成功", "count", len(monitors))
 	encodeResponse(w, monitors)
 }
+
+// monitorClient 返回切片的MDE和KPI计算组件所在集群的客户端
+// 全部监控或切片已被删除时使用默认集群
+func (s *Server) monitorClient(sliceID string) (*kubeclient.KubeClient, error) {
+	if sliceID == "" {
+		return s.kubeclient, nil
+	}
+	slice, err := s.store.GetSliceBySliceID(sliceID)
+	if isNotFoundError(err) {
+		return s.kubeclient, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取slice失败: %w", err)
+	}
+	return s.clusters.ForSlice(slice)
+}
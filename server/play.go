@@ -2,9 +2,12 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slicer/delivery"
+	"slicer/kubeclient"
 	"slicer/model"
 
 	"github.com/go-chi/chi"
@@ -13,6 +16,7 @@ import (
 // createPlay godoc
 // @Summary      创建Play资源
 // @Description  接收Play对象并创建新资源，同时部署到Kubernetes集群
+// @Description  写入后立即触发控制器以该Play为基础调整资源和带宽, operator_owned中固定的参数策略不会修改
 // @Tags         Play
 // @Accept       json
 // @Produce      json
@@ -20,7 +24,7 @@ import (
 // @Success      200 {object} model.Play "创建成功返回Play对象"
 // @Failure      400 {string} string "请求解码失败/参数非法/Slice不存在/Play已存在"
 // @Failure      404 {string} string "关联Slice不存在"
-// @Failure      500 {string} string "存储失败/部署失败/响应编码失败"
+// @Failure      500 {string} string "存储失败/部署失败/响应编码失败, Play回滚时返回JSON, 包含已恢复的变更"
 // @Router       /play [post]
 func (s *Server) createPlay(w http.ResponseWriter, r *http.Request) {
 	var play model.Play
@@ -37,7 +41,8 @@ func (s *Server) createPlay(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查slice是否存在
-	if _, err := s.store.GetSliceBySliceID(play.SliceID); err != nil {
+	slice, err := s.store.GetSliceBySliceID(play.SliceID)
+	if err != nil {
 		if isNotFoundError(err) {
 			slog.Warn("切片不存在", "sliceID", play.SliceID)
 			http.Error(w, "切片不存在", http.StatusNotFound)
@@ -49,7 +54,7 @@ func (s *Server) createPlay(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查是否有重复的play
-	_, err := s.store.GetPlayBySliceID(play.SliceID)
+	_, err = s.store.GetPlayBySliceID(play.SliceID)
 	if err == nil {
 		http.Error(w, "play已存在", http.StatusBadRequest)
 		return
@@ -63,16 +68,29 @@ func (s *Server) createPlay(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 部署play
-	err = s.kubeclient.Play(play, s.config.Namespace)
+	scaled, status, err := s.deliverPlay(slice, play)
+	if delivery.IsRolloutError(err) && !s.config.RolloutAutoRollback {
+		// 未开启自动回滚时保留play, 失败原因记录在交付状态中
+		slog.Warn("play未就绪", "sliceID", play.SliceID, "err", err)
+		err = nil
+	}
 	if err != nil {
+		slog.Error("部署play失败", "sliceID", play.SliceID, "err", err)
+		if delivery.IsRolloutError(err) || len(scaled.UPFAddrs()) != len(slice.UPFAddrs()) {
+			s.revertPlay(scaled, nil)
+		}
 		// 删除存储
 		errD := s.store.DeletePlay(play.ID.Hex())
 		if errD != nil {
 			slog.Error("删除play失败", "sliceID", play.SliceID, "err", errD)
 		}
-		http.Error(w, "部署play失败", http.StatusInternalServerError)
+		playErrorResponse(w, "部署play失败", err)
 		return
 	}
+	s.updateDeliveryStatus(scaled, status)
+
+	// 归档Play生效后的切片版本
+	s.archiveSliceByID(play.SliceID)
 
 	// 返回
 	w.Header().Set("Content-Type", "application/json")
@@ -131,6 +149,7 @@ func (s *Server) getPlay(w http.ResponseWriter, r *http.Request) {
 // updatePlay godoc
 // @Summary      更新Play资源
 // @Description  根据Play ID更新资源并重新部署
+// @Description  写入后立即触发控制器以该Play为基础调整资源和带宽, operator_owned中固定的参数策略不会修改
 // @Tags         Play
 // @Accept       json
 // @Produce      json
@@ -139,7 +158,7 @@ func (s *Server) getPlay(w http.ResponseWriter, r *http.Request) {
 // @Success      200 {object} model.Play "更新成功返回对象"
 // @Failure      400 {string} string "缺少Play ID/请求解码失败/参数非法"
 // @Failure      404 {string} string "Play不存在"
-// @Failure      500 {string} string "更新失败/部署失败/响应编码失败"
+// @Failure      500 {string} string "更新失败/部署失败/响应编码失败, Play回滚时返回JSON, 包含已恢复的变更"
 // @Router       /play/{play_id} [put]
 func (s *Server) updatePlay(w http.ResponseWriter, r *http.Request) {
 	// slog.Debug("更新play请求", "method", r.Method, "url", r.URL.String())
@@ -181,7 +200,8 @@ func (s *Server) updatePlay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 更新play
+	// 更新play, 保留更新前的play用于回滚
+	prevPlay := curPlay
 	err = curPlay.Update(play)
 	if err != nil {
 		slog.Error("更新play失败", "playID", playID, "error", err)
@@ -198,12 +218,41 @@ func (s *Server) updatePlay(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 更新部署
-	err = s.kubeclient.Play(curPlay, s.config.Namespace)
+	slice, err := s.store.GetSliceBySliceID(curPlay.SliceID)
+	if err != nil {
+		slog.Error("获取切片失败", "sliceID", curPlay.SliceID, "error", err)
+		http.Error(w, "获取切片失败", http.StatusInternalServerError)
+		return
+	}
+	scaled, status, err := s.deliverPlay(slice, curPlay)
+	if delivery.IsRolloutError(err) && !s.config.RolloutAutoRollback {
+		slog.Warn("play未就绪", "playID", playID, "err", err)
+		err = nil
+	}
 	if err != nil {
 		slog.Error("更新play部署失败", "playID", playID, "error", err)
-		http.Error(w, "更新play部署失败", http.StatusInternalServerError)
+		var playErr *kubeclient.PlayError
+		// Play事务已恢复集群, 或未就绪时已回滚到更新前的play, 存储也恢复为更新前的play
+		// UPF副本数有变化时切片模板已重新交付, 需恢复地址并重新交付更新前的play
+		reverted := errors.As(err, &playErr) && len(playErr.Report.RevertErrors) == 0
+		rescaled := len(scaled.UPFAddrs()) != len(slice.UPFAddrs())
+		if (!reverted && delivery.IsRolloutError(err)) || rescaled {
+			reverted = s.revertPlay(scaled, &prevPlay)
+		}
+		if reverted {
+			if _, err := s.store.UpdatePlay(prevPlay); err != nil {
+				slog.Error("回滚play存储失败", "playID", playID, "error", err)
+			}
+			playErrorResponse(w, "更新play部署失败, 已回滚", err)
+			return
+		}
+		playErrorResponse(w, "更新play部署失败", err)
 		return
 	}
+	s.updateDeliveryStatus(scaled, status)
+
+	// 归档Play生效后的切片版本
+	s.archiveSliceByID(curPlay.SliceID)
 
 	// 返回
 	w.Header().Set("Content-Type", "application/json")
@@ -214,3 +263,63 @@ func (s *Server) updatePlay(w http.ResponseWriter, r *http.Request) {
 	}
 	slog.Debug("更新play成功", "playID", curPlay.ID.Hex(), "sliceID", curPlay.SliceID)
 }
+
+// revertPlay 新play未就绪时恢复集群中的切片, 返回是否恢复成功
+// prev为nil时重新交付切片模板, 即恢复到未应用play的状态, 交付后的清理会删除play创建的优先级类;
+// UPF地址同时恢复为prev的副本数
+func (s *Server) revertPlay(slice model.SliceAndAddress, prev *model.Play) bool {
+	var (
+		status model.DeliveryStatus
+		err    error
+	)
+	if prev == nil {
+		changed, errR := s.resizeUPFAddrs(&slice, 1)
+		if errR != nil {
+			slog.Error("释放UPF副本地址失败", "sliceID", slice.SliceID(), "err", errR)
+		}
+		// 地址已释放, 无论交付是否成功都需保存缩容后的切片
+		if changed {
+			if _, errU := s.store.UpdateSlice(slice); errU != nil {
+				slog.Error("保存UPF副本地址失败", "sliceID", slice.SliceID(), "err", errU)
+				return false
+			}
+		}
+		status, err = s.delivery.DeliverSlice(slice, nil)
+	} else {
+		slice, status, err = s.deliverPlay(slice, *prev)
+	}
+	if err != nil {
+		slog.Error("回滚play失败", "sliceID", slice.SliceID(), "err", err)
+		return false
+	}
+	s.updateDeliveryStatus(slice, status)
+	slog.Info("已回滚play", "sliceID", slice.SliceID())
+	return true
+}
+
+// deliverPlay 交付play, 返回地址调整后的切片
+// UPF副本数变化时先按副本数分配或释放UPF地址并重新交付切片模板,
+// 使UPF在Deployment与StatefulSet之间切换, 且SMF配置列出所有副本
+func (s *Server) deliverPlay(slice model.SliceAndAddress, play model.Play) (model.SliceAndAddress, model.DeliveryStatus, error) {
+	changed, err := s.resizeUPFAddrs(&slice, play.UPFReplicaSlots())
+	if err != nil {
+		if !changed {
+			return slice, model.DeliveryStatus{}, err
+		}
+		// 缩容时部分地址释放失败, 不影响部署
+		slog.Error("释放UPF副本地址失败", "sliceID", slice.SliceID(), "err", err)
+	}
+	if changed {
+		if _, err := s.store.UpdateSlice(slice); err != nil {
+			return slice, model.DeliveryStatus{}, fmt.Errorf("保存UPF副本地址失败: %w", err)
+		}
+		// git方式下切片与待交付的Play在同一提交中, 不产生缺少Play的中间状态
+		status, err := s.delivery.DeliverSlice(slice, &play)
+		if err != nil && !delivery.IsRolloutError(err) {
+			return slice, status, fmt.Errorf("重新交付切片失败: %w", err)
+		}
+		slog.Info("已调整UPF副本地址", "sliceID", slice.SliceID(), "replicas", len(slice.UPFAddrs()))
+	}
+	status, err := s.delivery.DeliverPlay(slice, play)
+	return slice, status, err
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// getTemplates godoc
+// @Summary      获取模板信息
+// @Description  获取当前生效的渲染模板及其版本信息(来源、sha256、修改时间、加载时间)
+// @Description  配置TEMPLATE_PATH时, 覆盖目录中的模板变化后会自动重新加载, 加载失败时保留之前的模板并返回last_error
+// @Tags         Render
+// @Produce      json
+// @Success      200 {object} render.TemplateStatus "模板信息"
+// @Router       /render/templates [get]
+func (s *Server) getTemplates(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("获取模板信息请求", "method", r.Method, "url", r.URL.String())
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeResponse(w, s.render.Templates())
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"slicer/archive"
+	"slicer/backup"
 	"slicer/controller"
 	"slicer/db"
+	"slicer/delivery"
 	"slicer/kubeclient"
 	"slicer/monitor"
 	"slicer/render"
@@ -26,8 +29,12 @@ type Server struct {
 	store      db.Store
 	ipam       *db.IPAM
 	render     *render.Render
-	kubeclient *kubeclient.KubeClient
+	kubeclient *kubeclient.KubeClient // 默认集群, 用于全局监控等不属于切片的资源
+	clusters   *kubeclient.Clusters   // 切片所在的集群
 	controller controller.Controller
+	delivery   delivery.Deliverer // 切片资源的交付方式
+	backup     *backup.Backup     // 可为nil, 表示未启用备份
+	archive    *archive.Archive   // 可为nil, 表示未启用归档
 }
 
 type NewSeverArg struct {
@@ -36,8 +43,11 @@ type NewSeverArg struct {
 	db.Store
 	*db.IPAM
 	*render.Render
-	*kubeclient.KubeClient
+	*kubeclient.Clusters
 	controller.Controller
+	delivery.Deliverer
+	*backup.Backup
+	*archive.Archive
 }
 
 func NewServer(arg NewSeverArg) *Server {
@@ -49,8 +59,14 @@ func NewServer(arg NewSeverArg) *Server {
 		store:      arg.Store,
 		ipam:       arg.IPAM,
 		render:     arg.Render,
-		kubeclient: arg.KubeClient,
+		clusters:   arg.Clusters,
 		controller: arg.Controller,
+		delivery:   arg.Deliverer,
+		backup:     arg.Backup,
+		archive:    arg.Archive,
+	}
+	if arg.Clusters != nil {
+		s.kubeclient = arg.Clusters.Default()
 	}
 	s.routes()
 	return s
@@ -82,10 +98,15 @@ func (s *Server) routes() {
 
 	// 切片管理
 	s.router.Route("/slice", func(r chi.Router) {
-		r.Post("/", s.createSlice)             // 创建新切片
-		r.Delete("/{slice_id}", s.deleteSlice) // 删除指定切片
-		r.Get("/{slice_id}", s.getSlice)       // 获取指定切片详情
-		r.Get("/", s.listSlice)                // 列出所有切片
+		r.Post("/", s.createSlice)                    // 创建新切片
+		r.Delete("/{slice_id}", s.deleteSlice)        // 删除指定切片
+		r.Get("/{slice_id}", s.getSlice)              // 获取指定切片详情
+		r.Get("/{slice_id}/export", s.exportSlice)    // 导出为Helm chart或Kustomize压缩包
+		r.Post("/{slice_id}/prune", s.pruneSlice)     // 清理不再渲染的资源, 支持dry_run
+		r.Get("/{slice_id}/events", s.getSliceEvents) // 切片对象的Kubernetes事件
+		r.Get("/{slice_id}/logs", s.getSliceLogs)     // NF日志, 支持follow分块输出
+		r.Post("/adopt", s.adoptSlice)                // 接管slicer之外部署的切片, 支持dry_run
+		r.Get("/", s.listSlice)                       // 列出所有切片
 	})
 
 	// 监控管理(目前仅支持切片监控)
@@ -119,6 +140,33 @@ func (s *Server) routes() {
 	s.router.Route("/controller", func(r chi.Router) {
 		r.Get("/", s.getController)     // 获取 controller 的状态，包括切片列表、策略等
 		r.Post("/", s.updateController) // 更新 controller 的状态
+
+		r.Get("/slices", s.listControlledSlices)                 // 列出切片的控制设置及下次控制时间
+		r.Get("/slices/{slice_id}", s.getControlledSlice)        // 获取单个切片的控制设置
+		r.Post("/slices/{slice_id}", s.updateControlledSlice)    // 修改切片的控制间隔、策略或是否启用
+		r.Post("/slices/{slice_id}/reconcile", s.reconcileSlice) // 立即控制切片
+		r.Post("/alerts", s.receiveAlerts)                       // Alertmanager webhook, 按告警的slice_id标签立即控制切片
+	})
+
+	// 多集群
+	s.router.Get("/clusters", s.listClusters) // 各集群的连接状态与容量
+
+	// 备份与恢复
+	s.router.Route("/backup", func(r chi.Router) {
+		r.Post("/", s.createBackup)         // 立即备份
+		r.Get("/", s.listBackup)            // 列出所有备份
+		r.Post("/restore", s.restoreBackup) // 从备份恢复
+	})
+
+	// 渲染模板
+	s.router.Route("/render", func(r chi.Router) {
+		r.Get("/templates", s.getTemplates) // 获取当前生效模板的版本信息
+	})
+
+	// 归档(渲染清单与控制器决策记录)
+	s.router.Route("/archive", func(r chi.Router) {
+		r.Get("/", s.listArchive)             // 列出指定前缀下的归档
+		r.Get("/object/*", s.downloadArchive) // 下载归档对象
 	})
 
 	// Monarch交互
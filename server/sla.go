@@ -14,6 +14,7 @@ import (
 // @Summary      创建SLA
 // @Description  接收SLA对象并创建新资源
 // @Description  slice id对应切片必须存在
+// @Description  切片加入控制器, 并按新的SLA立即控制
 // @Tags         SLA
 // @Accept       json
 // @Produce      json
@@ -56,15 +57,15 @@ func (s *Server) createSla(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 存储SLA
-	sla, err = s.store.CreateSLA(sla)
+	// 先添加slice到controller, 存储SLA后由存储的变化通知按新的SLA立即控制
+	s.controller.AddSlice(sla.SliceID)
+	created, err := s.store.CreateSLA(sla)
 	if err != nil {
+		s.controller.RemoveSlice(sla.SliceID)
 		http.Error(w, "创建SLA失败", http.StatusInternalServerError)
 		return
 	}
-
-	// 添加slice到controller
-	s.controller.AddSlice(sla.SliceID)
+	sla = created
 
 	// 返回
 	w.Header().Set("Content-Type", "application/json")
@@ -120,6 +121,7 @@ func (s *Server) getSla(w http.ResponseWriter, r *http.Request) {
 // updateSla godoc
 // @Summary      更新SLA
 // @Description  根据SLA ID更新资源
+// @Description  按更新后的SLA立即控制切片
 // @Tags         SLA
 // @Accept       json
 // @Produce      json
@@ -173,7 +175,7 @@ func (s *Server) updateSla(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 更新存储
+	// 更新存储, 由存储的变化通知按新的SLA立即控制
 	_, err = s.store.UpdateSLA(curSLA)
 	if err != nil {
 		slog.Error("更新SLA存储失败", "SLAID", slaID, "error", err)
@@ -3,9 +3,13 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"slicer/delivery"
+	"slicer/kubeclient"
 	"slicer/model"
+	"slicer/render"
 
 	"github.com/go-chi/chi"
 )
@@ -20,7 +24,7 @@ import (
 // @Success      200   {object}  model.SliceAndAddress "创建成功，返回切片及其地址"
 // @Failure      400   {string}  string "请求格式错误或参数非法"
 // @Failure      409   {string}  string "切片已存在"
-// @Failure      500   {string}  string "服务器内部错误，如分配IP或部署资源失败"
+// @Failure      500   {string}  string "服务器内部错误，如分配IP或交付资源失败"
 // @Router       /slice [post]
 func (s *Server) createSlice(w http.ResponseWriter, r *http.Request) {
 	slog.Debug("创建slice请求", "method", r.Method, "url", r.URL.String())
@@ -49,6 +53,14 @@ func (s *Server) createSlice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 按放置规则选择集群
+	cluster, err := s.clusters.Resolve(slice.Placement)
+	if err != nil {
+		slog.Warn("选择集群失败", "sliceID", slice.SliceID(), "error", err)
+		http.Error(w, fmt.Sprintf("选择集群失败: %v", err), http.StatusBadRequest)
+		return
+	}
+
 	// 定义一个回滚栈，用于记录需要回滚的操作
 	var rollbackFuncs []func()
 
@@ -74,6 +86,7 @@ func (s *Server) createSlice(w http.ResponseWriter, r *http.Request) {
 			slog.Error("回滚释放IP失败", "error", releaseErr)
 		}
 	})
+	wrappedSlice.Cluster = cluster
 
 	// 存储 slice对象
 	wrappedSlice, err = s.store.CreateSlice(wrappedSlice)
@@ -88,27 +101,39 @@ func (s *Server) createSlice(w http.ResponseWriter, r *http.Request) {
 		}
 	})
 
-	// 切片转化为k8s资源
-	contents, err := s.render.RenderSlice(wrappedSlice)
-	if err != nil {
-		slog.Error("渲染slice失败", "error", err)
-		http.Error(w, fmt.Sprintf("渲染slice失败: %v", err), http.StatusInternalServerError)
-		return
+	// 交付k8s资源(直接应用或提交到git仓库)
+	status, err := s.delivery.DeliverSlice(wrappedSlice, nil)
+	if delivery.IsRolloutError(err) {
+		if s.config.RolloutAutoRollback {
+			// 资源已应用但未就绪, 删除已应用的资源, 其余由回滚栈处理
+			rollbackFuncs = append(rollbackFuncs, func() {
+				if _, deleteErr := s.delivery.RemoveSlice(wrappedSlice); deleteErr != nil {
+					slog.Error("回滚删除未就绪资源失败", "error", deleteErr)
+				}
+			})
+		} else {
+			// 未开启自动回滚时保留切片, 失败原因记录在交付状态中
+			slog.Warn("slice未就绪", "sliceID", wrappedSlice.SliceID(), "error", err)
+			err = nil
+		}
 	}
-
-	// 部署k8s资源
-	err = s.kubeclient.ApplySlice(contents)
 	if err != nil {
-		slog.Error("应用kube资源失败", "error", err)
-		http.Error(w, fmt.Sprintf("应用kube资源失败: %v", err), http.StatusInternalServerError)
+		slog.Error("交付slice失败", "mode", s.delivery.Mode(), "error", err)
+		http.Error(w, fmt.Sprintf("交付slice失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 	rollbackFuncs = append(rollbackFuncs, func() {
-		if deleteErr := s.kubeclient.DeleteMulti(contents, s.config.Namespace); deleteErr != nil {
-			slog.Error("回滚集群中删除配置失败", "error", deleteErr)
+		if _, deleteErr := s.delivery.RemoveSlice(wrappedSlice); deleteErr != nil {
+			slog.Error("回滚删除已交付资源失败", "error", deleteErr)
 		}
 	})
 
+	// 记录交付状态
+	wrappedSlice = s.updateDeliveryStatus(wrappedSlice, status)
+
+	// 归档本次部署的清单
+	s.archiveSlice(wrappedSlice)
+
 	//设置响应头
 	w.Header().Set("Content-Type", "application/json")
 	//编码响应
@@ -131,7 +156,7 @@ func (s *Server) createSlice(w http.ResponseWriter, r *http.Request) {
 // @Success      204 "删除成功无内容"
 // @Failure      400 {string} string "缺少sliceID参数"
 // @Failure      404 {string} string "切片不存在"
-// @Failure      500 {string} string "服务器内部错误（获取/渲染/删除k8s资源失败、释放IP失败、存储删除失败）"
+// @Failure      500 {string} string "服务器内部错误（删除已交付资源失败、释放IP失败、存储删除失败）"
 // @Router       /slice/{slice_id} [delete]
 func (s *Server) deleteSlice(w http.ResponseWriter, r *http.Request) {
 	slog.Debug("删除slice请求", "method", r.Method, "url", r.URL.String())
@@ -157,19 +182,11 @@ func (s *Server) deleteSlice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 切片转化为k8s资源
-	contents, err := s.render.RenderSlice(slice)
-	if err != nil {
-		slog.Error("渲染slice失败", "sliceID", sliceID, "error", err)
-		http.Error(w, fmt.Sprintf("渲染slice失败: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	// 删除k8s资源
-	err = s.kubeclient.DeleteSlice(contents)
+	// 删除k8s资源(直接删除或从git仓库中移除)
+	_, err = s.delivery.RemoveSlice(slice)
 	if err != nil {
-		slog.Error("删除kube资源失败", "sliceID", sliceID, "error", err)
-		http.Error(w, fmt.Sprintf("删除kube资源失败: %v", err), http.StatusInternalServerError)
+		slog.Error("删除已交付资源失败", "sliceID", sliceID, "mode", s.delivery.Mode(), "error", err)
+		http.Error(w, fmt.Sprintf("删除已交付资源失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -277,3 +294,342 @@ func (s *Server) listSlice(w http.ResponseWriter, r *http.Request) {
 
 	slog.Debug("获取slice列表成功", "count", len(slices))
 }
+
+// exportSlice godoc
+// @Summary      导出切片
+// @Description  将切片渲染为Helm chart或Kustomize目录, 以tar.gz下载, 用于GitOps管理的集群
+// @Description  切片已有的Play一并导出: helm格式写入values.yaml的play中, kustomize格式作为overlay中的patch
+// @Tags         Slice
+// @Produce      application/gzip
+// @Param        sliceID path string true "切片ID"
+// @Param        format query string false "输出格式: helm(默认)或kustomize"
+// @Success      200 {file} file "tar.gz压缩包"
+// @Failure      400 {string} string "缺少sliceID参数/不支持的输出格式"
+// @Failure      404 {string} string "切片不存在"
+// @Failure      500 {string} string "服务器内部错误（获取切片、渲染或打包失败）"
+// @Router       /slice/{slice_id}/export [get]
+func (s *Server) exportSlice(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("导出slice请求", "method", r.Method, "url", r.URL.String())
+
+	sliceID := chi.URLParam(r, "slice_id")
+	if sliceID == "" {
+		slog.Warn("缺少sliceID参数")
+		http.Error(w, "缺少sliceID参数", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = render.FormatHelm
+	}
+	if format != render.FormatHelm && format != render.FormatKustomize {
+		http.Error(w, fmt.Sprintf("不支持的输出格式: %s", format), http.StatusBadRequest)
+		return
+	}
+
+	slice, err := s.store.GetSliceBySliceID(sliceID)
+	if err != nil {
+		if isNotFoundError(err) {
+			slog.Warn("slice不存在", "sliceID", sliceID)
+			http.Error(w, fmt.Sprintf("slice不存在: %v", sliceID), http.StatusNotFound)
+			return
+		}
+		slog.Error("获取slice失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("获取slice失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// 已有Play时作为overlay一并导出
+	var play *model.Play
+	if p, err := s.store.GetPlayBySliceID(sliceID); err == nil {
+		play = &p
+	}
+
+	files, err := s.render.RenderPackage(slice, play, format)
+	if err != nil {
+		slog.Error("渲染slice失败", "sliceID", sliceID, "format", format, "error", err)
+		http.Error(w, fmt.Sprintf("渲染slice失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data, err := render.TarGz(files)
+	if err != nil {
+		slog.Error("打包失败", "sliceID", sliceID, "format", format, "error", err)
+		http.Error(w, fmt.Sprintf("打包失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("slice-%s-%s.tgz", sliceID, format)))
+	if _, err := w.Write(data); err != nil {
+		slog.Error("写入响应失败", "sliceID", sliceID, "error", err)
+		return
+	}
+	slog.Debug("导出slice成功", "sliceID", sliceID, "format", format)
+}
+
+// pruneSlice godoc
+// @Summary      清理切片的过期资源
+// @Description  删除带有该切片归属标签, 但当前模板已不再渲染的资源(如改名或移除的Service)
+// @Description  dry_run=true时只列出将被删除的资源
+// @Tags         Slice
+// @Produce      json
+// @Param        sliceID path string true "切片ID"
+// @Param        dry_run query bool false "只列出, 不删除"
+// @Success      200 {array} kubeclient.PrunedObject "被删除(或将被删除)的资源"
+// @Failure      400 {string} string "缺少sliceID参数/git交付方式不支持"
+// @Failure      404 {string} string "切片不存在"
+// @Failure      500 {string} string "服务器内部错误（获取切片、渲染或清理失败）"
+// @Router       /slice/{slice_id}/prune [post]
+func (s *Server) pruneSlice(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("清理slice请求", "method", r.Method, "url", r.URL.String())
+
+	sliceID := chi.URLParam(r, "slice_id")
+	if sliceID == "" {
+		slog.Warn("缺少sliceID参数")
+		http.Error(w, "缺少sliceID参数", http.StatusBadRequest)
+		return
+	}
+	if s.delivery.Mode() != delivery.ModeApply {
+		http.Error(w, "git交付方式下过期资源由GitOps工具清理", http.StatusBadRequest)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	slice, err := s.store.GetSliceBySliceID(sliceID)
+	if err != nil {
+		if isNotFoundError(err) {
+			slog.Warn("slice不存在", "sliceID", sliceID)
+			http.Error(w, fmt.Sprintf("slice不存在: %v", sliceID), http.StatusNotFound)
+			return
+		}
+		slog.Error("获取slice失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("获取slice失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	contents, err := s.render.RenderSlice(slice)
+	if err != nil {
+		slog.Error("渲染slice失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("渲染slice失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	kclient, err := s.clusters.ForSlice(slice)
+	if err != nil {
+		slog.Error("获取切片所在集群失败", "sliceID", sliceID, "cluster", slice.Cluster, "error", err)
+		http.Error(w, fmt.Sprintf("获取切片所在集群失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	pruned, err := kclient.PruneSlice(sliceID, contents, dryRun)
+	if err != nil {
+		slog.Error("清理过期资源失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("清理过期资源失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pruned); err != nil {
+		slog.Error("响应编码失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("响应编码失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Debug("清理slice成功", "sliceID", sliceID, "dryRun", dryRun, "count", len(pruned))
+}
+
+// getSliceEvents godoc
+// @Summary      获取切片的Kubernetes事件
+// @Description  返回切片中Pod、Deployment、Service等对象的事件(如OOMKilling、FailedScheduling、镜像拉取失败), 按最近发生时间排序
+// @Tags         Slice
+// @Produce      json
+// @Param        sliceID path string true "切片ID"
+// @Param        type query string false "事件类型, Normal或Warning, 默认全部"
+// @Success      200 {array} kubeclient.SliceEvent "切片的事件"
+// @Failure      400 {string} string "缺少sliceID参数"
+// @Failure      404 {string} string "切片不存在"
+// @Failure      500 {string} string "服务器内部错误（获取切片或事件失败）"
+// @Router       /slice/{slice_id}/events [get]
+func (s *Server) getSliceEvents(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("获取slice事件请求", "method", r.Method, "url", r.URL.String())
+
+	sliceID := chi.URLParam(r, "slice_id")
+	if sliceID == "" {
+		slog.Warn("缺少sliceID参数")
+		http.Error(w, "缺少sliceID参数", http.StatusBadRequest)
+		return
+	}
+
+	slice, err := s.store.GetSliceBySliceID(sliceID)
+	if err != nil {
+		if isNotFoundError(err) {
+			slog.Warn("slice不存在", "sliceID", sliceID)
+			http.Error(w, fmt.Sprintf("slice不存在: %v", sliceID), http.StatusNotFound)
+			return
+		}
+		slog.Error("获取slice失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("获取slice失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	kclient, err := s.clusters.ForSlice(slice)
+	if err != nil {
+		slog.Error("获取切片所在集群失败", "sliceID", sliceID, "cluster", slice.Cluster, "error", err)
+		http.Error(w, fmt.Sprintf("获取切片所在集群失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	events, err := kclient.SliceEvents(sliceID, r.URL.Query().Get("type"))
+	if err != nil {
+		slog.Error("获取slice事件失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("获取slice事件失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		slog.Error("响应编码失败", "sliceID", sliceID, "error", err)
+		http.Error(w, fmt.Sprintf("响应编码失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Debug("获取slice事件成功", "sliceID", sliceID, "count", len(events))
+}
+
+// updateDeliveryStatus 记录切片最近一次交付的状态(如git提交SHA), 失败只记录日志
+func (s *Server) updateDeliveryStatus(slice model.SliceAndAddress, status model.DeliveryStatus) model.SliceAndAddress {
+	slice.Status = &status
+	slice, err := s.store.UpdateSlice(slice)
+	if err != nil {
+		slog.Error("更新切片交付状态失败", "sliceID", slice.SliceID(), "error", err)
+	}
+	return slice
+}
+
+// adoptResult 单个切片的接管结果
+type adoptResult struct {
+	kubeclient.DiscoveredSlice
+	Action string `json:"action"`           // adopted, skipped, failed, 或dry_run时为pending
+	Detail string `json:"detail,omitempty"` // 跳过或失败的原因
+}
+
+// adoptSessions 接管请求中一个切片的会话签约参数, 按会话名称(DNN)匹配
+type adoptSessions struct {
+	SliceID  string          `json:"slice_id"`
+	Sessions []model.Session `json:"sessions"`
+}
+
+// adoptSlice godoc
+// @Summary      接管已有的切片
+// @Description  扫描命名空间中按 open5gs-smf<后缀>/open5gs-upf<后缀> 命名的Deployment及其ConfigMap(后缀如1-000001或1), 由配置文件和Multus注解还原切片(SMF配置中的S-NSSAI、DNN、会话子网、N3/N4地址)
+// @Description  配置文件中没有会话的QoS/AMBR, 需在请求体中按切片和DNN提供, 缺少时不接管并在missing中列出
+// @Description  在IPAM中保留这些地址, 存储切片并为资源打上归属标签, 之后可像slicer创建的切片一样管理
+// @Description  dry_run=true时只返回解析结果
+// @Tags         Slice
+// @Accept       json
+// @Produce      json
+// @Param        namespace query string false "扫描的命名空间, 默认为slicer的命名空间"
+// @Param        cluster query string false "扫描的集群, 默认为默认集群"
+// @Param        dry_run query bool false "只解析, 不接管"
+// @Param        sessions body []adoptSessions false "各切片会话的QoS/AMBR"
+// @Success      200 {array} adoptResult "每个切片的接管结果"
+// @Failure      400 {string} string "集群未注册或请求体格式错误"
+// @Failure      500 {string} string "连接集群或扫描命名空间失败"
+// @Router       /slice/adopt [post]
+func (s *Server) adoptSlice(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("接管slice请求", "method", r.Method, "url", r.URL.String())
+
+	namespace := r.URL.Query().Get("namespace")
+	if namespace == "" {
+		namespace = s.config.Namespace
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	var requested []adoptSessions
+	if err := json.NewDecoder(r.Body).Decode(&requested); err != nil && err != io.EOF {
+		slog.Warn("请求体格式错误", "error", err)
+		http.Error(w, fmt.Sprintf("请求体格式错误: %v", err), http.StatusBadRequest)
+		return
+	}
+	sessions := make(map[string][]model.Session, len(requested))
+	for _, req := range requested {
+		sessions[req.SliceID] = req.Sessions
+	}
+	cluster := r.URL.Query().Get("cluster")
+	if cluster == "" {
+		cluster = kubeclient.DefaultCluster
+	}
+	if !s.clusters.Has(cluster) {
+		slog.Warn("集群未注册", "cluster", cluster)
+		http.Error(w, fmt.Sprintf("集群未注册: %v", cluster), http.StatusBadRequest)
+		return
+	}
+	kclient, err := s.clusters.Get(cluster)
+	if err != nil {
+		slog.Error("连接集群失败", "cluster", cluster, "error", err)
+		http.Error(w, fmt.Sprintf("连接集群失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	discovered, err := kclient.DiscoverSlices(namespace)
+	if err != nil {
+		slog.Error("扫描命名空间失败", "namespace", namespace, "error", err)
+		http.Error(w, fmt.Sprintf("扫描命名空间失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	results := make([]adoptResult, 0, len(discovered))
+	for _, d := range discovered {
+		if d.Slice != nil {
+			d.Slice.Cluster = cluster
+		}
+		action, detail := s.adopt(kclient, namespace, &d, sessions[d.SliceID], dryRun)
+		result := adoptResult{DiscoveredSlice: d, Action: action, Detail: detail}
+		if result.Action == "adopted" {
+			slog.Info("已接管slice", "sliceID", d.SliceID, "cluster", cluster, "namespace", namespace)
+		} else if result.Action != "pending" {
+			slog.Warn("未接管slice", "sliceID", d.SliceID, "action", result.Action, "detail", result.Detail)
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		slog.Error("响应编码失败", "error", err)
+		http.Error(w, fmt.Sprintf("响应编码失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Debug("接管slice完成", "cluster", cluster, "namespace", namespace, "dryRun", dryRun, "count", len(results))
+}
+
+// adopt 接管单个切片, 返回动作及原因; 保留地址后的任一步骤失败时释放地址
+// 会话的QoS/AMBR取自请求, 缺少时不接管
+func (s *Server) adopt(kclient *kubeclient.KubeClient, namespace string, d *kubeclient.DiscoveredSlice, sessions []model.Session, dryRun bool) (string, string) {
+	if d.Error != "" {
+		return "failed", d.Error
+	}
+	if err := d.SetSessions(sessions); err != nil {
+		return "failed", err.Error()
+	}
+	if _, err := s.store.GetSliceBySliceID(d.SliceID); err == nil {
+		return "skipped", "切片已存在"
+	} else if !isNotFoundError(err) {
+		return "failed", fmt.Sprintf("获取slice失败: %v", err)
+	}
+	if expected := s.config.SliceNamespace(d.SliceID); namespace != expected {
+		return "failed", fmt.Sprintf("切片资源需位于命名空间 %s", expected)
+	}
+	if dryRun {
+		return "pending", ""
+	}
+
+	if err := s.reserveIP(*d.Slice); err != nil {
+		return "failed", fmt.Sprintf("保留地址失败: %v", err)
+	}
+	if _, err := s.store.CreateSlice(*d.Slice); err != nil {
+		if releaseErr := s.releaseIP(*d.Slice); releaseErr != nil {
+			slog.Error("回滚释放IP失败", "sliceID", d.SliceID, "error", releaseErr)
+		}
+		return "failed", fmt.Sprintf("存储slice失败: %v", err)
+	}
+
+	// 切片已存储, 标记失败时只影响按标签清理, 不回滚
+	if err := kclient.AdoptSlice(namespace, *d); err != nil {
+		return "adopted", fmt.Sprintf("资源未能打上归属标签: %v", err)
+	}
+	return "adopted", ""
+}
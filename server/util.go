@@ -1,9 +1,12 @@
 package server
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"slicer/kubeclient"
 	"slicer/model"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -76,6 +79,10 @@ func (s *Server) releaseIP(slice model.SliceAndAddress) error {
 		errs = append(errs, fmt.Errorf("释放UPF N4地址失败: %w", err))
 	}
 
+	for i, addr := range slice.UPFReplicaAddrs {
+		errs = append(errs, s.releaseReplicaAddr(i+1, addr))
+	}
+
 	for _, sessionSubnet := range slice.SessionSubnets {
 		err = s.ipam.ReleaseSessionSubnet(sessionSubnet)
 		if err != nil {
@@ -85,6 +92,103 @@ func (s *Server) releaseIP(slice model.SliceAndAddress) error {
 	return errors.Join(errs...)
 }
 
+// reserveIP 在IPAM中保留接管的切片已在使用的地址, 任一地址保留失败时释放已保留的部分
+func (s *Server) reserveIP(slice model.SliceAndAddress) (err error) {
+	var rollback []func() error
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(rollback) - 1; i >= 0; i-- {
+			if releaseErr := rollback[i](); releaseErr != nil {
+				slog.Error("回滚释放已保留的地址失败", "error", releaseErr)
+			}
+		}
+	}()
+
+	reserve := func(addr string, reserveFn, releaseFn func(string) error) error {
+		if err := reserveFn(addr); err != nil {
+			return err
+		}
+		rollback = append(rollback, func() error { return releaseFn(addr) })
+		return nil
+	}
+	for _, subnet := range slice.SessionSubnets {
+		if err = reserve(subnet, s.ipam.ReserveSessionSubnet, s.ipam.ReleaseSessionSubnet); err != nil {
+			return err
+		}
+	}
+	for _, addr := range slice.UPFAddrs() {
+		if err = reserve(addr.N3Addr, s.ipam.ReserveN3Addr, s.ipam.ReleaseN3Addr); err != nil {
+			return err
+		}
+		if err = reserve(addr.N4Addr, s.ipam.ReserveN4Addr, s.ipam.ReleaseN4Addr); err != nil {
+			return err
+		}
+	}
+	if err = reserve(slice.SMFN3Addr, s.ipam.ReserveN3Addr, s.ipam.ReleaseN3Addr); err != nil {
+		return err
+	}
+	return reserve(slice.SMFN4Addr, s.ipam.ReserveN4Addr, s.ipam.ReleaseN4Addr)
+}
+
+// resizeUPFAddrs 按副本数为UPF的每个副本分配或释放N3/N4地址, 返回是否有变化
+// 分配失败时释放本次已分配的地址, 切片保持不变
+func (s *Server) resizeUPFAddrs(slice *model.SliceAndAddress, replicas int) (bool, error) {
+	current := len(slice.UPFAddrs())
+	if replicas < 1 || replicas == current {
+		return false, nil
+	}
+
+	if replicas > current {
+		var allocated []model.ReplicaAddress
+		for i := current; i < replicas; i++ {
+			n3Addr, err := s.ipam.AllocateN3Addr()
+			if err == nil {
+				var n4Addr string
+				if n4Addr, err = s.ipam.AllocateN4Addr(); err == nil {
+					allocated = append(allocated, model.ReplicaAddress{N3Addr: n3Addr, N4Addr: n4Addr})
+					continue
+				}
+				if errR := s.ipam.ReleaseN3Addr(n3Addr); errR != nil {
+					slog.Error("释放UPF副本N3地址失败", "addr", n3Addr, "err", errR)
+				}
+			}
+			for j, addr := range allocated {
+				if errR := s.releaseReplicaAddr(current+j, addr); errR != nil {
+					slog.Error("释放UPF副本地址失败", "err", errR)
+				}
+			}
+			return false, fmt.Errorf("为UPF副本%d分配地址失败: %w", i, err)
+		}
+		slice.UPFReplicaAddrs = append(slice.UPFReplicaAddrs, allocated...)
+		return true, nil
+	}
+
+	// 缩容时释放序号最大的副本的地址
+	var errs []error
+	for i := current - 1; i >= replicas; i-- {
+		errs = append(errs, s.releaseReplicaAddr(i, slice.UPFReplicaAddrs[i-1]))
+	}
+	slice.UPFReplicaAddrs = slice.UPFReplicaAddrs[:replicas-1]
+	if len(slice.UPFReplicaAddrs) == 0 {
+		slice.UPFReplicaAddrs = nil
+	}
+	return true, errors.Join(errs...)
+}
+
+// releaseReplicaAddr 释放第index个UPF副本的地址
+func (s *Server) releaseReplicaAddr(index int, addr model.ReplicaAddress) error {
+	var errs []error
+	if err := s.ipam.ReleaseN3Addr(addr.N3Addr); err != nil {
+		errs = append(errs, fmt.Errorf("释放UPF副本%d N3地址失败: %w", index, err))
+	}
+	if err := s.ipam.ReleaseN4Addr(addr.N4Addr); err != nil {
+		errs = append(errs, fmt.Errorf("释放UPF副本%d N4地址失败: %w", index, err))
+	}
+	return errors.Join(errs...)
+}
+
 func isNotFoundError(err error) bool {
 	if errors.Is(err, mongo.ErrNoDocuments) { // MongoDB为空文档
 		slog.Debug("MongoDB没有文档", "error", err)
@@ -97,3 +201,23 @@ func isNotFoundError(err error) bool {
 		return false
 	}
 }
+
+// playErrorResponse Play部署失败时的响应, 事务回滚时以JSON返回已恢复的变更
+func playErrorResponse(w http.ResponseWriter, msg string, err error) {
+	var playErr *kubeclient.PlayError
+	if !errors.As(err, &playErr) {
+		http.Error(w, fmt.Sprintf("%s: %v", msg, err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(struct {
+		Error  string           `json:"error"`
+		Report model.PlayReport `json:"report"`
+	}{
+		Error:  fmt.Sprintf("%s: %v", msg, err),
+		Report: playErr.Report,
+	}); err != nil {
+		slog.Error("响应编码失败", "error", err)
+	}
+}
@@ -3,8 +3,10 @@ package util
 import (
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -25,6 +27,37 @@ type KubeConfig struct {
 	Namespace        string
 	MonitorNamespace string
 	KubeconfigPath   string
+	// 可选, 应用后等待切片就绪的超时时间, 为0时不等待
+	RolloutTimeout time.Duration
+	// 可选, 等待就绪失败时自动回滚
+	RolloutAutoRollback bool
+	// 可选, 为true时每个切片部署在独立的命名空间 <SliceNamespacePrefix><切片ID>, 共享NF和NAD仍在Namespace中
+	NamespacePerSlice bool
+	// 可选, 切片命名空间的前缀, 默认 "slice-"
+	SliceNamespacePrefix string
+	// 可选, 集群的Pod及Service网段, 切片的默认网络策略中UPF访问数据网络(N6)时排除这些网段, 避免访问集群内部
+	ClusterCIDRs []string
+	// 可选, Play未指定时的带宽限制方式: annotation(默认)或tc
+	BandwidthEnforcer string
+	// 可选, tc方式限速的UPF接口, 默认n3, 以及N6_NETWORKS中各DNN的N6接口n6-<dnn>, 未配置N6网络时为eth0(N6流量经eth0 NAT后离开Pod)
+	TCN3Interface string
+	TCN6Interface string
+	// 可选, 默认集群(DefaultCluster, 即KubeconfigPath的当前上下文或集群内配置)之外的集群, 集群名称到kubeconfig上下文的映射
+	Clusters map[string]string
+	// 可选, 各集群的站点标签, 切片可按标签选择集群
+	ClusterLabels map[string]map[string]string
+}
+
+// SliceNamespace 切片资源所在的命名空间, 默认所有切片共用Namespace, sliceID为空时同样返回Namespace
+func (c KubeConfig) SliceNamespace(sliceID string) string {
+	if !c.NamespacePerSlice || sliceID == "" {
+		return c.Namespace
+	}
+	prefix := c.SliceNamespacePrefix
+	if prefix == "" {
+		prefix = "slice-"
+	}
+	return prefix + sliceID
 }
 
 type ServerConfig struct {
@@ -34,6 +67,7 @@ type ServerConfig struct {
 	MonitorStoreName  string
 	PlayStoreName     string
 	SLAStoreName      string
+	ControlStoreName  string
 }
 
 type IPAMConfig struct {
@@ -42,6 +76,26 @@ type IPAMConfig struct {
 	SessionNetwork      string
 	SessionSubnetLength uint8
 	IPAMTimeout         time.Duration
+	// 可选, 非空时启动时按N3/N4网络创建并校验Multus的NetworkAttachmentDefinition, 为NAD使用的主机接口
+	NADMaster string
+	// 可选, NAD的CNI类型: macvlan(默认)或ipvlan
+	NADType string
+	// 可选, 各DNN的N6网络, 键为DNN, 值为主机接口, 为每个DNN创建NAD n6network-<dnn>
+	// UPF通过接口n6-<dnn>接入数据网络, 地址和网关由数据网络的DHCP分配, 未配置的DNN仍经eth0 NAT
+	N6Networks map[string]string
+}
+
+// N6NetworkName DNN的N6网络(NAD)名称
+func N6NetworkName(dnn string) string {
+	return "n6network-" + dnn
+}
+
+// N6Interface UPF中DNN的N6接口名称, 未配置该DNN的N6网络时返回空
+func (c IPAMConfig) N6Interface(dnn string) string {
+	if _, ok := c.N6Networks[dnn]; !ok {
+		return ""
+	}
+	return "n6-" + dnn
 }
 
 type AIConfig struct {
@@ -54,6 +108,43 @@ type AIConfig struct {
 	MaxTokens int
 }
 
+type OSSConfig struct {
+	OSSEndpoint  string
+	OSSAccessKey string
+	OSSSecretKey string
+	OSSUseSSL    bool
+	OSSBucket    string
+	// 可选, 为0时不进行定时备份
+	BackupInterval time.Duration
+	// 可选, 每个切片保留的最大归档版本数, 为0时不限制
+	ArchiveMaxRevisions int
+	// 可选, 控制器决策记录的保留时长, 为0时永久保留
+	ArchiveDecisionRetention time.Duration
+}
+
+type DeliveryConfig struct {
+	// 交付方式: apply(默认, 直接应用到集群)或git(提交到本地git仓库, 由Argo/Flux同步)
+	DeliveryMode string
+	// 以下仅用于git模式
+	GitRepoPath    string // 本地git工作目录
+	GitBranch      string // 提交的分支, 默认main
+	GitSubdir      string // 仓库中存放切片的目录, 默认slices
+	GitAuthorName  string // 提交作者, 默认slicer
+	GitAuthorEmail string // 提交作者邮箱, 默认slicer@localhost
+	GitRemote      string // 可选, 非空时每次提交后推送到该远程仓库
+}
+
+type ControllerConfig struct {
+	// 可选, 并行控制切片的worker数, 默认4
+	ControllerWorkers int
+	// 可选, 所有切片每分钟最多开始的控制次数, 默认60
+	ControllerRate int
+	// 可选, 控制失败后重试的初始间隔, 每次失败翻倍, 默认10s
+	ControllerBackoffBase time.Duration
+	// 可选, 重试间隔的上限, 默认10m
+	ControllerBackoffMax time.Duration
+}
+
 type Config struct {
 	// for monitor
 	MonitorConfig
@@ -68,13 +159,22 @@ type Config struct {
 	ServerConfig
 
 	// for render
-	TemplatePath string
+	TemplatePath string // 模板覆盖目录, 可为空, 为空时只使用内嵌模板
 
 	// for ipam
 	IPAMConfig
 
 	// for ai
 	AIConfig
+
+	// for oss(backup)
+	OSSConfig
+
+	// for delivery
+	DeliveryConfig
+
+	// for controller
+	ControllerConfig
 }
 
 func LoadConfig() Config {
@@ -99,6 +199,21 @@ func LoadConfig() Config {
 			Namespace:        MustGetEnv("NAMESPACE"),         //用于open5gs的namespace
 			MonitorNamespace: MustGetEnv("MONITOR_NAMESPACE"), //监控系统所在的namespace
 			KubeconfigPath:   os.Getenv("KUBECONFIG_PATH"),    // kubeconfig文件路径,可为空,如果不设置则使用集群内配置
+			// 等待就绪, 均为可选
+			RolloutTimeout:      String2Duration(GetEnv("ROLLOUT_TIMEOUT")),
+			RolloutAutoRollback: String2Bool(GetEnv("ROLLOUT_AUTO_ROLLBACK")),
+			// 每个切片独立的命名空间, 均为可选
+			NamespacePerSlice:    String2Bool(GetEnv("NAMESPACE_PER_SLICE")),
+			SliceNamespacePrefix: GetEnv("SLICE_NAMESPACE_PREFIX"),
+			// 集群网段, 可选
+			ClusterCIDRs: String2CIDRs(GetEnv("CLUSTER_CIDRS")),
+			// 带宽限制, 均为可选
+			BandwidthEnforcer: GetEnv("BANDWIDTH_ENFORCER"),
+			TCN3Interface:     GetEnv("TC_N3_INTERFACE"),
+			TCN6Interface:     GetEnv("TC_N6_INTERFACE"),
+			// 多集群, 均为可选
+			Clusters:      String2Map(GetEnv("CLUSTERS")),
+			ClusterLabels: String2Labels(GetEnv("CLUSTER_LABELS")),
 		},
 
 		// for http server
@@ -109,10 +224,11 @@ func LoadConfig() Config {
 			MonitorStoreName:  "monitor",
 			PlayStoreName:     "play",
 			SLAStoreName:      "sla",
+			ControlStoreName:  "control",
 		},
 
 		// for render
-		TemplatePath: MustGetEnv("TEMPLATE_PATH"),
+		TemplatePath: os.Getenv("TEMPLATE_PATH"), // 可为空, 其中的同名模板覆盖内嵌模板, 修改后自动重新加载
 
 		// for ipam
 		IPAMConfig: IPAMConfig{
@@ -121,6 +237,10 @@ func LoadConfig() Config {
 			SessionNetwork:      MustGetEnv("SESSION_NETWORK"),
 			SessionSubnetLength: String2Uint8(MustGetEnv("SESSION_SUBNET_LENGTH")),
 			IPAMTimeout:         String2Duration(MustGetEnv("IPAM_TIMEOUT")),
+			// Multus网络, 均为可选
+			NADMaster:  GetEnv("NAD_MASTER"),
+			NADType:    GetEnv("NAD_TYPE"),
+			N6Networks: String2Map(GetEnv("N6_NETWORKS")),
 		},
 
 		// for ai
@@ -133,6 +253,38 @@ func LoadConfig() Config {
 			Timeout:   String2Duration(GetEnv("AI_TIMEOUT")),
 			MaxTokens: String2Int(GetEnv("AI_MAX_TOKENS")),
 		},
+
+		// for oss(backup), 均为可选, OSS_ENDPOINT为空时不启用备份
+		OSSConfig: OSSConfig{
+			OSSEndpoint:    GetEnv("OSS_ENDPOINT"),
+			OSSAccessKey:   GetEnv("OSS_ACCESS_KEY"),
+			OSSSecretKey:   GetEnv("OSS_SECRET_KEY"),
+			OSSUseSSL:      String2Bool(GetEnv("OSS_USE_SSL")),
+			OSSBucket:      GetEnv("OSS_BUCKET"),
+			BackupInterval: String2Duration(GetEnv("BACKUP_INTERVAL")),
+			// 归档保留策略
+			ArchiveMaxRevisions:      String2Int(GetEnv("ARCHIVE_MAX_REVISIONS")),
+			ArchiveDecisionRetention: String2Duration(GetEnv("ARCHIVE_DECISION_RETENTION")),
+		},
+
+		// for delivery, 均为可选, 默认直接应用到集群
+		DeliveryConfig: DeliveryConfig{
+			DeliveryMode:   GetEnv("DELIVERY_MODE"),
+			GitRepoPath:    GetEnv("GIT_REPO_PATH"),
+			GitBranch:      GetEnv("GIT_BRANCH"),
+			GitSubdir:      GetEnv("GIT_SUBDIR"),
+			GitAuthorName:  GetEnv("GIT_AUTHOR_NAME"),
+			GitAuthorEmail: GetEnv("GIT_AUTHOR_EMAIL"),
+			GitRemote:      GetEnv("GIT_REMOTE"),
+		},
+
+		// for controller, 均为可选
+		ControllerConfig: ControllerConfig{
+			ControllerWorkers:     String2Int(GetEnv("CONTROLLER_WORKERS")),
+			ControllerRate:        String2Int(GetEnv("CONTROLLER_RATE")),
+			ControllerBackoffBase: String2Duration(GetEnv("CONTROLLER_BACKOFF_BASE")),
+			ControllerBackoffMax:  String2Duration(GetEnv("CONTROLLER_BACKOFF_MAX")),
+		},
 	}
 }
 
@@ -166,7 +318,11 @@ func String2Uint8(s string) uint8 {
 	return uint8(i)
 }
 
+// String2Int 可选变量为空时返回0, 不视为转换失败
 func String2Int(s string) int {
+	if s == "" {
+		return 0
+	}
 	i, err := strconv.Atoi(s)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("变量 %s 转换失败", s))
@@ -175,7 +331,22 @@ func String2Int(s string) int {
 	return i
 }
 
+func String2Bool(s string) bool {
+	if s == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("变量 %s 转换失败", s))
+	}
+	return b
+}
+
+// String2Duration 可选变量为空时返回0, 不视为转换失败
 func String2Duration(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
 	// 检查是否为纯数字
 	if seconds, err := strconv.Atoi(s); err == nil {
 		// 将纯数字视为秒
@@ -189,3 +360,58 @@ func String2Duration(s string) time.Duration {
 		return d
 	}
 }
+
+// String2Map 解析 "k1=v1,k2=v2" 格式的变量, 为空时返回nil
+func String2Map(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || k == "" {
+			slog.Warn(fmt.Sprintf("变量 %s 转换失败", s))
+			continue
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// String2CIDRs 解析 "cidr1,cidr2" 格式的变量, 跳过不合法的网段, 为空时返回nil
+func String2CIDRs(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var cidrs []string
+	for _, cidr := range strings.Split(s, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			slog.Warn(fmt.Sprintf("变量 %s 转换失败", s))
+			continue
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs
+}
+
+// String2Labels 解析 "name1:k1=v1,name1:k2=v2,name2:k1=v3" 格式的变量, 返回各名称的标签, 为空时返回nil
+func String2Labels(s string) map[string]map[string]string {
+	if s == "" {
+		return nil
+	}
+	m := make(map[string]map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		name, label, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		k, v, ok2 := strings.Cut(label, "=")
+		if !ok || !ok2 || name == "" || k == "" {
+			slog.Warn(fmt.Sprintf("变量 %s 转换失败", s))
+			continue
+		}
+		if m[name] == nil {
+			m[name] = make(map[string]string)
+		}
+		m[name][k] = v
+	}
+	return m
+}